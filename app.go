@@ -0,0 +1,12 @@
+package main
+
+import (
+	"badminton-backend/internal/job"
+	"github.com/gin-gonic/gin"
+)
+
+// App 整个应用，包括 HTTP 服务和后台任务
+type App struct {
+	server    *gin.Engine
+	scheduler *job.Scheduler
+}
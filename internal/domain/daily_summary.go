@@ -29,3 +29,21 @@ type DailySummary struct {
 	CreatedAt time.Time // 创建时间
 	UpdatedAt time.Time // 更新时间
 }
+
+// StrokeCounts 按击球类型返回击球次数，key 与 StrokeTypes 一致
+func (ds DailySummary) StrokeCounts() map[string]int {
+	return map[string]int{
+		"forehand_clear": ds.ForehandClear,
+		"backhand_clear": ds.BackhandClear,
+		"forehand_lift":  ds.ForehandLift,
+		"backhand_lift":  ds.BackhandLift,
+		"forehand_net":   ds.ForehandNet,
+		"backhand_net":   ds.BackhandNet,
+		"forehand_smash": ds.ForehandSmash,
+		"backhand_smash": ds.BackhandSmash,
+		"forehand_drop":  ds.ForehandDrop,
+		"backhand_drop":  ds.BackhandDrop,
+		"forehand_drive": ds.ForehandDrive,
+		"backhand_drive": ds.BackhandDrive,
+	}
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// DataArchive 用户个人数据的下载包，内容是若干个 JSON 文件组成的 zip
+type DataArchive struct {
+	Id       int64
+	UserID   int64
+	Status   string
+	FilePath string    // 压缩包在本地磁盘上的路径，不对外暴露
+	Token    string    // 下载凭证，持有它就可以下载，不需要登录
+	ExpireAt time.Time // 过期之后压缩包会被删除
+	ErrMsg   string
+	Ctime    time.Time
+	Utime    time.Time
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// 导出格式
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// 异步任务的状态
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// ExportJob 异步导出任务
+type ExportJob struct {
+	Id        int64
+	UserID    int64
+	Format    string
+	StartDate time.Time
+	EndDate   time.Time
+	Status    string
+	RowCount  int64
+	FilePath  string // 导出文件在本地磁盘上的路径，不对外暴露
+	ErrMsg    string
+	Ctime     time.Time
+	Utime     time.Time
+}
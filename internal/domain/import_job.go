@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// ImportJob 导入历史训练数据的异步任务
+type ImportJob struct {
+	Id          int64
+	UserID      int64
+	Source      string // 数据来源，决定了列的映射关系
+	Format      string // csv 或者 json
+	FileHash    string // 文件内容的 SHA-256，用来识别重复上传
+	FilePath    string // 上传文件在本地磁盘上的路径
+	Status      string
+	TotalRows   int
+	SuccessRows int
+	FailedRows  int
+	Errors      []ImportRowError // 每一行的错误，最多保留前 1000 条
+	ErrMsg      string           // 整个任务失败的原因
+	Ctime       time.Time
+	Utime       time.Time
+}
+
+// ImportRowError 某一行数据的校验错误
+type ImportRowError struct {
+	Row     int // 从 1 开始的数据行号，不包括 CSV 表头
+	Message string
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// 登录方式
+const (
+	LoginMethodPassword = "password"
+	LoginMethodSMS      = "sms"
+	LoginMethodOAuth2   = "oauth2"
+)
+
+// LoginRecord 一次成功的登录
+type LoginRecord struct {
+	Id        int64
+	UserID    int64
+	Ssid      string // 这次登录创建的会话
+	Method    string
+	IP        string
+	UserAgent string
+	Ctime     time.Time
+}
+
+// Session 一个还没有失效的登录会话
+type Session struct {
+	Ssid      string
+	UserID    int64
+	Device    string // 设备名称，客户端没有上报时根据 User-Agent 推断
+	IP        string
+	UserAgent string
+	LoginTime time.Time
+	LastSeen  time.Time // 最后一次使用这个会话访问接口的时间
+}
+
+// LoginClient 发起登录的客户端信息，用于防暴力破解
+type LoginClient struct {
+	IP string
+	// CaptchaToken 人机验证组件返回的 token，连续失败之后才需要
+	CaptchaToken string
+}
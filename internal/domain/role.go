@@ -0,0 +1,59 @@
+package domain
+
+// Role 用户角色，数值越大权限越多
+type Role int
+
+const (
+	RoleUser        Role = 0 // 普通用户
+	RoleCoach       Role = 1 // 教练
+	RoleClubAdmin   Role = 2 // 俱乐部管理员
+	RoleSystemAdmin Role = 3 // 系统管理员
+)
+
+// Permission 管理接口使用的权限
+type Permission string
+
+const (
+	PermUserRead    Permission = "user:read"    // 搜索、查看用户
+	PermSummaryRead Permission = "summary:read" // 查看任意用户的训练汇总
+	PermUserDisable Permission = "user:disable" // 禁用、启用账号
+	PermSMSQueue    Permission = "sms:queue"    // 查看发送失败的短信、重新投递
+	PermRoleManage  Permission = "role:manage"  // 修改用户的角色
+)
+
+// rolePermissions 每个角色拥有的权限
+// 普通用户和教练目前没有管理权限，教练和学员的关系建立之后再开放查看学员数据
+// 俱乐部管理员同理，现在还没有俱乐部和成员的关系，查看用户和训练数据只能是全站范围的，
+// 等能限定在本俱乐部之后再开放，在那之前只有系统管理员能查看
+var rolePermissions = map[Role][]Permission{
+	RoleSystemAdmin: {PermUserRead, PermSummaryRead, PermUserDisable, PermSMSQueue, PermRoleManage},
+}
+
+func (r Role) Valid() bool {
+	return r >= RoleUser && r <= RoleSystemAdmin
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleUser:
+		return "user"
+	case RoleCoach:
+		return "coach"
+	case RoleClubAdmin:
+		return "club_admin"
+	case RoleSystemAdmin:
+		return "system_admin"
+	default:
+		return "unknown"
+	}
+}
+
+// HasPermission 角色是否拥有某个权限
+func (r Role) HasPermission(p Permission) bool {
+	for _, perm := range rolePermissions[r] {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
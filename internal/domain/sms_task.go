@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// 短信重试任务的状态
+const (
+	SMSTaskStatusPending   = "pending"
+	SMSTaskStatusSucceeded = "succeeded"
+	// SMSTaskStatusDead 重试次数用完或者过期了，需要人工处理
+	SMSTaskStatusDead = "dead"
+)
+
+// SMSTask 同步发送失败之后放进队列等待重试的短信
+type SMSTask struct {
+	Id      int64
+	TplId   string
+	Args    []string
+	Numbers []string
+	Status  string
+	// Attempts 已经尝试发送的次数，包括第一次同步发送
+	Attempts      int
+	NextRetryTime time.Time
+	// Deadline 之后再发已经没有意义了，例如验证码已经过期
+	Deadline time.Time
+	LastErr  string
+	Ctime    time.Time
+	Utime    time.Time
+}
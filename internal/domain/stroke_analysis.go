@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// 参考画像
+const (
+	StrokeReferenceIdeal   = "ideal"   // 教练设定的理想击球结构
+	StrokeReferenceAverage = "average" // 所有用户的平均击球结构
+)
+
+// 建议的严重程度
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+)
+
+// 建议编码，App 根据编码渲染对应的文案和图标
+const (
+	RecommendationBackhandLow = "backhand_low" // 某一类击球中反手占比过低
+	RecommendationNoNetPlay   = "no_net_play"  // 近 7 天没有网前球
+	RecommendationUnderused   = "underused"    // 某种击球明显少于参考画像
+	RecommendationOverused    = "overused"     // 某种击球明显多于参考画像
+)
+
+// StrokeRecommendation 一条结构化的训练建议
+type StrokeRecommendation struct {
+	Code       string
+	Severity   string
+	StrokeType string  // 涉及的击球类型或击球类别，例如 backhand_clear、clear、net
+	Actual     float64 // 实际占比
+	Expected   float64 // 期望占比
+	Message    string
+}
+
+// StrokeAnalysis 击球结构分析结果
+type StrokeAnalysis struct {
+	Reference       string
+	StartDate       time.Time
+	EndDate         time.Time
+	TotalStrokes    int
+	Mix             map[string]float64 // 各击球类型占总击球数的比例
+	ReferenceMix    map[string]float64 // 参考画像中各击球类型的比例
+	Recommendations []StrokeRecommendation
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+const (
+	SwingSpeedBucketWidth = 10 // 挥拍速度直方图每个桶的宽度（km/h）
+	SwingSpeedBucketCount = 40 // 桶的数量，最后一个桶统计所有大于等于 390 km/h 的挥拍
+
+	StrokeTypeAll = "all" // 不区分击球类型的整体分布
+)
+
+// StrokeTypes 支持统计挥拍速度的击球类型，与 DailySummary 中的击球统计一一对应
+var StrokeTypes = []string{
+	"forehand_clear", "backhand_clear",
+	"forehand_lift", "backhand_lift",
+	"forehand_net", "backhand_net",
+	"forehand_smash", "backhand_smash",
+	"forehand_drop", "backhand_drop",
+	"forehand_drive", "backhand_drive",
+}
+
+// SwingSpeedDistribution 某一天某种击球类型的挥拍速度分布
+type SwingSpeedDistribution struct {
+	UserID     int64
+	Date       time.Time
+	StrokeType string
+	Buckets    []int   // Buckets[i] 表示速度落在 [i*10, (i+1)*10) km/h 内的挥拍次数
+	Count      int     // 挥拍次数
+	AvgSpeed   float64 // 平均速度
+	P50Speed   int     // 速度中位数
+	P90Speed   int     // 90 分位速度
+}
+
+// SwingSpeedPercentile 用户在同年龄段、同性别用户中的挥拍速度排名
+type SwingSpeedPercentile struct {
+	StrokeType string
+	Gender     int
+	AgeFrom    int     // 年龄段下限（含）
+	AgeTo      int     // 年龄段上限（含）
+	UserSpeed  float64 // 用户在统计区间内的平均 90 分位速度
+	PeerCount  int     // 同组用户数（包含自己）
+	Percentile float64 // 超过了同组百分之多少的用户
+}
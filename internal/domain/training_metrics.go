@@ -0,0 +1,20 @@
+package domain
+
+// 受伤风险等级，依据急慢性负荷比（ACWR）划分
+const (
+	InjuryRiskUnknown       = "unknown"       // 历史数据不足，无法评估
+	InjuryRiskUndertraining = "undertraining" // ACWR < 0.8，训练量不足
+	InjuryRiskOptimal       = "optimal"       // 0.8 <= ACWR <= 1.3，最佳区间
+	InjuryRiskCaution       = "caution"       // 1.3 < ACWR <= 1.5，需要注意
+	InjuryRiskHigh          = "high"          // ACWR > 1.5，受伤风险高
+)
+
+// TrainingMetrics 根据身体数据与训练数据推算出的生理指标
+type TrainingMetrics struct {
+	Calories     float64 // 估算消耗的热量（千卡）
+	TrainingLoad float64 // 训练负荷分数
+	AcuteLoad    float64 // 急性负荷：近 7 天日均训练负荷
+	ChronicLoad  float64 // 慢性负荷：近 28 天日均训练负荷
+	ACWR         float64 // 急慢性负荷比
+	InjuryRisk   string  // 受伤风险等级
+}
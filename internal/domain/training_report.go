@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// 报告周期
+const (
+	ReportPeriodWeek  = "week"
+	ReportPeriodMonth = "month"
+)
+
+// TrainingReport 周报/月报
+type TrainingReport struct {
+	Id        int64
+	UserID    int64
+	Period    string
+	StartDate time.Time
+	EndDate   time.Time
+
+	Totals     DailySummary // 本周期的累计数据
+	ActiveDays int          // 本周期有训练的天数
+
+	// 与上一个周期相比的变化百分比，上一周期没有数据时为 0
+	DurationChange   float64
+	SwingsChange     float64
+	ActiveDaysChange int
+
+	Records []PersonalRecord // 本周期打破的个人纪录
+	Streak  int              // 截止到周期最后一天的连续训练天数
+
+	Ctime time.Time
+}
+
+// 个人纪录的指标
+const (
+	RecordMaxDailySwings   = "max_daily_swings"   // 单日最多挥拍次数
+	RecordMaxDailyDuration = "max_daily_duration" // 单日最长训练时长
+	RecordMaxSwingSpeed    = "max_swing_speed"    // 最大挥拍速度
+)
+
+// PersonalRecord 一项个人纪录
+type PersonalRecord struct {
+	Metric   string
+	Value    int
+	Previous int // 之前的纪录
+	Date     time.Time
+}
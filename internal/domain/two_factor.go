@@ -0,0 +1,33 @@
+package domain
+
+// 二次验证的方式
+const (
+	TwoFactorTOTP     = "totp"
+	TwoFactorSMS      = "sms"
+	TwoFactorRecovery = "recovery"
+)
+
+// UserTOTP 用户的 TOTP 配置，确认之前 Enabled 为 false
+type UserTOTP struct {
+	UserID  int64
+	Secret  string // 明文的 base32 密钥，只在 service 中出现，存储时会加密
+	Enabled bool
+	// LastStep 最后一次使用的时间步，同一个验证码不能用两次
+	LastStep int64
+}
+
+// TwoFactorStatus 用户二次验证的设置情况
+type TwoFactorStatus struct {
+	TOTPEnabled       bool
+	RecoveryCodesLeft int64
+}
+
+// PreAuth 第一步登录通过之后、二次验证通过之前的中间状态
+type PreAuth struct {
+	UserID int64
+	Role   Role
+	// LoginMethod 第一步的登录方式，二次验证通过之后记入登录历史
+	LoginMethod string
+	// Methods 可以使用的二次验证方式
+	Methods []string
+}
@@ -2,6 +2,13 @@ package domain
 
 import "time"
 
+// 用户性别
+const (
+	GenderUnknown = 0
+	GenderMale    = 1
+	GenderFemale  = 2
+)
+
 type User struct {
 	Id       int64
 	Username string
@@ -9,10 +16,14 @@ type User struct {
 	Password string
 	Phone    string
 	Nickname string
+	Gender   int
 	WeightKG int
 	HeightCM int
 	Birthday time.Time
 	AboutMe  string
 	Ctime    time.Time
 	Utime    time.Time
+	Dtime    time.Time // 注销时间，零值表示账号正常
+	Role     Role
+	Disabled bool // 被管理员禁用，禁用期间不能登录
 }
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// UserIdentity 用户在第三方平台上的身份，一个用户可以绑定多个平台
+type UserIdentity struct {
+	Id       int64
+	UserID   int64
+	Provider string // 第三方平台的名称，与配置中的名称一致
+	Subject  string // 用户在第三方平台上的唯一标识，例如 sub、openid
+	Nickname string
+	Ctime    time.Time
+}
+
+// OAuth2 授权的用途
+const (
+	OAuth2PurposeLogin = "login" // 登录，没有绑定过的身份会自动注册
+	OAuth2PurposeLink  = "link"  // 给已经登录的账号绑定第三方身份
+)
+
+// OAuth2State 发起授权时保存下来，回调时通过 state 参数取回
+type OAuth2State struct {
+	Provider     string
+	Purpose      string
+	UserID       int64 // 绑定时才有
+	CodeVerifier string
+	// Nonce 同时写在发起授权的浏览器的 cookie 里，回调时必须一致，
+	// 防止攻击者把自己发起的授权链接交给别人完成
+	Nonce string
+}
@@ -0,0 +1,25 @@
+package job
+
+import (
+	"badminton-backend/internal/service"
+	"context"
+)
+
+// AccountPurgeJob 彻底删除超过宽限期的注销账号
+type AccountPurgeJob struct {
+	svc service.AccountService
+}
+
+func NewAccountPurgeJob(svc service.AccountService) *AccountPurgeJob {
+	return &AccountPurgeJob{
+		svc: svc,
+	}
+}
+
+func (j *AccountPurgeJob) Name() string {
+	return "account_purge"
+}
+
+func (j *AccountPurgeJob) Run(ctx context.Context) error {
+	return j.svc.PurgeExpired(ctx)
+}
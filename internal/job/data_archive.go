@@ -0,0 +1,25 @@
+package job
+
+import (
+	"badminton-backend/internal/service"
+	"context"
+)
+
+// DataArchiveCleanJob 删除过期的个人数据压缩包
+type DataArchiveCleanJob struct {
+	svc service.DataArchiveService
+}
+
+func NewDataArchiveCleanJob(svc service.DataArchiveService) *DataArchiveCleanJob {
+	return &DataArchiveCleanJob{
+		svc: svc,
+	}
+}
+
+func (j *DataArchiveCleanJob) Name() string {
+	return "data_archive_clean"
+}
+
+func (j *DataArchiveCleanJob) Run(ctx context.Context) error {
+	return j.svc.CleanExpired(ctx)
+}
@@ -0,0 +1,39 @@
+package job
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	"context"
+	"errors"
+	"time"
+)
+
+// ReportJob 为上一个完整的周和月生成训练报告
+// 报告的生成是幂等的，所以每次调度都可以放心地重新检查
+type ReportJob struct {
+	svc service.TrainingReportService
+}
+
+func NewReportJob(svc service.TrainingReportService) *ReportJob {
+	return &ReportJob{
+		svc: svc,
+	}
+}
+
+func (j *ReportJob) Name() string {
+	return "training_report"
+}
+
+func (j *ReportJob) Run(ctx context.Context) error {
+	now := time.Now()
+	var errs []error
+	for _, period := range []string{domain.ReportPeriodWeek, domain.ReportPeriodMonth} {
+		start, _, err := service.PeriodRange(period, now)
+		if err != nil {
+			return err
+		}
+		// 当前周期还没结束，取上一个周期
+		errs = append(errs, j.svc.GenerateAll(ctx, period, start.AddDate(0, 0, -1)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package job
+
+import (
+	"badminton-backend/pkg/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+// Scheduler 在进程内按固定间隔调度后台任务
+// 任务需要自己保证幂等，多实例部署时同一个任务可能在多个实例上同时执行
+type Scheduler struct {
+	entries []entry
+	l       logger.Logger
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type entry struct {
+	job      Job
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func NewScheduler(l logger.Logger) *Scheduler {
+	return &Scheduler{
+		l: l,
+	}
+}
+
+// AddJob 注册任务，任务每隔 interval 执行一次，单次执行最多 timeout
+func (s *Scheduler) AddJob(j Job, interval, timeout time.Duration) *Scheduler {
+	s.entries = append(s.entries, entry{
+		job:      j,
+		interval: interval,
+		timeout:  timeout,
+	})
+	return s
+}
+
+// Start 启动所有任务，任务会先立刻执行一次
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go func(e entry) {
+			defer s.wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+}
+
+// Stop 停止调度，并等待正在执行的任务结束
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		s.run(ctx, e)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e entry) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	start := time.Now()
+	err := e.job.Run(ctx)
+	if err != nil {
+		s.l.Error("执行后台任务失败",
+			logger.Field{Key: "job", Value: e.job.Name()},
+			logger.Field{Key: "err", Value: err.Error()})
+		return
+	}
+	s.l.Debug("执行后台任务成功",
+		logger.Field{Key: "job", Value: e.job.Name()},
+		logger.Field{Key: "duration", Value: time.Since(start).String()})
+}
@@ -0,0 +1,25 @@
+package job
+
+import (
+	"badminton-backend/internal/service/sms/async"
+	"context"
+)
+
+// SMSRetryJob 重试同步发送失败的短信
+type SMSRetryJob struct {
+	svc *async.Service
+}
+
+func NewSMSRetryJob(svc *async.Service) *SMSRetryJob {
+	return &SMSRetryJob{
+		svc: svc,
+	}
+}
+
+func (j *SMSRetryJob) Name() string {
+	return "sms_retry"
+}
+
+func (j *SMSRetryJob) Run(ctx context.Context) error {
+	return j.svc.Retry(ctx)
+}
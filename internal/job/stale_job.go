@@ -0,0 +1,53 @@
+package job
+
+import (
+	"badminton-backend/internal/service"
+	"badminton-backend/pkg/logger"
+	"context"
+)
+
+// StaleJobSweepJob 把导出、导入、数据打包中已经中断的任务标记为失败
+// 这些任务在进程内执行，进程重启之后状态会一直停留在 pending 或 running
+type StaleJobSweepJob struct {
+	exportSvc  service.ExportService
+	importSvc  service.ImportService
+	archiveSvc service.DataArchiveService
+	l          logger.Logger
+}
+
+func NewStaleJobSweepJob(exportSvc service.ExportService, importSvc service.ImportService,
+	archiveSvc service.DataArchiveService, l logger.Logger) *StaleJobSweepJob {
+	return &StaleJobSweepJob{
+		exportSvc:  exportSvc,
+		importSvc:  importSvc,
+		archiveSvc: archiveSvc,
+		l:          l,
+	}
+}
+
+func (j *StaleJobSweepJob) Name() string {
+	return "stale_job_sweep"
+}
+
+func (j *StaleJobSweepJob) Run(ctx context.Context) error {
+	sweeps := []struct {
+		name string
+		fn   func(ctx context.Context) (int64, error)
+	}{
+		{name: "export", fn: j.exportSvc.FailStaleJobs},
+		{name: "import", fn: j.importSvc.FailStaleJobs},
+		{name: "archive", fn: j.archiveSvc.FailStaleJobs},
+	}
+	for _, s := range sweeps {
+		cnt, err := s.fn(ctx)
+		if err != nil {
+			return err
+		}
+		if cnt > 0 {
+			j.l.Warn("标记中断的后台任务",
+				logger.Field{Key: "type", Value: s.name},
+				logger.Field{Key: "count", Value: cnt})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package job
+
+import "context"
+
+// Job 由 Scheduler 周期性执行的后台任务
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
@@ -13,17 +13,25 @@ type DailySummaryCache interface {
 	Get(ctx context.Context, biz string, userID int64, date time.Time) (domain.DailySummary, error)
 	Set(ctx context.Context, biz string, userID int64, date time.Time, u domain.DailySummary) error
 	Delete(ctx context.Context, biz string, userID int64, date time.Time) error
+	// DeleteByUser 删除某个用户所有日期、所有业务的缓存
+	DeleteByUser(ctx context.Context, userID int64) error
+	// GetAggregate 所有用户在区间内的汇总，不随单个用户的数据更新失效，只靠过期时间刷新
+	GetAggregate(ctx context.Context, startDate, endDate time.Time) (domain.DailySummary, error)
+	SetAggregate(ctx context.Context, startDate, endDate time.Time, ds domain.DailySummary) error
 }
 
 type RedisDailySummaryCache struct {
 	cmd        redis.Cmdable
 	expiration time.Duration
+	// aggExpiration 所有用户的汇总要扫描整个区间，计算代价高，平均值短时间内也不会有明显变化
+	aggExpiration time.Duration
 }
 
 func NewRedisDailySummaryCache(cmd redis.Cmdable) DailySummaryCache {
 	return &RedisDailySummaryCache{
-		cmd:        cmd,
-		expiration: time.Minute * 15,
+		cmd:           cmd,
+		expiration:    time.Minute * 15,
+		aggExpiration: time.Hour,
 	}
 }
 
@@ -51,6 +59,40 @@ func (cache *RedisDailySummaryCache) Delete(ctx context.Context, biz string, use
 	return cache.cmd.Del(ctx, cache.key(biz, userID, date)).Err()
 }
 
+func (cache *RedisDailySummaryCache) DeleteByUser(ctx context.Context, userID int64) error {
+	// 日期部分固定是 yyyy-MM-dd，避免用户 ID 和日期中的数字混淆，例如用户 12 和 12 月
+	pattern := fmt.Sprintf("DailySummary:info:*-%d-????-??-??", userID)
+	iter := cache.cmd.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if err := cache.cmd.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (cache *RedisDailySummaryCache) GetAggregate(ctx context.Context, startDate, endDate time.Time) (domain.DailySummary, error) {
+	data, err := cache.cmd.Get(ctx, cache.aggKey(startDate, endDate)).Result()
+	if err != nil {
+		return domain.DailySummary{}, err
+	}
+	var ds domain.DailySummary
+	err = json.Unmarshal([]byte(data), &ds)
+	return ds, err
+}
+
+func (cache *RedisDailySummaryCache) SetAggregate(ctx context.Context, startDate, endDate time.Time, ds domain.DailySummary) error {
+	data, err := json.Marshal(ds)
+	if err != nil {
+		return err
+	}
+	return cache.cmd.Set(ctx, cache.aggKey(startDate, endDate), data, cache.aggExpiration).Err()
+}
+
+func (cache *RedisDailySummaryCache) aggKey(startDate, endDate time.Time) string {
+	return fmt.Sprintf("DailySummary:aggregate:%s-%s", startDate.Format(time.DateOnly), endDate.Format(time.DateOnly))
+}
+
 func (cache *RedisDailySummaryCache) key(biz string, userID int64, date time.Time) string {
 	return fmt.Sprintf("DailySummary:info:%s-%d-%s", biz, userID, date.Format(time.DateOnly))
 }
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/record_login_failure.lua
+var luaRecordLoginFailure string
+
+// LockoutPolicy 连续登录失败之后的锁定策略
+type LockoutPolicy struct {
+	// Window 统计失败次数的窗口，这段时间内没有再失败就清零
+	Window time.Duration
+	// Threshold 失败多少次开始锁定，0 表示不锁定
+	Threshold int
+	// BaseLock 第一次锁定的时长，之后每多失败一次翻倍
+	BaseLock time.Duration
+	MaxLock  time.Duration
+}
+
+// LoginAttemptCache 按账号或者 IP 统计登录失败的次数
+// subject 是统计的对象，例如 account:xxx、ip:1.2.3.4
+type LoginAttemptCache interface {
+	// RecordFailure 记录一次失败，返回累计的失败次数以及这次触发的锁定时长（没有锁定时为 0）
+	RecordFailure(ctx context.Context, subject string, p LockoutPolicy) (int64, time.Duration, error)
+	// State 返回当前的失败次数以及剩余的锁定时长
+	State(ctx context.Context, subject string) (int64, time.Duration, error)
+	// Reset 登录成功之后清零
+	Reset(ctx context.Context, subject string) error
+}
+
+type RedisLoginAttemptCache struct {
+	cmd redis.Cmdable
+}
+
+func NewRedisLoginAttemptCache(cmd redis.Cmdable) LoginAttemptCache {
+	return &RedisLoginAttemptCache{
+		cmd: cmd,
+	}
+}
+
+func (cache *RedisLoginAttemptCache) RecordFailure(ctx context.Context, subject string, p LockoutPolicy) (int64, time.Duration, error) {
+	res, err := cache.cmd.Eval(ctx, luaRecordLoginFailure,
+		[]string{cache.failKey(subject), cache.lockKey(subject)},
+		p.Window.Milliseconds(), p.Threshold, p.BaseLock.Milliseconds(), p.MaxLock.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(res) != 2 {
+		return 0, 0, errors.New("记录登录失败的脚本返回值不对")
+	}
+	return res[0], time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (cache *RedisLoginAttemptCache) State(ctx context.Context, subject string) (int64, time.Duration, error) {
+	pipe := cache.cmd.Pipeline()
+	failures := pipe.Get(ctx, cache.failKey(subject))
+	ttl := pipe.PTTL(ctx, cache.lockKey(subject))
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+	cnt, err := failures.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+	// key 不存在时 PTTL 返回负数
+	lock := ttl.Val()
+	if lock < 0 {
+		lock = 0
+	}
+	return cnt, lock, nil
+}
+
+func (cache *RedisLoginAttemptCache) Reset(ctx context.Context, subject string) error {
+	return cache.cmd.Del(ctx, cache.failKey(subject), cache.lockKey(subject)).Err()
+}
+
+func (cache *RedisLoginAttemptCache) failKey(subject string) string {
+	return fmt.Sprintf("login:fail:%s", subject)
+}
+
+func (cache *RedisLoginAttemptCache) lockKey(subject string) string {
+	return fmt.Sprintf("login:lock:%s", subject)
+}
@@ -0,0 +1,9 @@
+-- 预登录凭证的 key，users:preauth:token
+local key = KEYS[1]
+
+-- 凭证已经过期或者被用掉了，不能用 hincrby 创建一个没有过期时间的 key
+if redis.call("exists", key) == 0 then
+    return -1
+end
+
+return redis.call("hincrby", key, "failures", 1)
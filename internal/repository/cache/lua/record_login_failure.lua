@@ -0,0 +1,31 @@
+-- 失败次数的 key
+local failKey = KEYS[1]
+-- 锁定标记的 key
+local lockKey = KEYS[2]
+-- 统计失败次数的窗口，毫秒
+local window = tonumber(ARGV[1])
+-- 失败多少次开始锁定
+local threshold = tonumber(ARGV[2])
+-- 第一次锁定的时长以及最长的锁定时长，毫秒
+local baseLock = tonumber(ARGV[3])
+local maxLock = tonumber(ARGV[4])
+
+-- 每次失败都刷新窗口，持续失败的话次数会一直累加
+local failures = redis.call("incr", failKey)
+redis.call("pexpire", failKey, window)
+
+if threshold <= 0 or failures < threshold then
+    return {failures, 0}
+end
+
+-- 超过阈值之后每多失败一次，锁定时长翻倍
+local lock = math.floor(baseLock * math.pow(2, failures - threshold))
+if lock > maxLock then
+    lock = maxLock
+end
+redis.call("set", lockKey, failures, "px", lock)
+-- 锁定期间计数不能过期，否则解锁之后又从头开始
+if lock > window then
+    redis.call("pexpire", failKey, lock)
+end
+return {failures, lock}
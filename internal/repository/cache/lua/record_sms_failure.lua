@@ -0,0 +1,23 @@
+-- 连续失败次数的 key
+local failKey = KEYS[1]
+-- 不可用标记的 key
+local downKey = KEYS[2]
+-- 统计连续失败的窗口，毫秒
+local window = tonumber(ARGV[1])
+-- 连续失败多少次之后标记为不可用
+local threshold = tonumber(ARGV[2])
+-- 标记为不可用多久，毫秒
+local cooldown = tonumber(ARGV[3])
+
+local failures = redis.call("incr", failKey)
+if failures == 1 then
+    redis.call("pexpire", failKey, window)
+end
+if failures < threshold then
+    return 0
+end
+
+-- 所有实例看到的都是同一个标记，一起切换
+redis.call("del", failKey)
+redis.call("set", downKey, "1", "px", cooldown)
+return 1
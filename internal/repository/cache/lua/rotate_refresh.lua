@@ -0,0 +1,40 @@
+-- 会话的 key，users:session:ssid
+local key = KEYS[1]
+-- 用户所有会话的集合
+local userKey = KEYS[2]
+local ssid = ARGV[1]
+-- 客户端出示的 refresh token 的 ID
+local oldJti = ARGV[2]
+-- 新签发的 refresh token 的 ID
+local newJti = ARGV[3]
+-- 会话延长后的有效期，单位是秒
+local ttl = tonumber(ARGV[4])
+-- 当前时间，毫秒时间戳
+local now = tonumber(ARGV[5])
+-- 上一个 refresh token 在轮换之后还能使用的时长，单位是毫秒
+local grace = tonumber(ARGV[6])
+
+local vals = redis.call("hmget", key, "refresh_jti", "prev_jti", "prev_time")
+local cur = vals[1]
+if not cur then
+    -- 会话不存在，已经退出登录或者过期了
+    return {0, ""}
+end
+
+if cur ~= oldJti then
+    -- 客户端并发刷新（比如手机 App 切回前台时）会在很短的时间内出示同一个 refresh token，
+    -- 宽限期内出示上一个 token 不算被盗，返回当前的 ID，两个请求拿到的是同一个会话状态
+    if oldJti == vals[2] and now - tonumber(vals[3] or 0) <= grace then
+        return {2, cur}
+    end
+    -- 出示的是已经用过的 refresh token，说明 token 可能被盗了
+    -- 直接删除整个会话，由这次登录派生出来的所有 token 都会失效
+    redis.call("del", key)
+    redis.call("srem", userKey, ssid)
+    return {-1, ""}
+end
+
+redis.call("hset", key, "refresh_jti", newJti, "prev_jti", oldJti, "prev_time", now)
+redis.call("expire", key, ttl)
+redis.call("expire", userKey, ttl)
+return {1, newJti}
@@ -0,0 +1,13 @@
+-- 会话的 key，users:session:ssid
+local key = KEYS[1]
+-- 当前时间，毫秒时间戳
+local now = ARGV[1]
+
+-- 会话不存在，说明已经退出登录、被踢下线或者过期了
+if redis.call("exists", key) == 0 then
+    return 0
+end
+
+-- 会话有效，顺便记录最后访问时间
+redis.call("hset", key, "last_seen", now)
+return 1
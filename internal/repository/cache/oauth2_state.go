@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"badminton-backend/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// ErrOAuth2StateNotFound state 不存在、已经过期或者已经用过了
+var ErrOAuth2StateNotFound = errors.New("OAuth2 state 不存在")
+
+// OAuth2StateCache 保存发起授权时生成的 state 和 PKCE code verifier
+type OAuth2StateCache interface {
+	Set(ctx context.Context, state string, s domain.OAuth2State) error
+	// GetDel 取出并删除，每个 state 只能使用一次
+	GetDel(ctx context.Context, state string) (domain.OAuth2State, error)
+}
+
+type RedisOAuth2StateCache struct {
+	cmd redis.Cmdable
+	// expiration 用户在第三方页面完成授权的最长时间
+	expiration time.Duration
+}
+
+func NewRedisOAuth2StateCache(cmd redis.Cmdable) OAuth2StateCache {
+	return &RedisOAuth2StateCache{
+		cmd:        cmd,
+		expiration: time.Minute * 10,
+	}
+}
+
+func (cache *RedisOAuth2StateCache) Set(ctx context.Context, state string, s domain.OAuth2State) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return cache.cmd.Set(ctx, cache.key(state), data, cache.expiration).Err()
+}
+
+func (cache *RedisOAuth2StateCache) GetDel(ctx context.Context, state string) (domain.OAuth2State, error) {
+	data, err := cache.cmd.GetDel(ctx, cache.key(state)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return domain.OAuth2State{}, ErrOAuth2StateNotFound
+	}
+	if err != nil {
+		return domain.OAuth2State{}, err
+	}
+	var s domain.OAuth2State
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+func (cache *RedisOAuth2StateCache) key(state string) string {
+	return fmt.Sprintf("oauth2:state:%s", state)
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"badminton-backend/internal/domain"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	//go:embed lua/incr_pre_auth_failures.lua
+	luaIncrPreAuthFailures string
+
+	ErrPreAuthNotFound = errors.New("预登录凭证不存在")
+)
+
+// PreAuthCache 保存第一步登录通过之后的预登录凭证
+type PreAuthCache interface {
+	Set(ctx context.Context, token string, pa domain.PreAuth) error
+	Get(ctx context.Context, token string) (domain.PreAuth, error)
+	// IncrFailures 记录一次验证失败，返回累计的失败次数
+	IncrFailures(ctx context.Context, token string) (int64, error)
+	// Delete 删除凭证，返回 false 说明凭证已经被别的请求用掉了
+	Delete(ctx context.Context, token string) (bool, error)
+}
+
+type RedisPreAuthCache struct {
+	cmd redis.Cmdable
+	// expiration 用户输入二次验证码的最长时间
+	expiration time.Duration
+}
+
+func NewRedisPreAuthCache(cmd redis.Cmdable) PreAuthCache {
+	return &RedisPreAuthCache{
+		cmd:        cmd,
+		expiration: time.Minute * 5,
+	}
+}
+
+func (cache *RedisPreAuthCache) Set(ctx context.Context, token string, pa domain.PreAuth) error {
+	key := cache.key(token)
+	pipe := cache.cmd.TxPipeline()
+	pipe.HSet(ctx, key, map[string]any{
+		"uid":          pa.UserID,
+		"role":         int(pa.Role),
+		"login_method": pa.LoginMethod,
+		"methods":      strings.Join(pa.Methods, ","),
+		"failures":     0,
+	})
+	pipe.Expire(ctx, key, cache.expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (cache *RedisPreAuthCache) Get(ctx context.Context, token string) (domain.PreAuth, error) {
+	vals, err := cache.cmd.HGetAll(ctx, cache.key(token)).Result()
+	if err != nil {
+		return domain.PreAuth{}, err
+	}
+	if len(vals) == 0 {
+		return domain.PreAuth{}, ErrPreAuthNotFound
+	}
+	uid, _ := strconv.ParseInt(vals["uid"], 10, 64)
+	role, _ := strconv.Atoi(vals["role"])
+	var methods []string
+	if vals["methods"] != "" {
+		methods = strings.Split(vals["methods"], ",")
+	}
+	return domain.PreAuth{
+		UserID:      uid,
+		Role:        domain.Role(role),
+		LoginMethod: vals["login_method"],
+		Methods:     methods,
+	}, nil
+}
+
+func (cache *RedisPreAuthCache) IncrFailures(ctx context.Context, token string) (int64, error) {
+	res, err := cache.cmd.Eval(ctx, luaIncrPreAuthFailures, []string{cache.key(token)}).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if res < 0 {
+		return 0, ErrPreAuthNotFound
+	}
+	return res, nil
+}
+
+func (cache *RedisPreAuthCache) Delete(ctx context.Context, token string) (bool, error) {
+	n, err := cache.cmd.Del(ctx, cache.key(token)).Result()
+	return n > 0, err
+}
+
+func (cache *RedisPreAuthCache) key(token string) string {
+	return fmt.Sprintf("users:preauth:%s", token)
+}
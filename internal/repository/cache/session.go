@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"badminton-backend/internal/domain"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+var (
+	//go:embed lua/touch_session.lua
+	luaTouchSession string
+	//go:embed lua/rotate_refresh.lua
+	luaRotateRefresh string
+
+	ErrSessionNotFound = errors.New("会话不存在")
+	// ErrRefreshTokenReused 出示了已经用过的 refresh token，会话已经被整个撤销
+	ErrRefreshTokenReused = errors.New("refresh token 被重复使用")
+)
+
+// SessionCache 会话注册表
+// 每个会话（ssid）是一个 hash，记录设备、IP、登录时间等信息，另外每个用户有一个集合记录他所有的 ssid
+// 会话存在就代表有效，删除会话就能立刻让它失效
+type SessionCache interface {
+	// Create 登录时注册一个新的会话，refreshJTI 是这次登录签发的 refresh token 的 ID
+	Create(ctx context.Context, s domain.Session, refreshJTI string) error
+	// RotateRefresh 用 oldJTI 换成 newJTI，并延长会话的有效期，返回客户端应该使用的 refresh token 的 ID
+	// oldJTI 是刚刚被换掉的上一个 token 并且还在宽限期内时不再轮换，返回当前的 ID
+	// 其他情况下 oldJTI 不是当前有效的 refresh token 时撤销整个会话并返回 ErrRefreshTokenReused
+	RotateRefresh(ctx context.Context, uid int64, ssid, oldJTI, newJTI string) (string, error)
+	// Touch 检查会话是否有效，有效时更新最后访问时间
+	Touch(ctx context.Context, ssid string) (bool, error)
+	// TouchLegacy 检查上线会话注册表之前签发的会话是否有效
+	// 那时只在退出登录时写一个 users:Ssid:<ssid> 标记，没有这个标记就算有效
+	// 旧 token 没有 refresh token 的 ID，不能刷新，access token 全部过期之后可以删掉
+	TouchLegacy(ctx context.Context, ssid string) (bool, error)
+	// RevokeLegacy 让上线会话注册表之前签发的会话失效
+	RevokeLegacy(ctx context.Context, ssid string) error
+	// Revoke 让用户的某个会话失效，会话不属于该用户时返回 ErrSessionNotFound
+	Revoke(ctx context.Context, uid int64, ssid string) error
+	// RevokeAll 让用户所有的会话失效
+	RevokeAll(ctx context.Context, uid int64) error
+	// RevokeOthers 让用户除了 keep 之外的会话都失效
+	RevokeOthers(ctx context.Context, uid int64, keep string) error
+	// List 返回用户所有还没有失效的会话
+	List(ctx context.Context, uid int64) ([]domain.Session, error)
+}
+
+type RedisSessionCache struct {
+	cmd redis.Cmdable
+	// expiration 会话最长的有效期，也就是 refresh token 的有效期
+	expiration time.Duration
+	// refreshGrace 上一个 refresh token 在轮换之后还能使用的时长，容忍客户端并发刷新
+	refreshGrace time.Duration
+	// legacyExpiration 旧会话退出登录标记的有效期，旧 token 只有 access token 能用，有效期 24 小时
+	legacyExpiration time.Duration
+}
+
+func NewRedisSessionCache(cmd redis.Cmdable) SessionCache {
+	return &RedisSessionCache{
+		cmd:              cmd,
+		expiration:       time.Hour * 24 * 7,
+		refreshGrace:     time.Second * 30,
+		legacyExpiration: time.Hour * 24,
+	}
+}
+
+func (cache *RedisSessionCache) Create(ctx context.Context, s domain.Session, refreshJTI string) error {
+	key := cache.key(s.Ssid)
+	userKey := cache.userKey(s.UserID)
+	pipe := cache.cmd.TxPipeline()
+	pipe.HSet(ctx, key, map[string]any{
+		"uid":        s.UserID,
+		"device":     s.Device,
+		"ip":         s.IP,
+		"user_agent": s.UserAgent,
+		"login_time": s.LoginTime.UnixMilli(),
+		"last_seen":  s.LoginTime.UnixMilli(),
+		// 每个会话同时只有一个有效的 refresh token
+		"refresh_jti": refreshJTI,
+	})
+	pipe.Expire(ctx, key, cache.expiration)
+	pipe.SAdd(ctx, userKey, s.Ssid)
+	pipe.Expire(ctx, userKey, cache.expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (cache *RedisSessionCache) Touch(ctx context.Context, ssid string) (bool, error) {
+	res, err := cache.cmd.Eval(ctx, luaTouchSession, []string{cache.key(ssid)},
+		time.Now().UnixMilli()).Int()
+	return res == 1, err
+}
+
+func (cache *RedisSessionCache) TouchLegacy(ctx context.Context, ssid string) (bool, error) {
+	cnt, err := cache.cmd.Exists(ctx, cache.legacyRevokedKey(ssid)).Result()
+	return cnt == 0, err
+}
+
+func (cache *RedisSessionCache) RevokeLegacy(ctx context.Context, ssid string) error {
+	return cache.cmd.Set(ctx, cache.legacyRevokedKey(ssid), "", cache.legacyExpiration).Err()
+}
+
+func (cache *RedisSessionCache) RotateRefresh(ctx context.Context, uid int64, ssid, oldJTI, newJTI string) (string, error) {
+	res, err := cache.cmd.Eval(ctx, luaRotateRefresh,
+		[]string{cache.key(ssid), cache.userKey(uid)},
+		ssid, oldJTI, newJTI, int64(cache.expiration/time.Second),
+		time.Now().UnixMilli(), cache.refreshGrace.Milliseconds()).Slice()
+	if err != nil {
+		return "", err
+	}
+	if len(res) != 2 {
+		return "", fmt.Errorf("轮换 refresh token 的返回值不对 %v", res)
+	}
+	code, _ := res[0].(int64)
+	jti, _ := res[1].(string)
+	switch code {
+	case 1, 2:
+		return jti, nil
+	case -1:
+		return "", ErrRefreshTokenReused
+	default:
+		return "", ErrSessionNotFound
+	}
+}
+
+func (cache *RedisSessionCache) Revoke(ctx context.Context, uid int64, ssid string) error {
+	owner, err := cache.cmd.HGet(ctx, cache.key(ssid), "uid").Int64()
+	if errors.Is(err, redis.Nil) || (err == nil && owner != uid) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	pipe := cache.cmd.TxPipeline()
+	pipe.Del(ctx, cache.key(ssid))
+	pipe.SRem(ctx, cache.userKey(uid), ssid)
+	// 没有签发时间的旧 token 只认退出登录的标记，见 TouchLegacy
+	pipe.Set(ctx, cache.legacyRevokedKey(ssid), "", cache.legacyExpiration)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (cache *RedisSessionCache) RevokeAll(ctx context.Context, uid int64) error {
+	return cache.RevokeOthers(ctx, uid, "")
+}
+
+func (cache *RedisSessionCache) RevokeOthers(ctx context.Context, uid int64, keep string) error {
+	userKey := cache.userKey(uid)
+	ssids, err := cache.cmd.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+	pipe := cache.cmd.TxPipeline()
+	for _, ssid := range ssids {
+		if ssid == keep {
+			continue
+		}
+		pipe.Del(ctx, cache.key(ssid))
+		pipe.SRem(ctx, userKey, ssid)
+		// 集合里可能还有没有签发时间的旧 token，它们只认退出登录的标记
+		pipe.Set(ctx, cache.legacyRevokedKey(ssid), "", cache.legacyExpiration)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (cache *RedisSessionCache) List(ctx context.Context, uid int64) ([]domain.Session, error) {
+	userKey := cache.userKey(uid)
+	ssids, err := cache.cmd.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	pipe := cache.cmd.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ssids))
+	for i, ssid := range ssids {
+		cmds[i] = pipe.HGetAll(ctx, cache.key(ssid))
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	res := make([]domain.Session, 0, len(ssids))
+	var expired []any
+	for i, ssid := range ssids {
+		vals := cmds[i].Val()
+		if len(vals) == 0 {
+			// 会话已经过期，顺便从集合里清理掉
+			expired = append(expired, ssid)
+			continue
+		}
+		res = append(res, cache.toSession(uid, ssid, vals))
+	}
+	if len(expired) > 0 {
+		if err = cache.cmd.SRem(ctx, userKey, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (cache *RedisSessionCache) toSession(uid int64, ssid string, vals map[string]string) domain.Session {
+	loginTime, _ := strconv.ParseInt(vals["login_time"], 10, 64)
+	lastSeen, _ := strconv.ParseInt(vals["last_seen"], 10, 64)
+	return domain.Session{
+		Ssid:      ssid,
+		UserID:    uid,
+		Device:    vals["device"],
+		IP:        vals["ip"],
+		UserAgent: vals["user_agent"],
+		LoginTime: time.UnixMilli(loginTime),
+		LastSeen:  time.UnixMilli(lastSeen),
+	}
+}
+
+// legacyRevokedKey 上线注册表之前退出登录的会话，和原来 CheckSession 检查的 key 一样
+func (cache *RedisSessionCache) legacyRevokedKey(ssid string) string {
+	return fmt.Sprintf("users:Ssid:%s", ssid)
+}
+
+func (cache *RedisSessionCache) key(ssid string) string {
+	return fmt.Sprintf("users:session:%s", ssid)
+}
+
+func (cache *RedisSessionCache) userKey(uid int64) string {
+	return fmt.Sprintf("users:Ssids:%d", uid)
+}
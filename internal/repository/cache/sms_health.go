@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/record_sms_failure.lua
+var luaRecordSMSFailure string
+
+// SMSHealthPolicy 短信服务商连续失败之后的熔断策略
+type SMSHealthPolicy struct {
+	// Window 统计连续失败的窗口
+	Window time.Duration
+	// Threshold 连续失败多少次之后标记为不可用
+	Threshold int
+	// Cooldown 标记为不可用多久，过了之后重新尝试
+	Cooldown time.Duration
+}
+
+// SMSHealthCache 在 Redis 中保存短信服务商是否可用，所有实例共享
+type SMSHealthCache interface {
+	// RecordFailure 记录一次失败，返回 true 表示这次失败导致服务商被标记为不可用
+	RecordFailure(ctx context.Context, provider string, p SMSHealthPolicy) (bool, error)
+	// RecordSuccess 清零连续失败的次数
+	RecordSuccess(ctx context.Context, provider string) error
+	// Down 按顺序返回服务商是否被标记为不可用
+	Down(ctx context.Context, providers []string) ([]bool, error)
+}
+
+type RedisSMSHealthCache struct {
+	cmd redis.Cmdable
+}
+
+func NewRedisSMSHealthCache(cmd redis.Cmdable) SMSHealthCache {
+	return &RedisSMSHealthCache{
+		cmd: cmd,
+	}
+}
+
+func (cache *RedisSMSHealthCache) RecordFailure(ctx context.Context, provider string, p SMSHealthPolicy) (bool, error) {
+	res, err := cache.cmd.Eval(ctx, luaRecordSMSFailure,
+		[]string{cache.failKey(provider), cache.downKey(provider)},
+		p.Window.Milliseconds(), p.Threshold, p.Cooldown.Milliseconds()).Int()
+	return res == 1, err
+}
+
+func (cache *RedisSMSHealthCache) RecordSuccess(ctx context.Context, provider string) error {
+	return cache.cmd.Del(ctx, cache.failKey(provider)).Err()
+}
+
+func (cache *RedisSMSHealthCache) Down(ctx context.Context, providers []string) ([]bool, error) {
+	if len(providers) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(providers))
+	for _, p := range providers {
+		keys = append(keys, cache.downKey(p))
+	}
+	vals, err := cache.cmd.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != len(providers) {
+		return nil, errors.New("查询短信服务商状态的返回值不对")
+	}
+	res := make([]bool, len(vals))
+	for i, val := range vals {
+		res[i] = val != nil
+	}
+	return res, nil
+}
+
+func (cache *RedisSMSHealthCache) failKey(provider string) string {
+	return fmt.Sprintf("sms:provider:%s:failures", provider)
+}
+
+func (cache *RedisSMSHealthCache) downKey(provider string) string {
+	return fmt.Sprintf("sms:provider:%s:down", provider)
+}
@@ -13,6 +13,23 @@ import (
 type DailySummaryRepository interface {
 	FindByUserIDAndDate(ctx context.Context, biz string, userID int64, date time.Time) (domain.DailySummary, error)
 	FindByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (domain.DailySummary, error)
+	// ListByUserIDAndDateRange 按日期升序返回区间内每一天的汇总数据，没有训练的日期不会出现在结果中
+	ListByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) ([]domain.DailySummary, error)
+	// AggregateByDateRange 汇总区间内所有用户的数据
+	AggregateByDateRange(ctx context.Context, startDate, endDate time.Time) (domain.DailySummary, error)
+	// MaxByUserIDBefore 返回 before 之前单日各项数据的最大值，只有时长、挥拍次数和速度有意义
+	MaxByUserIDBefore(ctx context.Context, userID int64, before time.Time) (domain.DailySummary, error)
+	FindUserIDsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]int64, error)
+	CountByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (int64, error)
+	// ExportColumns 导出时使用的列名，与数据库中的列名一致
+	ExportColumns() []string
+	// StreamByUserIDAndDateRange 逐行导出原始数据，values 与 ExportColumns 的顺序一致
+	StreamByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time, fn func(values []any) error) error
+	// UpsertBatch 批量写入，同一天已有的数据只覆盖 columns 中的列
+	// biz 与 FindByUserIDAndDate 的 biz 一致，用于清理缓存
+	UpsertBatch(ctx context.Context, biz string, summaries []domain.DailySummary, columns []string) error
+	// DeleteCacheByUserID 删除某个用户所有的缓存，数据库中的数据由注销流程统一删除
+	DeleteCacheByUserID(ctx context.Context, userID int64) error
 }
 
 type dailySummaryRepository struct {
@@ -54,6 +71,83 @@ func (r *dailySummaryRepository) FindByUserIDAndDateRange(ctx context.Context, u
 	return aggDomainSummary, nil
 }
 
+func (r *dailySummaryRepository) ListByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) ([]domain.DailySummary, error) {
+	summaries, err := r.dao.ListByUserIDAndDateRange(ctx, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.DailySummary, 0, len(summaries))
+	for _, ds := range summaries {
+		res = append(res, r.entityToDomain(ds))
+	}
+	return res, nil
+}
+
+func (r *dailySummaryRepository) AggregateByDateRange(ctx context.Context, startDate, endDate time.Time) (domain.DailySummary, error) {
+	ds, err := r.cache.GetAggregate(ctx, startDate, endDate)
+	switch {
+	case err == nil:
+		return ds, nil
+	case errors.Is(err, redis.Nil):
+		aggSummary, err := r.dao.AggregateByDateRange(ctx, startDate, endDate)
+		if err != nil {
+			return domain.DailySummary{}, err
+		}
+		ds = r.entityToDomain(aggSummary)
+		_ = r.cache.SetAggregate(ctx, startDate, endDate, ds)
+		return ds, nil
+	default:
+		// Redis 不可用时不回源，避免所有请求都去扫描整个区间
+		return domain.DailySummary{}, err
+	}
+}
+
+func (r *dailySummaryRepository) MaxByUserIDBefore(ctx context.Context, userID int64, before time.Time) (domain.DailySummary, error) {
+	ds, err := r.dao.MaxByUserIDBefore(ctx, userID, before)
+	if err != nil {
+		return domain.DailySummary{}, err
+	}
+	return r.entityToDomain(ds), nil
+}
+
+func (r *dailySummaryRepository) FindUserIDsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]int64, error) {
+	return r.dao.FindUserIDsByDateRange(ctx, startDate, endDate)
+}
+
+func (r *dailySummaryRepository) CountByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (int64, error) {
+	return r.dao.CountByUserIDAndDateRange(ctx, userID, startDate, endDate)
+}
+
+func (r *dailySummaryRepository) ExportColumns() []string {
+	return dao.DailySummaryColumns()
+}
+
+func (r *dailySummaryRepository) StreamByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time, fn func(values []any) error) error {
+	return r.dao.StreamByUserIDAndDateRange(ctx, userID, startDate, endDate, func(ds dao.DailySummary) error {
+		return fn(ds.ColumnValues())
+	})
+}
+
+func (r *dailySummaryRepository) UpsertBatch(ctx context.Context, biz string, summaries []domain.DailySummary, columns []string) error {
+	entities := make([]dao.DailySummary, 0, len(summaries))
+	for _, ds := range summaries {
+		entities = append(entities, r.domainToEntity(ds))
+	}
+	err := r.dao.UpsertBatch(ctx, entities, columns)
+	if err != nil {
+		return err
+	}
+	// 覆盖写入之后缓存中的数据就过期了
+	for _, ds := range summaries {
+		_ = r.cache.Delete(ctx, biz, ds.UserID, ds.Date)
+	}
+	return nil
+}
+
+func (r *dailySummaryRepository) DeleteCacheByUserID(ctx context.Context, userID int64) error {
+	return r.cache.DeleteByUser(ctx, userID)
+}
+
 func (r *dailySummaryRepository) domainToEntity(d domain.DailySummary) dao.DailySummary {
 	return dao.DailySummary{
 		ID:                   d.ID,
@@ -78,8 +172,8 @@ func (r *dailySummaryRepository) domainToEntity(d domain.DailySummary) dao.Daily
 		BackhandDrive: d.BackhandDrive,
 		PickupCount:   d.PickupCount,
 
-		Ctime: d.CreatedAt.Unix(),
-		Utime: d.UpdatedAt.Unix(),
+		Ctime: d.CreatedAt.UnixMilli(),
+		Utime: d.UpdatedAt.UnixMilli(),
 	}
 }
 
@@ -105,7 +199,7 @@ func (r *dailySummaryRepository) entityToDomain(ds dao.DailySummary) domain.Dail
 		ForehandDrive: ds.ForehandDrive,
 		BackhandDrive: ds.BackhandDrive,
 		PickupCount:   ds.PickupCount,
-		CreatedAt:     time.Unix(ds.Ctime, 0),
-		UpdatedAt:     time.Unix(ds.Utime, 0),
+		CreatedAt:     time.UnixMilli(ds.Ctime),
+		UpdatedAt:     time.UnixMilli(ds.Utime),
 	}
 }
@@ -3,13 +3,85 @@ package dao
 import (
 	"context"
 	"errors"
+	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"strings"
 	"time"
 )
 
 type DailySummaryDAO interface {
 	FindByUserIDAndDate(ctx context.Context, userID int64, date time.Time) (DailySummary, error)
 	AggregateByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (DailySummary, error)
+	ListByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) ([]DailySummary, error)
+	// AggregateByDateRange 汇总区间内所有用户的数据
+	AggregateByDateRange(ctx context.Context, startDate, endDate time.Time) (DailySummary, error)
+	// MaxByUserIDBefore 返回 before 之前单日各项数据的最大值
+	MaxByUserIDBefore(ctx context.Context, userID int64, before time.Time) (DailySummary, error)
+	// FindUserIDsByDateRange 返回区间内有训练数据的用户
+	FindUserIDsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]int64, error)
+	CountByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (int64, error)
+	// StreamByUserIDAndDateRange 逐行读取区间内的数据并交给 fn 处理，不会把结果一次性加载到内存
+	// fn 返回 error 时停止读取
+	StreamByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time, fn func(ds DailySummary) error) error
+	// UpsertBatch 按 (用户, 日期) 批量写入，已经存在的数据只覆盖 columns 中的列，不会累加
+	// 没有列出的列保留原来的值，避免只包含部分字段的数据把其他字段清零
+	UpsertBatch(ctx context.Context, summaries []DailySummary, columns []string) error
+}
+
+// aggregateColumns 区间汇总时使用的字段，速度取最大值，其余取累加值
+var aggregateColumns = []string{
+	"SUM(total_duration_seconds) as total_duration_seconds",
+	"MAX(max_swing_speed) as max_swing_speed",
+	"SUM(total_swings) as total_swings",
+	"SUM(racket_rotation_count) as racket_rotation_count",
+	"SUM(forehand_clear) as forehand_clear",
+	"SUM(backhand_clear) as backhand_clear",
+	"SUM(forehand_lift) as forehand_lift",
+	"SUM(backhand_lift) as backhand_lift",
+	"SUM(forehand_net) as forehand_net",
+	"SUM(backhand_net) as backhand_net",
+	"SUM(forehand_smash) as forehand_smash",
+	"SUM(backhand_smash) as backhand_smash",
+	"SUM(forehand_drop) as forehand_drop",
+	"SUM(backhand_drop) as backhand_drop",
+	"SUM(forehand_drive) as forehand_drive",
+	"SUM(backhand_drive) as backhand_drive",
+	"SUM(pickup_count) as pickup_count",
+}
+
+// mergeDailySummaries 把 fromID 的每日汇总合并到 toID 中
+// 同一天两个账号都有数据时按 aggregateColumns 的规则合并，其余的直接转给 toID
+// 需要在事务中调用
+func mergeDailySummaries(tx *gorm.DB, toID, fromID int64) error {
+	assignments := make([]string, 0, len(aggregateColumns))
+	for _, col := range aggregateColumns {
+		fn, rest, _ := strings.Cut(col, "(")
+		name, _, _ := strings.Cut(rest, ")")
+		if fn == "MAX" {
+			assignments = append(assignments, fmt.Sprintf("t.%s = GREATEST(t.%s, f.%s)", name, name, name))
+		} else {
+			assignments = append(assignments, fmt.Sprintf("t.%s = t.%s + f.%s", name, name, name))
+		}
+	}
+	now := time.Now().UnixMilli()
+	err := tx.Exec("UPDATE daily_summary t JOIN daily_summary f ON t.summary_date = f.summary_date "+
+		"SET "+strings.Join(assignments, ", ")+", t.utime = ? "+
+		"WHERE t.user_id = ? AND f.user_id = ?", now, toID, fromID).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Exec("DELETE f FROM daily_summary f JOIN daily_summary t ON t.summary_date = f.summary_date "+
+		"WHERE f.user_id = ? AND t.user_id = ?", fromID, toID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&DailySummary{}).Where("user_id = ?", fromID).
+		Updates(map[string]any{
+			"user_id": toID,
+			"utime":   now,
+		}).Error
 }
 
 type GormDailySummaryDAO struct {
@@ -57,25 +129,7 @@ func (d *GormDailySummaryDAO) AggregateByUserIDAndDateRange(ctx context.Context,
 	var result DailySummary
 	err := d.db.WithContext(ctx).
 		Model(&DailySummary{}).
-		Select([]string{
-			"SUM(total_duration_seconds) as total_duration_seconds",
-			"MAX(max_swing_speed) as max_swing_speed",
-			"SUM(total_swings) as total_swings",
-			"SUM(racket_rotation_count) as racket_rotation_count",
-			"SUM(forehand_clear) as forehand_clear",
-			"SUM(backhand_clear) as backhand_clear",
-			"SUM(forehand_lift) as forehand_lift",
-			"SUM(backhand_lift) as backhand_lift",
-			"SUM(forehand_net) as forehand_net",
-			"SUM(backhand_net) as backhand_net",
-			"SUM(forehand_smash) as forehand_smash",
-			"SUM(backhand_smash) as backhand_smash",
-			"SUM(forehand_drop) as forehand_drop",
-			"SUM(backhand_drop) as backhand_drop",
-			"SUM(forehand_drive) as forehand_drive",
-			"SUM(backhand_drive) as backhand_drive",
-			"SUM(pickup_count) as pickup_count",
-		}).
+		Select(aggregateColumns).
 		Where("user_id = ? AND summary_date BETWEEN ? AND ?", userID, startDate, endDate).
 		Scan(&result).Error
 	if err != nil {
@@ -84,14 +138,135 @@ func (d *GormDailySummaryDAO) AggregateByUserIDAndDateRange(ctx context.Context,
 	return result, err
 }
 
+func (d *GormDailySummaryDAO) ListByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) ([]DailySummary, error) {
+	var res []DailySummary
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND summary_date BETWEEN ? AND ?", userID, startDate, endDate).
+		Order("summary_date ASC").
+		Find(&res).Error
+	return res, err
+}
+
+func (d *GormDailySummaryDAO) AggregateByDateRange(ctx context.Context, startDate, endDate time.Time) (DailySummary, error) {
+	var result DailySummary
+	err := d.db.WithContext(ctx).
+		Model(&DailySummary{}).
+		Select(aggregateColumns).
+		Where("summary_date BETWEEN ? AND ?", startDate, endDate).
+		Scan(&result).Error
+	return result, err
+}
+
+func (d *GormDailySummaryDAO) MaxByUserIDBefore(ctx context.Context, userID int64, before time.Time) (DailySummary, error) {
+	var result DailySummary
+	err := d.db.WithContext(ctx).
+		Model(&DailySummary{}).
+		Select([]string{
+			"MAX(total_duration_seconds) as total_duration_seconds",
+			"MAX(max_swing_speed) as max_swing_speed",
+			"MAX(total_swings) as total_swings",
+		}).
+		Where("user_id = ? AND summary_date < ?", userID, before).
+		Scan(&result).Error
+	return result, err
+}
+
+func (d *GormDailySummaryDAO) FindUserIDsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]int64, error) {
+	var ids []int64
+	err := d.db.WithContext(ctx).
+		Model(&DailySummary{}).
+		Distinct("user_id").
+		Where("summary_date BETWEEN ? AND ?", startDate, endDate).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (d *GormDailySummaryDAO) CountByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (int64, error) {
+	var cnt int64
+	err := d.db.WithContext(ctx).
+		Model(&DailySummary{}).
+		Where("user_id = ? AND summary_date BETWEEN ? AND ?", userID, startDate, endDate).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+func (d *GormDailySummaryDAO) StreamByUserIDAndDateRange(ctx context.Context, userID int64, startDate, endDate time.Time, fn func(ds DailySummary) error) error {
+	rows, err := d.db.WithContext(ctx).
+		Model(&DailySummary{}).
+		Where("user_id = ? AND summary_date BETWEEN ? AND ?", userID, startDate, endDate).
+		Order("summary_date ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ds DailySummary
+		if err = d.db.ScanRows(rows, &ds); err != nil {
+			return err
+		}
+		if err = fn(ds); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (d *GormDailySummaryDAO) UpsertBatch(ctx context.Context, summaries []DailySummary, columns []string) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	for i := range summaries {
+		summaries[i].Ctime = now
+		summaries[i].Utime = now
+	}
+	updates := make([]string, 0, len(columns)+1)
+	updates = append(updates, columns...)
+	updates = append(updates, "utime")
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns(updates),
+	}).Create(&summaries).Error
+}
+
+// DailySummaryColumns 按字段顺序返回 DailySummary 的列名，与 gorm 标签中的 column 保持一致
+func DailySummaryColumns() []string {
+	t := reflect.TypeOf(DailySummary{})
+	res := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		res = append(res, columnName(t.Field(i)))
+	}
+	return res
+}
+
+// ColumnValues 按 DailySummaryColumns 的顺序返回各列的值
+func (ds DailySummary) ColumnValues() []any {
+	v := reflect.ValueOf(ds)
+	res := make([]any, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		res = append(res, v.Field(i).Interface())
+	}
+	return res
+}
+
+// columnName 解析 gorm 标签中的 column，没有标签时使用字段名
+func columnName(f reflect.StructField) string {
+	for _, part := range strings.Split(f.Tag.Get("gorm"), ";") {
+		if name, ok := strings.CutPrefix(part, "column:"); ok {
+			return name
+		}
+	}
+	return f.Name
+}
+
 type DailySummary struct {
-	ID                   int64     `gorm:"column:id;primaryKey;autoIncrement"` // 主键
-	UserID               int64     `gorm:"column:user_id"`                     // 用户ID
-	SummaryDate          time.Time `gorm:"column:summary_date;type:date"`      // 汇总日期（格式为 yyyy-MM-dd）
-	TotalDurationSeconds int       `gorm:"column:total_duration_seconds"`      // 训练总时长（秒）
-	MaxSwingSpeed        int       `gorm:"column:max_swing_speed"`             // 最大挥拍速度
-	TotalSwings          int       `gorm:"column:total_swings"`                // 总挥拍次数
-	RacketRotationCount  int       `gorm:"column:racket_rotation_count"`       // 转球拍次数
+	ID                   int64     `gorm:"column:id;primaryKey;autoIncrement"`                     // 主键
+	UserID               int64     `gorm:"column:user_id;uniqueIndex:uk_user_date"`                // 用户ID
+	SummaryDate          time.Time `gorm:"column:summary_date;type:date;uniqueIndex:uk_user_date"` // 汇总日期（格式为 yyyy-MM-dd）
+	TotalDurationSeconds int       `gorm:"column:total_duration_seconds"`                          // 训练总时长（秒）
+	MaxSwingSpeed        int       `gorm:"column:max_swing_speed"`                                 // 最大挥拍速度
+	TotalSwings          int       `gorm:"column:total_swings"`                                    // 总挥拍次数
+	RacketRotationCount  int       `gorm:"column:racket_rotation_count"`                           // 转球拍次数
 
 	ForehandClear int `gorm:"column:forehand_clear"` // 正手高远球
 	BackhandClear int `gorm:"column:backhand_clear"` // 反手高远球
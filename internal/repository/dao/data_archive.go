@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"time"
+)
+
+type DataArchiveDAO interface {
+	Insert(ctx context.Context, a DataArchive) (int64, error)
+	FindById(ctx context.Context, id int64) (DataArchive, error)
+	FindByToken(ctx context.Context, token string) (DataArchive, error)
+	// UpdateNonZeroFields 更新状态、文件路径、下载凭证等字段
+	UpdateNonZeroFields(ctx context.Context, a DataArchive) error
+	// FindExpired 查找在 before（毫秒时间戳）之前过期的压缩包
+	FindExpired(ctx context.Context, before int64, limit int) ([]DataArchive, error)
+	Delete(ctx context.Context, id int64) error
+	// FailStale 把 before 之前创建、仍然没有结束的任务标记为失败，返回修改的行数
+	FailStale(ctx context.Context, before int64, errMsg string) (int64, error)
+}
+
+type GormDataArchiveDAO struct {
+	db *gorm.DB
+}
+
+func NewGormDataArchiveDAO(db *gorm.DB) DataArchiveDAO {
+	return &GormDataArchiveDAO{
+		db: db,
+	}
+}
+
+func (d *GormDataArchiveDAO) Insert(ctx context.Context, a DataArchive) (int64, error) {
+	now := time.Now().UnixMilli()
+	a.Ctime = now
+	a.Utime = now
+	err := d.db.WithContext(ctx).Create(&a).Error
+	return a.Id, err
+}
+
+func (d *GormDataArchiveDAO) FindById(ctx context.Context, id int64) (DataArchive, error) {
+	var a DataArchive
+	err := d.db.WithContext(ctx).First(&a, "id = ?", id).Error
+	return a, err
+}
+
+func (d *GormDataArchiveDAO) FindByToken(ctx context.Context, token string) (DataArchive, error) {
+	var a DataArchive
+	err := d.db.WithContext(ctx).First(&a, "token = ?", token).Error
+	return a, err
+}
+
+func (d *GormDataArchiveDAO) UpdateNonZeroFields(ctx context.Context, a DataArchive) error {
+	a.Utime = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Updates(&a).Error
+}
+
+func (d *GormDataArchiveDAO) FindExpired(ctx context.Context, before int64, limit int) ([]DataArchive, error) {
+	var res []DataArchive
+	err := d.db.WithContext(ctx).
+		Where("expire_at > 0 AND expire_at < ?", before).
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (d *GormDataArchiveDAO) Delete(ctx context.Context, id int64) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&DataArchive{}).Error
+}
+
+func (d *GormDataArchiveDAO) FailStale(ctx context.Context, before int64, errMsg string) (int64, error) {
+	res := d.db.WithContext(ctx).Model(&DataArchive{}).
+		Where("status IN ? AND ctime < ?", []string{"pending", "running"}, before).
+		Updates(map[string]any{
+			"status":  "failed",
+			"err_msg": errMsg,
+			"utime":   time.Now().UnixMilli(),
+		})
+	return res.RowsAffected, res.Error
+}
+
+type DataArchive struct {
+	Id       int64          `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID   int64          `gorm:"column:user_id;index"`
+	Status   string         `gorm:"column:status;type:varchar(16)"`
+	FilePath string         `gorm:"column:file_path;type:varchar(512)"`
+	Token    sql.NullString `gorm:"column:token;type:varchar(64);unique"`
+	ExpireAt int64          `gorm:"column:expire_at;index"`
+	ErrMsg   string         `gorm:"column:err_msg;type:varchar(1024)"`
+	Ctime    int64          `gorm:"column:ctime"`
+	Utime    int64          `gorm:"column:utime"`
+}
+
+func (DataArchive) TableName() string {
+	return "data_archive"
+}
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type ExportJobDAO interface {
+	Insert(ctx context.Context, j ExportJob) (int64, error)
+	FindById(ctx context.Context, id int64) (ExportJob, error)
+	// UpdateNonZeroFields 更新任务状态、行数、文件路径等字段
+	UpdateNonZeroFields(ctx context.Context, j ExportJob) error
+	// FailStale 把 before 之前创建、仍然没有结束的任务标记为失败，返回修改的行数
+	FailStale(ctx context.Context, before int64, errMsg string) (int64, error)
+}
+
+type GormExportJobDAO struct {
+	db *gorm.DB
+}
+
+func NewGormExportJobDAO(db *gorm.DB) ExportJobDAO {
+	return &GormExportJobDAO{
+		db: db,
+	}
+}
+
+func (d *GormExportJobDAO) Insert(ctx context.Context, j ExportJob) (int64, error) {
+	now := time.Now().UnixMilli()
+	j.Ctime = now
+	j.Utime = now
+	err := d.db.WithContext(ctx).Create(&j).Error
+	return j.Id, err
+}
+
+func (d *GormExportJobDAO) FindById(ctx context.Context, id int64) (ExportJob, error) {
+	var j ExportJob
+	err := d.db.WithContext(ctx).First(&j, "id = ?", id).Error
+	return j, err
+}
+
+func (d *GormExportJobDAO) UpdateNonZeroFields(ctx context.Context, j ExportJob) error {
+	j.Utime = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Updates(&j).Error
+}
+
+func (d *GormExportJobDAO) FailStale(ctx context.Context, before int64, errMsg string) (int64, error) {
+	res := d.db.WithContext(ctx).Model(&ExportJob{}).
+		Where("status IN ? AND ctime < ?", []string{"pending", "running"}, before).
+		Updates(map[string]any{
+			"status":  "failed",
+			"err_msg": errMsg,
+			"utime":   time.Now().UnixMilli(),
+		})
+	return res.RowsAffected, res.Error
+}
+
+type ExportJob struct {
+	Id        int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"column:user_id;index"`
+	Format    string    `gorm:"column:format;type:varchar(16)"`
+	StartDate time.Time `gorm:"column:start_date;type:date"`
+	EndDate   time.Time `gorm:"column:end_date;type:date"`
+	Status    string    `gorm:"column:status;type:varchar(16)"`
+	RowCount  int64     `gorm:"column:row_count"`
+	FilePath  string    `gorm:"column:file_path;type:varchar(512)"`
+	ErrMsg    string    `gorm:"column:err_msg;type:varchar(1024)"`
+	Ctime     int64     `gorm:"column:ctime"`
+	Utime     int64     `gorm:"column:utime"`
+}
+
+func (ExportJob) TableName() string {
+	return "export_job"
+}
@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type ImportJobDAO interface {
+	Insert(ctx context.Context, j ImportJob) (int64, error)
+	FindById(ctx context.Context, id int64) (ImportJob, error)
+	// FindByFileHash 查找同一个用户上传过的同一个文件中最近的一次导入
+	FindByFileHash(ctx context.Context, userID int64, fileHash string) (ImportJob, error)
+	UpdateNonZeroFields(ctx context.Context, j ImportJob) error
+	// FailStale 把 before 之前创建、仍然没有结束的任务标记为失败，返回修改的行数
+	FailStale(ctx context.Context, before int64, errMsg string) (int64, error)
+}
+
+type GormImportJobDAO struct {
+	db *gorm.DB
+}
+
+func NewGormImportJobDAO(db *gorm.DB) ImportJobDAO {
+	return &GormImportJobDAO{
+		db: db,
+	}
+}
+
+func (d *GormImportJobDAO) Insert(ctx context.Context, j ImportJob) (int64, error) {
+	now := time.Now().UnixMilli()
+	j.Ctime = now
+	j.Utime = now
+	err := d.db.WithContext(ctx).Create(&j).Error
+	return j.Id, err
+}
+
+func (d *GormImportJobDAO) FindById(ctx context.Context, id int64) (ImportJob, error) {
+	var j ImportJob
+	err := d.db.WithContext(ctx).First(&j, "id = ?", id).Error
+	return j, err
+}
+
+func (d *GormImportJobDAO) FindByFileHash(ctx context.Context, userID int64, fileHash string) (ImportJob, error) {
+	var j ImportJob
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND file_hash = ?", userID, fileHash).
+		Order("id DESC").
+		First(&j).Error
+	return j, err
+}
+
+func (d *GormImportJobDAO) UpdateNonZeroFields(ctx context.Context, j ImportJob) error {
+	j.Utime = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Updates(&j).Error
+}
+
+func (d *GormImportJobDAO) FailStale(ctx context.Context, before int64, errMsg string) (int64, error) {
+	res := d.db.WithContext(ctx).Model(&ImportJob{}).
+		Where("status IN ? AND ctime < ?", []string{"pending", "running"}, before).
+		Updates(map[string]any{
+			"status":  "failed",
+			"err_msg": errMsg,
+			"utime":   time.Now().UnixMilli(),
+		})
+	return res.RowsAffected, res.Error
+}
+
+type ImportJob struct {
+	Id          int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID      int64  `gorm:"column:user_id;index:idx_user_hash"`
+	Source      string `gorm:"column:source;type:varchar(64)"`
+	Format      string `gorm:"column:format;type:varchar(16)"`
+	FileHash    string `gorm:"column:file_hash;type:char(64);index:idx_user_hash"`
+	FilePath    string `gorm:"column:file_path;type:varchar(512)"`
+	Status      string `gorm:"column:status;type:varchar(16)"`
+	TotalRows   int    `gorm:"column:total_rows"`
+	SuccessRows int    `gorm:"column:success_rows"`
+	FailedRows  int    `gorm:"column:failed_rows"`
+	Errors      string `gorm:"column:errors;type:mediumtext"` // 行错误列表，JSON 格式
+	ErrMsg      string `gorm:"column:err_msg;type:varchar(1024)"`
+	Ctime       int64  `gorm:"column:ctime"`
+	Utime       int64  `gorm:"column:utime"`
+}
+
+func (ImportJob) TableName() string {
+	return "import_job"
+}
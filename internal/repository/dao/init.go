@@ -1,5 +1,9 @@
 package dao
 
-//func InitTables(db *gorm.DB) error {
-//	return db.AutoMigrate(&User{})
-//}
+import "gorm.io/gorm"
+
+// InitTables 用 AutoMigrate 建表，只在开发环境使用
+// 已有数据的库按 script/mysql/migration 中的脚本升级
+func InitTables(db *gorm.DB) error {
+	return db.AutoMigrate(&User{}, &DailySummary{}, &SwingSpeedStat{}, &TrainingReport{}, &ExportJob{}, &ImportJob{}, &LoginHistory{}, &DataArchive{}, &UserIdentity{}, &UserTOTP{}, &UserRecoveryCode{}, &SMSTask{})
+}
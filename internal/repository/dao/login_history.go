@@ -0,0 +1,52 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type LoginHistoryDAO interface {
+	Insert(ctx context.Context, r LoginHistory) error
+	// ListByUserID 按登录时间倒序返回
+	ListByUserID(ctx context.Context, userID int64, offset, limit int) ([]LoginHistory, error)
+}
+
+type GormLoginHistoryDAO struct {
+	db *gorm.DB
+}
+
+func NewGormLoginHistoryDAO(db *gorm.DB) LoginHistoryDAO {
+	return &GormLoginHistoryDAO{
+		db: db,
+	}
+}
+
+func (d *GormLoginHistoryDAO) Insert(ctx context.Context, r LoginHistory) error {
+	r.Ctime = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Create(&r).Error
+}
+
+func (d *GormLoginHistoryDAO) ListByUserID(ctx context.Context, userID int64, offset, limit int) ([]LoginHistory, error) {
+	var res []LoginHistory
+	err := d.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Offset(offset).Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+type LoginHistory struct {
+	Id        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID    int64  `gorm:"column:user_id;index"`
+	Ssid      string `gorm:"column:ssid;type:varchar(64)"`
+	Method    string `gorm:"column:method;type:varchar(16)"`
+	IP        string `gorm:"column:ip;type:varchar(64)"`
+	UserAgent string `gorm:"column:user_agent;type:varchar(512)"`
+	Ctime     int64  `gorm:"column:ctime"`
+}
+
+func (LoginHistory) TableName() string {
+	return "login_history"
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SMSTaskDAO interface {
+	Insert(ctx context.Context, t SMSTask) (int64, error)
+	// FindDue 查找到了重试时间的任务
+	FindDue(ctx context.Context, now int64, limit int) ([]SMSTask, error)
+	// Claim 抢占任务，把下一次重试时间推后到 leaseUntil。多个实例同时抢的时候只有一个返回 true
+	// 抢到的实例崩溃的话，过了 leaseUntil 任务会被重新抢占
+	Claim(ctx context.Context, id, oldNextRetryTime, leaseUntil int64) (bool, error)
+	// UpdateResult 记录一次发送的结果
+	UpdateResult(ctx context.Context, id int64, status string, nextRetryTime int64, lastErr string) error
+	ListByStatus(ctx context.Context, status string, offset, limit int) ([]SMSTask, error)
+	// Requeue 把死信重新放回队列，返回 false 说明任务不是死信
+	Requeue(ctx context.Context, id int64, deadline int64) (bool, error)
+}
+
+type GormSMSTaskDAO struct {
+	db *gorm.DB
+}
+
+func NewGormSMSTaskDAO(db *gorm.DB) SMSTaskDAO {
+	return &GormSMSTaskDAO{
+		db: db,
+	}
+}
+
+func (d *GormSMSTaskDAO) Insert(ctx context.Context, t SMSTask) (int64, error) {
+	now := time.Now().UnixMilli()
+	t.Ctime = now
+	t.Utime = now
+	err := d.db.WithContext(ctx).Create(&t).Error
+	return t.Id, err
+}
+
+func (d *GormSMSTaskDAO) FindDue(ctx context.Context, now int64, limit int) ([]SMSTask, error) {
+	var res []SMSTask
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND next_retry_time <= ?", "pending", now).
+		Order("next_retry_time").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (d *GormSMSTaskDAO) Claim(ctx context.Context, id, oldNextRetryTime, leaseUntil int64) (bool, error) {
+	res := d.db.WithContext(ctx).Model(&SMSTask{}).
+		Where("id = ? AND status = ? AND next_retry_time = ?", id, "pending", oldNextRetryTime).
+		Updates(map[string]any{
+			"next_retry_time": leaseUntil,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"utime":           time.Now().UnixMilli(),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (d *GormSMSTaskDAO) UpdateResult(ctx context.Context, id int64, status string, nextRetryTime int64, lastErr string) error {
+	return d.db.WithContext(ctx).Model(&SMSTask{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status":          status,
+			"next_retry_time": nextRetryTime,
+			"last_err":        lastErr,
+			"utime":           time.Now().UnixMilli(),
+		}).Error
+}
+
+func (d *GormSMSTaskDAO) ListByStatus(ctx context.Context, status string, offset, limit int) ([]SMSTask, error) {
+	var res []SMSTask
+	err := d.db.WithContext(ctx).Where("status = ?", status).
+		Order("id DESC").Offset(offset).Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (d *GormSMSTaskDAO) Requeue(ctx context.Context, id int64, deadline int64) (bool, error) {
+	now := time.Now().UnixMilli()
+	res := d.db.WithContext(ctx).Model(&SMSTask{}).
+		Where("id = ? AND status = ?", id, "dead").
+		Updates(map[string]any{
+			"status":          "pending",
+			"attempts":        0,
+			"next_retry_time": now,
+			"deadline":        deadline,
+			"utime":           now,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+type SMSTask struct {
+	Id    int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	TplId string `gorm:"column:tpl_id;type:varchar(64)"`
+	// Args、Numbers 是 JSON 数组
+	Args          string `gorm:"column:args;type:varchar(1024)"`
+	Numbers       string `gorm:"column:numbers;type:varchar(1024)"`
+	Status        string `gorm:"column:status;type:varchar(16);index:idx_status_next_retry,priority:1"`
+	Attempts      int    `gorm:"column:attempts"`
+	NextRetryTime int64  `gorm:"column:next_retry_time;index:idx_status_next_retry,priority:2"`
+	Deadline      int64  `gorm:"column:deadline"`
+	LastErr       string `gorm:"column:last_err;type:varchar(1024)"`
+	Ctime         int64  `gorm:"column:ctime"`
+	Utime         int64  `gorm:"column:utime"`
+}
+
+func (SMSTask) TableName() string {
+	return "sms_task"
+}
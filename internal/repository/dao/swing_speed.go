@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SwingSpeedDAO interface {
+	// Replace 删除用户当天已有的数据，再写入 stats，没有出现在 stats 中的击球类型也会被删除
+	Replace(ctx context.Context, userID int64, date time.Time, stats []SwingSpeedStat) error
+	ListByUserIDAndDateRange(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) ([]SwingSpeedStat, error)
+	// AvgP90ByPeers 统计同性别、生日落在 [birthFrom, birthTo] 内的所有用户在区间内的平均 90 分位速度
+	AvgP90ByPeers(ctx context.Context, strokeType string, startDate, endDate time.Time,
+		gender int, birthFrom, birthTo int64) ([]UserSpeedMetric, error)
+}
+
+type GormSwingSpeedDAO struct {
+	db *gorm.DB
+}
+
+func NewGormSwingSpeedDAO(db *gorm.DB) SwingSpeedDAO {
+	return &GormSwingSpeedDAO{
+		db: db,
+	}
+}
+
+func (d *GormSwingSpeedDAO) Replace(ctx context.Context, userID int64, date time.Time, stats []SwingSpeedStat) error {
+	now := time.Now().UnixMilli()
+	for i := range stats {
+		stats[i].Ctime = now
+		stats[i].Utime = now
+	}
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND summary_date = ?", userID, date).
+			Delete(&SwingSpeedStat{}).Error
+		if err != nil {
+			return err
+		}
+		if len(stats) == 0 {
+			return nil
+		}
+		return tx.Create(&stats).Error
+	})
+}
+
+func (d *GormSwingSpeedDAO) ListByUserIDAndDateRange(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) ([]SwingSpeedStat, error) {
+	var res []SwingSpeedStat
+	query := d.db.WithContext(ctx).
+		Where("user_id = ? AND summary_date BETWEEN ? AND ?", userID, startDate, endDate)
+	// 不指定击球类型时返回所有类型
+	if strokeType != "" {
+		query = query.Where("stroke_type = ?", strokeType)
+	}
+	err := query.Order("summary_date ASC").Find(&res).Error
+	return res, err
+}
+
+func (d *GormSwingSpeedDAO) AvgP90ByPeers(ctx context.Context, strokeType string, startDate, endDate time.Time,
+	gender int, birthFrom, birthTo int64) ([]UserSpeedMetric, error) {
+	var res []UserSpeedMetric
+	err := d.db.WithContext(ctx).
+		Table("swing_speed_stat AS s").
+		Select("s.user_id AS user_id, AVG(s.p90_speed) AS speed").
+		Joins("JOIN users AS u ON u.id = s.user_id").
+		Where("s.stroke_type = ? AND s.summary_date BETWEEN ? AND ?", strokeType, startDate, endDate).
+		Where("u.gender = ? AND u.birthday BETWEEN ? AND ?", gender, birthFrom, birthTo).
+		Group("s.user_id").
+		Scan(&res).Error
+	return res, err
+}
+
+// SwingSpeedStat 每个用户每天每种击球类型一行，stroke_type = all 表示整体分布
+type SwingSpeedStat struct {
+	ID          int64     `gorm:"column:id;primaryKey;autoIncrement"`                            // 主键
+	UserID      int64     `gorm:"column:user_id;uniqueIndex:uk_user_date_stroke"`                // 用户ID
+	SummaryDate time.Time `gorm:"column:summary_date;type:date;uniqueIndex:uk_user_date_stroke"` // 汇总日期
+	StrokeType  string    `gorm:"column:stroke_type;type:varchar(32);uniqueIndex:uk_user_date_stroke"`
+	Buckets     string    `gorm:"column:buckets;type:text"` // 直方图各个桶的计数，JSON 数组
+	SwingCount  int       `gorm:"column:swing_count"`       // 挥拍次数
+	AvgSpeed    float64   `gorm:"column:avg_speed"`         // 平均速度
+	P50Speed    int       `gorm:"column:p50_speed"`         // 速度中位数
+	P90Speed    int       `gorm:"column:p90_speed"`         // 90 分位速度
+
+	Ctime int64 `gorm:"column:ctime"` // 创建时间（时间戳）
+	Utime int64 `gorm:"column:utime"` // 更新时间（时间戳）
+}
+
+func (SwingSpeedStat) TableName() string {
+	return "swing_speed_stat"
+}
+
+// UserSpeedMetric 聚合查询的结果
+type UserSpeedMetric struct {
+	UserID int64   `gorm:"column:user_id"`
+	Speed  float64 `gorm:"column:speed"`
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type TrainingReportDAO interface {
+	// Insert 同一个用户同一个周期只会保存一份报告，返回值表示是否真的插入了
+	Insert(ctx context.Context, r TrainingReport) (bool, error)
+	FindByPeriod(ctx context.Context, userID int64, period string, startDate time.Time) (TrainingReport, error)
+	ListByUserID(ctx context.Context, userID int64, period string, offset, limit int) ([]TrainingReport, error)
+}
+
+type GormTrainingReportDAO struct {
+	db *gorm.DB
+}
+
+func NewGormTrainingReportDAO(db *gorm.DB) TrainingReportDAO {
+	return &GormTrainingReportDAO{
+		db: db,
+	}
+}
+
+func (d *GormTrainingReportDAO) Insert(ctx context.Context, r TrainingReport) (bool, error) {
+	r.Ctime = time.Now().UnixMilli()
+	res := d.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&r)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (d *GormTrainingReportDAO) FindByPeriod(ctx context.Context, userID int64, period string, startDate time.Time) (TrainingReport, error) {
+	var r TrainingReport
+	err := d.db.WithContext(ctx).
+		First(&r, "user_id = ? AND period = ? AND start_date = ?", userID, period, startDate).Error
+	return r, err
+}
+
+func (d *GormTrainingReportDAO) ListByUserID(ctx context.Context, userID int64, period string, offset, limit int) ([]TrainingReport, error) {
+	var res []TrainingReport
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND period = ?", userID, period).
+		Order("start_date DESC").
+		Offset(offset).Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+type TrainingReport struct {
+	Id        int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"column:user_id;uniqueIndex:uk_user_period"`
+	Period    string    `gorm:"column:period;type:varchar(16);uniqueIndex:uk_user_period"` // week / month
+	StartDate time.Time `gorm:"column:start_date;type:date;uniqueIndex:uk_user_period"`
+	EndDate   time.Time `gorm:"column:end_date;type:date"`
+	Content   string    `gorm:"column:content;type:text"` // 报告正文，JSON 格式
+	Ctime     int64     `gorm:"column:ctime"`
+}
+
+func (TrainingReport) TableName() string {
+	return "training_report"
+}
@@ -0,0 +1,183 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type TwoFactorDAO interface {
+	// UpsertTOTP 保存一个还没有确认的密钥，覆盖之前没有确认的密钥
+	// 已经启用的返回 ErrUserStateConflict
+	UpsertTOTP(ctx context.Context, t UserTOTP) error
+	FindTOTP(ctx context.Context, userID int64) (UserTOTP, error)
+	// EnableTOTP 确认密钥，同时生成一组新的恢复码
+	// 已经启用过的返回 ErrUserStateConflict
+	EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error
+	// UseTOTPStep 只有 step 比上一次使用的大才会成功，用来防止验证码被重放
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+	// DeleteTOTP 关闭二次验证，同时删除所有恢复码
+	DeleteTOTP(ctx context.Context, userID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	// UseRecoveryCode 每个恢复码只能使用一次
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+}
+
+type GormTwoFactorDAO struct {
+	db *gorm.DB
+}
+
+func NewGormTwoFactorDAO(db *gorm.DB) TwoFactorDAO {
+	return &GormTwoFactorDAO{
+		db: db,
+	}
+}
+
+func (d *GormTwoFactorDAO) UpsertTOTP(ctx context.Context, t UserTOTP) error {
+	now := time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old UserTOTP
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&old, "user_id = ?", t.UserID).Error
+		switch {
+		case err == nil:
+			// 已经启用的密钥不能被覆盖
+			if old.Enabled {
+				return ErrUserStateConflict
+			}
+			return tx.Model(&old).Updates(map[string]any{
+				"secret":    t.Secret,
+				"last_step": 0,
+				"utime":     now,
+			}).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			t.Enabled = false
+			t.LastStep = 0
+			t.Ctime = now
+			t.Utime = now
+			err = tx.Create(&t).Error
+			if isUniqueConflict(err) {
+				// 并发设置，让用户重试
+				return ErrUserStateConflict
+			}
+			return err
+		default:
+			return err
+		}
+	})
+}
+
+func (d *GormTwoFactorDAO) FindTOTP(ctx context.Context, userID int64) (UserTOTP, error) {
+	var res UserTOTP
+	err := d.db.WithContext(ctx).First(&res, "user_id = ?", userID).Error
+	return res, err
+}
+
+func (d *GormTwoFactorDAO) EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserTOTP{}).
+			Where("user_id = ? AND enabled = ?", userID, false).
+			Updates(map[string]any{
+				"enabled":   true,
+				"last_step": step,
+				"utime":     time.Now().UnixMilli(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUserStateConflict
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (d *GormTwoFactorDAO) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	res := d.db.WithContext(ctx).Model(&UserTOTP{}).
+		Where("user_id = ? AND enabled = ? AND last_step < ?", userID, true, step).
+		Updates(map[string]any{
+			"last_step": step,
+			"utime":     time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (d *GormTwoFactorDAO) DeleteTOTP(ctx context.Context, userID int64) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", userID).Delete(&UserRecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&UserTOTP{}).Error
+	})
+}
+
+func (d *GormTwoFactorDAO) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int64, codeHashes []string) error {
+	err := tx.Where("user_id = ?", userID).Delete(&UserRecoveryCode{}).Error
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	codes := make([]UserRecoveryCode, 0, len(codeHashes))
+	for _, h := range codeHashes {
+		codes = append(codes, UserRecoveryCode{
+			UserID:   userID,
+			CodeHash: h,
+			Ctime:    now,
+		})
+	}
+	return tx.Create(&codes).Error
+}
+
+func (d *GormTwoFactorDAO) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	res := d.db.WithContext(ctx).Model(&UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at = 0", userID, codeHash).
+		Update("used_at", time.Now().UnixMilli())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (d *GormTwoFactorDAO) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	var cnt int64
+	err := d.db.WithContext(ctx).Model(&UserRecoveryCode{}).
+		Where("user_id = ? AND used_at = 0", userID).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+// UserTOTP 每个用户最多一个 TOTP 密钥
+type UserTOTP struct {
+	Id     int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID int64  `gorm:"column:user_id;unique"`
+	Secret string `gorm:"column:secret;type:varchar(255)"` // 加密之后的密钥
+	// Enabled 用户用验证码确认之后才启用
+	Enabled  bool  `gorm:"column:enabled"`
+	LastStep int64 `gorm:"column:last_step"`
+	Ctime    int64 `gorm:"column:ctime"`
+	Utime    int64 `gorm:"column:utime"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+// UserRecoveryCode 恢复码只保存哈希
+type UserRecoveryCode struct {
+	Id       int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID   int64  `gorm:"column:user_id;index"`
+	CodeHash string `gorm:"column:code_hash;type:varchar(64)"`
+	UsedAt   int64  `gorm:"column:used_at"` // 0 表示还没有使用
+	Ctime    int64  `gorm:"column:ctime"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_code"
+}
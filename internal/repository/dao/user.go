@@ -6,6 +6,8 @@ import (
 	"errors"
 	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
 	"time"
 )
 
@@ -15,6 +17,9 @@ var (
 
 	// ErrDataNotFound 通用的数据没找到错误（即Gorm的记录未找到）
 	ErrDataNotFound = gorm.ErrRecordNotFound
+
+	// ErrUserStateConflict 用户当前的状态不允许这次操作，例如已经绑定过手机号
+	ErrUserStateConflict = errors.New("用户状态冲突")
 )
 
 type UserDAO interface {
@@ -23,6 +28,30 @@ type UserDAO interface {
 	FindByAccount(ctx context.Context, email string) (User, error)
 	FindById(ctx context.Context, id int64) (User, error)
 	UpdateNonZeroFields(ctx context.Context, u User) error
+	// SoftDelete 标记账号已注销，数据在宽限期过后才会真正删除
+	SoftDelete(ctx context.Context, id int64) error
+	// FindDeletedBefore 查找在 before（毫秒时间戳）之前注销的账号
+	FindDeletedBefore(ctx context.Context, before int64, limit int) ([]int64, error)
+	// HardDelete 在一个事务中删除用户以及该用户所有的个人数据
+	HardDelete(ctx context.Context, id int64) error
+	// BindPhone 给还没有手机号的账号绑定手机号
+	BindPhone(ctx context.Context, id int64, phone string) error
+	// UnbindPhone 解绑手机号，只有设置了账号的用户才能解绑，否则就没办法登录了
+	UnbindPhone(ctx context.Context, id int64) error
+	// MergeByPhone 把手机号 phone 对应的账号合并到 primaryID 中，返回被合并的账号 ID
+	// 被合并的账号会交出手机号并注销
+	MergeByPhone(ctx context.Context, primaryID int64, phone string) (int64, error)
+	// Search 按账号、手机号、昵称模糊搜索，keyword 是数字时同时按 ID 精确匹配
+	Search(ctx context.Context, keyword string, offset, limit int) ([]User, error)
+	// SetDisabled 禁用或者启用账号，已注销的账号返回 ErrDataNotFound
+	SetDisabled(ctx context.Context, id int64, disabled bool) error
+	// SetRole 修改角色，已注销的账号返回 ErrDataNotFound
+	SetRole(ctx context.Context, id int64, role int) error
+	// SetGender 修改性别，可以改回未知（0），已注销的账号返回 ErrDataNotFound
+	SetGender(ctx context.Context, id int64, gender int) error
+	// InsertWithIdentity 第三方登录时在一个事务中创建用户并绑定身份，返回新用户的 ID
+	// 身份已经被绑定时返回 ErrIdentityDuplicate
+	InsertWithIdentity(ctx context.Context, u User, identity UserIdentity) (int64, error)
 }
 
 // GormUserDAO 与用户数据表交互的所有操作
@@ -42,15 +71,20 @@ func (ud *GormUserDAO) Insert(ctx context.Context, u User) error {
 	u.Utime = now
 
 	err := ud.db.WithContext(ctx).Create(&u).Error
+	if isUniqueConflict(err) {
+		// 如果是唯一索引冲突，返回自定义的 ErrUserDuplicate 错误
+		return ErrUserDuplicate
+	}
+	return err
+}
+
+func isUniqueConflict(err error) bool {
 	var me *mysql.MySQLError
 	if errors.As(err, &me) {
 		const uniqueIndexErrNo uint16 = 1062 // 唯一索引冲突错误码
-		if me.Number == uniqueIndexErrNo {
-			// 如果是唯一索引冲突，返回自定义的 ErrUserDuplicate 错误
-			return ErrUserDuplicate
-		}
+		return me.Number == uniqueIndexErrNo
 	}
-	return err
+	return false
 }
 
 func (ud *GormUserDAO) FindByAccount(ctx context.Context, account string) (User, error) {
@@ -75,17 +109,238 @@ func (ud *GormUserDAO) UpdateNonZeroFields(ctx context.Context, u User) error {
 	return ud.db.Updates(&u).Error
 }
 
+func (ud *GormUserDAO) SoftDelete(ctx context.Context, id int64) error {
+	now := time.Now().UnixMilli()
+	return ud.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND dtime = 0", id).
+		Updates(map[string]any{
+			"dtime": now,
+			"utime": now,
+		}).Error
+}
+
+func (ud *GormUserDAO) FindDeletedBefore(ctx context.Context, before int64, limit int) ([]int64, error) {
+	var ids []int64
+	err := ud.db.WithContext(ctx).Model(&User{}).
+		Where("dtime > 0 AND dtime < ?", before).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (ud *GormUserDAO) HardDelete(ctx context.Context, id int64) error {
+	return ud.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先删除各个业务表中属于该用户的数据，最后删除用户本身
+		for _, model := range userOwnedModels {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", id).Delete(&User{}).Error
+	})
+}
+
+func (ud *GormUserDAO) BindPhone(ctx context.Context, id int64, phone string) error {
+	res := ud.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND phone IS NULL AND dtime = 0", id).
+		Updates(map[string]any{
+			"phone": phone,
+			"utime": time.Now().UnixMilli(),
+		})
+	if isUniqueConflict(res.Error) {
+		return ErrUserDuplicate
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserStateConflict
+	}
+	return nil
+}
+
+func (ud *GormUserDAO) UnbindPhone(ctx context.Context, id int64) error {
+	res := ud.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND phone IS NOT NULL AND account IS NOT NULL AND dtime = 0", id).
+		Updates(map[string]any{
+			"phone": nil,
+			"utime": time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserStateConflict
+	}
+	return nil
+}
+
+func (ud *GormUserDAO) MergeByPhone(ctx context.Context, primaryID int64, phone string) (int64, error) {
+	var dupID int64
+	err := ud.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var primary, dup User
+		// 锁住两个账号，避免合并过程中其中一个被修改
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&primary, "id = ?", primaryID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dup, "phone = ?", phone).Error
+		if err != nil {
+			return err
+		}
+		// 只合并纯手机号账号，并且主账号还没有绑定手机号
+		if dup.Id == primary.Id || dup.Account.Valid || dup.Dtime > 0 ||
+			primary.Phone.Valid || primary.Dtime > 0 {
+			return ErrUserStateConflict
+		}
+		dupID = dup.Id
+
+		if err = mergeDailySummaries(tx, primary.Id, dup.Id); err != nil {
+			return err
+		}
+		err = tx.Model(&LoginHistory{}).Where("user_id = ?", dup.Id).
+			Update("user_id", primary.Id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&UserIdentity{}).Where("user_id = ?", dup.Id).
+			Update("user_id", primary.Id).Error
+		if err != nil {
+			return err
+		}
+
+		// 被合并的账号先交出手机号，再把手机号绑定到主账号上
+		now := time.Now().UnixMilli()
+		err = tx.Model(&User{}).Where("id = ?", dup.Id).
+			Updates(map[string]any{
+				"phone": nil,
+				"dtime": now,
+				"utime": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", primary.Id).
+			Updates(map[string]any{
+				"phone": phone,
+				"utime": now,
+			}).Error
+	})
+	return dupID, err
+}
+
+func (ud *GormUserDAO) InsertWithIdentity(ctx context.Context, u User, identity UserIdentity) (int64, error) {
+	now := time.Now().UnixMilli()
+	u.Ctime = now
+	u.Utime = now
+	err := ud.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		identity.UserID = u.Id
+		identity.Ctime = now
+		err := tx.Create(&identity).Error
+		if isUniqueConflict(err) {
+			return ErrIdentityDuplicate
+		}
+		return err
+	})
+	return u.Id, err
+}
+
+func (ud *GormUserDAO) Search(ctx context.Context, keyword string, offset, limit int) ([]User, error) {
+	var res []User
+	query := ud.db.WithContext(ctx).Model(&User{}).Where("dtime = 0")
+	if keyword != "" {
+		like := "%" + keyword + "%"
+		cond := ud.db.Where("account LIKE ? OR phone LIKE ? OR nickname LIKE ?", like, like, like)
+		if id, err := strconv.ParseInt(keyword, 10, 64); err == nil {
+			cond = cond.Or("id = ?", id)
+		}
+		query = query.Where(cond)
+	}
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (ud *GormUserDAO) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	res := ud.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND dtime = 0", id).
+		Updates(map[string]any{
+			"disabled": disabled,
+			"utime":    time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDataNotFound
+	}
+	return nil
+}
+
+func (ud *GormUserDAO) SetRole(ctx context.Context, id int64, role int) error {
+	res := ud.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND dtime = 0", id).
+		Updates(map[string]any{
+			"role":  role,
+			"utime": time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDataNotFound
+	}
+	return nil
+}
+
+func (ud *GormUserDAO) SetGender(ctx context.Context, id int64, gender int) error {
+	res := ud.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND dtime = 0", id).
+		Updates(map[string]any{
+			"gender": gender,
+			"utime":  time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDataNotFound
+	}
+	return nil
+}
+
+// userOwnedModels 所有带 user_id 列、属于某个用户的个人数据表
+// 新增这类表时需要加到这里，否则注销账号时不会被清理
+var userOwnedModels = []any{
+	&DailySummary{},
+	&SwingSpeedStat{},
+	&TrainingReport{},
+	&ExportJob{},
+	&ImportJob{},
+	&LoginHistory{},
+	&DataArchive{},
+	&UserIdentity{},
+	&UserTOTP{},
+	&UserRecoveryCode{},
+}
+
 type User struct {
 	Id       int64
 	Username sql.NullString
-	Account  sql.NullString
+	Account  sql.NullString `gorm:"unique"`
 	Password string
-	Phone    sql.NullString
+	Phone    sql.NullString `gorm:"unique"`
 	Nickname sql.NullString
+	Gender   int
 	WeightKg int
 	HeightCm int
 	AboutMe  sql.NullString
 	Birthday sql.NullInt64
 	Ctime    int64
 	Utime    int64
+	Dtime    int64 `gorm:"index"` // 注销时间，0 表示账号正常
+	Role     int
+	Disabled bool
 }
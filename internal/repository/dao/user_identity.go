@@ -0,0 +1,81 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrIdentityDuplicate 第三方身份已经绑定了账号
+var ErrIdentityDuplicate = errors.New("第三方身份已经绑定了账号")
+
+type UserIdentityDAO interface {
+	Insert(ctx context.Context, i UserIdentity) error
+	FindByProviderSubject(ctx context.Context, provider, subject string) (UserIdentity, error)
+	ListByUserID(ctx context.Context, userID int64) ([]UserIdentity, error)
+	// Delete 解绑用户在某个平台上的身份，没有绑定时返回 ErrDataNotFound
+	Delete(ctx context.Context, userID int64, provider string) error
+}
+
+type GormUserIdentityDAO struct {
+	db *gorm.DB
+}
+
+func NewGormUserIdentityDAO(db *gorm.DB) UserIdentityDAO {
+	return &GormUserIdentityDAO{
+		db: db,
+	}
+}
+
+func (d *GormUserIdentityDAO) Insert(ctx context.Context, i UserIdentity) error {
+	i.Ctime = time.Now().UnixMilli()
+	err := d.db.WithContext(ctx).Create(&i).Error
+	if isUniqueConflict(err) {
+		return ErrIdentityDuplicate
+	}
+	return err
+}
+
+func (d *GormUserIdentityDAO) FindByProviderSubject(ctx context.Context, provider, subject string) (UserIdentity, error) {
+	var res UserIdentity
+	err := d.db.WithContext(ctx).
+		First(&res, "provider = ? AND subject = ?", provider, subject).Error
+	return res, err
+}
+
+func (d *GormUserIdentityDAO) ListByUserID(ctx context.Context, userID int64) ([]UserIdentity, error) {
+	var res []UserIdentity
+	err := d.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id ASC").
+		Find(&res).Error
+	return res, err
+}
+
+func (d *GormUserIdentityDAO) Delete(ctx context.Context, userID int64, provider string) error {
+	res := d.db.WithContext(ctx).
+		Where("user_id = ? AND provider = ?", userID, provider).
+		Delete(&UserIdentity{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDataNotFound
+	}
+	return nil
+}
+
+// UserIdentity 同一个平台上的同一个身份只能绑定一个账号
+type UserIdentity struct {
+	Id       int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID   int64  `gorm:"column:user_id;index"`
+	Provider string `gorm:"column:provider;type:varchar(64);uniqueIndex:uniq_provider_subject"`
+	Subject  string `gorm:"column:subject;type:varchar(255);uniqueIndex:uniq_provider_subject"`
+	Nickname string `gorm:"column:nickname;type:varchar(128)"`
+	Ctime    int64  `gorm:"column:ctime"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identity"
+}
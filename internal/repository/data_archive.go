@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"database/sql"
+	"time"
+)
+
+var ErrArchiveNotFound = dao.ErrDataNotFound
+
+type DataArchiveRepository interface {
+	Create(ctx context.Context, a domain.DataArchive) (int64, error)
+	FindById(ctx context.Context, id int64) (domain.DataArchive, error)
+	FindByToken(ctx context.Context, token string) (domain.DataArchive, error)
+	// Update 只会更新非零值字段
+	Update(ctx context.Context, a domain.DataArchive) error
+	FindExpired(ctx context.Context, before time.Time, limit int) ([]domain.DataArchive, error)
+	Delete(ctx context.Context, id int64) error
+	// FailStale 把 before 之前创建、仍然没有结束的任务标记为失败
+	FailStale(ctx context.Context, before time.Time, errMsg string) (int64, error)
+}
+
+type dataArchiveRepository struct {
+	dao dao.DataArchiveDAO
+}
+
+func NewDataArchiveRepository(dao dao.DataArchiveDAO) DataArchiveRepository {
+	return &dataArchiveRepository{
+		dao: dao,
+	}
+}
+
+func (r *dataArchiveRepository) Create(ctx context.Context, a domain.DataArchive) (int64, error) {
+	return r.dao.Insert(ctx, r.domainToEntity(a))
+}
+
+func (r *dataArchiveRepository) FindById(ctx context.Context, id int64) (domain.DataArchive, error) {
+	a, err := r.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.DataArchive{}, err
+	}
+	return r.entityToDomain(a), nil
+}
+
+func (r *dataArchiveRepository) FindByToken(ctx context.Context, token string) (domain.DataArchive, error) {
+	a, err := r.dao.FindByToken(ctx, token)
+	if err != nil {
+		return domain.DataArchive{}, err
+	}
+	return r.entityToDomain(a), nil
+}
+
+func (r *dataArchiveRepository) Update(ctx context.Context, a domain.DataArchive) error {
+	return r.dao.UpdateNonZeroFields(ctx, r.domainToEntity(a))
+}
+
+func (r *dataArchiveRepository) FindExpired(ctx context.Context, before time.Time, limit int) ([]domain.DataArchive, error) {
+	res, err := r.dao.FindExpired(ctx, before.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]domain.DataArchive, 0, len(res))
+	for _, a := range res {
+		archives = append(archives, r.entityToDomain(a))
+	}
+	return archives, nil
+}
+
+func (r *dataArchiveRepository) Delete(ctx context.Context, id int64) error {
+	return r.dao.Delete(ctx, id)
+}
+
+func (r *dataArchiveRepository) FailStale(ctx context.Context, before time.Time, errMsg string) (int64, error) {
+	return r.dao.FailStale(ctx, before.UnixMilli(), errMsg)
+}
+
+func (r *dataArchiveRepository) domainToEntity(a domain.DataArchive) dao.DataArchive {
+	var expireAt int64
+	if !a.ExpireAt.IsZero() {
+		expireAt = a.ExpireAt.UnixMilli()
+	}
+	return dao.DataArchive{
+		Id:       a.Id,
+		UserID:   a.UserID,
+		Status:   a.Status,
+		FilePath: a.FilePath,
+		// 还没有生成下载凭证时存 NULL，避免和唯一索引冲突
+		Token:    sql.NullString{String: a.Token, Valid: a.Token != ""},
+		ExpireAt: expireAt,
+		ErrMsg:   a.ErrMsg,
+	}
+}
+
+func (r *dataArchiveRepository) entityToDomain(a dao.DataArchive) domain.DataArchive {
+	var expireAt time.Time
+	if a.ExpireAt > 0 {
+		expireAt = time.UnixMilli(a.ExpireAt)
+	}
+	return domain.DataArchive{
+		Id:       a.Id,
+		UserID:   a.UserID,
+		Status:   a.Status,
+		FilePath: a.FilePath,
+		Token:    a.Token.String,
+		ExpireAt: expireAt,
+		ErrMsg:   a.ErrMsg,
+		Ctime:    time.UnixMilli(a.Ctime),
+		Utime:    time.UnixMilli(a.Utime),
+	}
+}
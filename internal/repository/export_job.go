@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrJobNotFound = dao.ErrDataNotFound
+
+type ExportJobRepository interface {
+	Create(ctx context.Context, j domain.ExportJob) (int64, error)
+	FindById(ctx context.Context, id int64) (domain.ExportJob, error)
+	// Update 只会更新非零值字段
+	Update(ctx context.Context, j domain.ExportJob) error
+	// FailStale 把 before 之前创建、仍然没有结束的任务标记为失败
+	FailStale(ctx context.Context, before time.Time, errMsg string) (int64, error)
+}
+
+type exportJobRepository struct {
+	dao dao.ExportJobDAO
+}
+
+func NewExportJobRepository(dao dao.ExportJobDAO) ExportJobRepository {
+	return &exportJobRepository{
+		dao: dao,
+	}
+}
+
+func (r *exportJobRepository) Create(ctx context.Context, j domain.ExportJob) (int64, error) {
+	return r.dao.Insert(ctx, r.domainToEntity(j))
+}
+
+func (r *exportJobRepository) FindById(ctx context.Context, id int64) (domain.ExportJob, error) {
+	j, err := r.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.ExportJob{}, err
+	}
+	return r.entityToDomain(j), nil
+}
+
+func (r *exportJobRepository) Update(ctx context.Context, j domain.ExportJob) error {
+	return r.dao.UpdateNonZeroFields(ctx, r.domainToEntity(j))
+}
+
+func (r *exportJobRepository) FailStale(ctx context.Context, before time.Time, errMsg string) (int64, error) {
+	return r.dao.FailStale(ctx, before.UnixMilli(), errMsg)
+}
+
+func (r *exportJobRepository) domainToEntity(j domain.ExportJob) dao.ExportJob {
+	return dao.ExportJob{
+		Id:        j.Id,
+		UserID:    j.UserID,
+		Format:    j.Format,
+		StartDate: j.StartDate,
+		EndDate:   j.EndDate,
+		Status:    j.Status,
+		RowCount:  j.RowCount,
+		FilePath:  j.FilePath,
+		ErrMsg:    j.ErrMsg,
+	}
+}
+
+func (r *exportJobRepository) entityToDomain(j dao.ExportJob) domain.ExportJob {
+	return domain.ExportJob{
+		Id:        j.Id,
+		UserID:    j.UserID,
+		Format:    j.Format,
+		StartDate: j.StartDate,
+		EndDate:   j.EndDate,
+		Status:    j.Status,
+		RowCount:  j.RowCount,
+		FilePath:  j.FilePath,
+		ErrMsg:    j.ErrMsg,
+		Ctime:     time.UnixMilli(j.Ctime),
+		Utime:     time.UnixMilli(j.Utime),
+	}
+}
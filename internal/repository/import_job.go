@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type ImportJobRepository interface {
+	Create(ctx context.Context, j domain.ImportJob) (int64, error)
+	FindById(ctx context.Context, id int64) (domain.ImportJob, error)
+	FindByFileHash(ctx context.Context, userID int64, fileHash string) (domain.ImportJob, error)
+	// Update 只会更新非零值字段
+	Update(ctx context.Context, j domain.ImportJob) error
+	// FailStale 把 before 之前创建、仍然没有结束的任务标记为失败
+	FailStale(ctx context.Context, before time.Time, errMsg string) (int64, error)
+}
+
+type importJobRepository struct {
+	dao dao.ImportJobDAO
+}
+
+func NewImportJobRepository(dao dao.ImportJobDAO) ImportJobRepository {
+	return &importJobRepository{
+		dao: dao,
+	}
+}
+
+func (r *importJobRepository) Create(ctx context.Context, j domain.ImportJob) (int64, error) {
+	entity, err := r.domainToEntity(j)
+	if err != nil {
+		return 0, err
+	}
+	return r.dao.Insert(ctx, entity)
+}
+
+func (r *importJobRepository) FindById(ctx context.Context, id int64) (domain.ImportJob, error) {
+	j, err := r.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.ImportJob{}, err
+	}
+	return r.entityToDomain(j)
+}
+
+func (r *importJobRepository) FindByFileHash(ctx context.Context, userID int64, fileHash string) (domain.ImportJob, error) {
+	j, err := r.dao.FindByFileHash(ctx, userID, fileHash)
+	if err != nil {
+		return domain.ImportJob{}, err
+	}
+	return r.entityToDomain(j)
+}
+
+func (r *importJobRepository) Update(ctx context.Context, j domain.ImportJob) error {
+	entity, err := r.domainToEntity(j)
+	if err != nil {
+		return err
+	}
+	return r.dao.UpdateNonZeroFields(ctx, entity)
+}
+
+func (r *importJobRepository) FailStale(ctx context.Context, before time.Time, errMsg string) (int64, error) {
+	return r.dao.FailStale(ctx, before.UnixMilli(), errMsg)
+}
+
+func (r *importJobRepository) domainToEntity(j domain.ImportJob) (dao.ImportJob, error) {
+	var errs string
+	if len(j.Errors) > 0 {
+		data, err := json.Marshal(j.Errors)
+		if err != nil {
+			return dao.ImportJob{}, err
+		}
+		errs = string(data)
+	}
+	return dao.ImportJob{
+		Id:          j.Id,
+		UserID:      j.UserID,
+		Source:      j.Source,
+		Format:      j.Format,
+		FileHash:    j.FileHash,
+		FilePath:    j.FilePath,
+		Status:      j.Status,
+		TotalRows:   j.TotalRows,
+		SuccessRows: j.SuccessRows,
+		FailedRows:  j.FailedRows,
+		Errors:      errs,
+		ErrMsg:      j.ErrMsg,
+	}, nil
+}
+
+func (r *importJobRepository) entityToDomain(j dao.ImportJob) (domain.ImportJob, error) {
+	var errs []domain.ImportRowError
+	if j.Errors != "" {
+		if err := json.Unmarshal([]byte(j.Errors), &errs); err != nil {
+			return domain.ImportJob{}, err
+		}
+	}
+	return domain.ImportJob{
+		Id:          j.Id,
+		UserID:      j.UserID,
+		Source:      j.Source,
+		Format:      j.Format,
+		FileHash:    j.FileHash,
+		FilePath:    j.FilePath,
+		Status:      j.Status,
+		TotalRows:   j.TotalRows,
+		SuccessRows: j.SuccessRows,
+		FailedRows:  j.FailedRows,
+		Errors:      errs,
+		ErrMsg:      j.ErrMsg,
+		Ctime:       time.UnixMilli(j.Ctime),
+		Utime:       time.UnixMilli(j.Utime),
+	}, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"badminton-backend/internal/repository/cache"
+	"context"
+	"time"
+)
+
+type LockoutPolicy = cache.LockoutPolicy
+
+type LoginAttemptRepository interface {
+	RecordFailure(ctx context.Context, subject string, p LockoutPolicy) (int64, time.Duration, error)
+	State(ctx context.Context, subject string) (int64, time.Duration, error)
+	Reset(ctx context.Context, subject string) error
+}
+
+type CachedLoginAttemptRepository struct {
+	cache cache.LoginAttemptCache
+}
+
+func NewCachedLoginAttemptRepository(c cache.LoginAttemptCache) LoginAttemptRepository {
+	return &CachedLoginAttemptRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedLoginAttemptRepository) RecordFailure(ctx context.Context, subject string, p LockoutPolicy) (int64, time.Duration, error) {
+	return repo.cache.RecordFailure(ctx, subject, p)
+}
+
+func (repo *CachedLoginAttemptRepository) State(ctx context.Context, subject string) (int64, time.Duration, error) {
+	return repo.cache.State(ctx, subject)
+}
+
+func (repo *CachedLoginAttemptRepository) Reset(ctx context.Context, subject string) error {
+	return repo.cache.Reset(ctx, subject)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"time"
+)
+
+type LoginHistoryRepository interface {
+	Create(ctx context.Context, r domain.LoginRecord) error
+	List(ctx context.Context, userID int64, offset, limit int) ([]domain.LoginRecord, error)
+}
+
+type loginHistoryRepository struct {
+	dao dao.LoginHistoryDAO
+}
+
+func NewLoginHistoryRepository(dao dao.LoginHistoryDAO) LoginHistoryRepository {
+	return &loginHistoryRepository{
+		dao: dao,
+	}
+}
+
+func (r *loginHistoryRepository) Create(ctx context.Context, record domain.LoginRecord) error {
+	return r.dao.Insert(ctx, dao.LoginHistory{
+		UserID:    record.UserID,
+		Ssid:      record.Ssid,
+		Method:    record.Method,
+		IP:        record.IP,
+		UserAgent: record.UserAgent,
+	})
+}
+
+func (r *loginHistoryRepository) List(ctx context.Context, userID int64, offset, limit int) ([]domain.LoginRecord, error) {
+	res, err := r.dao.ListByUserID(ctx, userID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomains(res), nil
+}
+
+func (r *loginHistoryRepository) toDomains(entities []dao.LoginHistory) []domain.LoginRecord {
+	res := make([]domain.LoginRecord, 0, len(entities))
+	for _, e := range entities {
+		res = append(res, domain.LoginRecord{
+			Id:        e.Id,
+			UserID:    e.UserID,
+			Ssid:      e.Ssid,
+			Method:    e.Method,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Ctime:     time.UnixMilli(e.Ctime),
+		})
+	}
+	return res
+}
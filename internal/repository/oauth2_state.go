@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/cache"
+	"context"
+)
+
+var ErrOAuth2StateNotFound = cache.ErrOAuth2StateNotFound
+
+type OAuth2StateRepository interface {
+	Store(ctx context.Context, state string, s domain.OAuth2State) error
+	// Take 取出 state 对应的信息，取出之后就失效了
+	Take(ctx context.Context, state string) (domain.OAuth2State, error)
+}
+
+type CachedOAuth2StateRepository struct {
+	cache cache.OAuth2StateCache
+}
+
+func NewCachedOAuth2StateRepository(c cache.OAuth2StateCache) OAuth2StateRepository {
+	return &CachedOAuth2StateRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedOAuth2StateRepository) Store(ctx context.Context, state string, s domain.OAuth2State) error {
+	return repo.cache.Set(ctx, state, s)
+}
+
+func (repo *CachedOAuth2StateRepository) Take(ctx context.Context, state string) (domain.OAuth2State, error) {
+	return repo.cache.GetDel(ctx, state)
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/cache"
+	"context"
+)
+
+var ErrPreAuthNotFound = cache.ErrPreAuthNotFound
+
+type PreAuthRepository interface {
+	Create(ctx context.Context, token string, pa domain.PreAuth) error
+	Find(ctx context.Context, token string) (domain.PreAuth, error)
+	IncrFailures(ctx context.Context, token string) (int64, error)
+	// Consume 让凭证失效，返回 false 说明凭证已经被用掉了
+	Consume(ctx context.Context, token string) (bool, error)
+}
+
+type CachedPreAuthRepository struct {
+	cache cache.PreAuthCache
+}
+
+func NewCachedPreAuthRepository(c cache.PreAuthCache) PreAuthRepository {
+	return &CachedPreAuthRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedPreAuthRepository) Create(ctx context.Context, token string, pa domain.PreAuth) error {
+	return repo.cache.Set(ctx, token, pa)
+}
+
+func (repo *CachedPreAuthRepository) Find(ctx context.Context, token string) (domain.PreAuth, error) {
+	return repo.cache.Get(ctx, token)
+}
+
+func (repo *CachedPreAuthRepository) IncrFailures(ctx context.Context, token string) (int64, error) {
+	return repo.cache.IncrFailures(ctx, token)
+}
+
+func (repo *CachedPreAuthRepository) Consume(ctx context.Context, token string) (bool, error) {
+	return repo.cache.Delete(ctx, token)
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/cache"
+	"context"
+)
+
+type SessionRepository interface {
+	// ListActive 返回用户所有还没有失效的会话
+	ListActive(ctx context.Context, uid int64) ([]domain.Session, error)
+}
+
+type sessionRepository struct {
+	cache cache.SessionCache
+}
+
+func NewSessionRepository(c cache.SessionCache) SessionRepository {
+	return &sessionRepository{
+		cache: c,
+	}
+}
+
+func (r *sessionRepository) ListActive(ctx context.Context, uid int64) ([]domain.Session, error) {
+	return r.cache.List(ctx, uid)
+}
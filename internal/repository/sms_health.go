@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"badminton-backend/internal/repository/cache"
+	"context"
+)
+
+type SMSHealthPolicy = cache.SMSHealthPolicy
+
+type SMSHealthRepository interface {
+	RecordFailure(ctx context.Context, provider string, p SMSHealthPolicy) (bool, error)
+	RecordSuccess(ctx context.Context, provider string) error
+	Down(ctx context.Context, providers []string) ([]bool, error)
+}
+
+type CachedSMSHealthRepository struct {
+	cache cache.SMSHealthCache
+}
+
+func NewCachedSMSHealthRepository(c cache.SMSHealthCache) SMSHealthRepository {
+	return &CachedSMSHealthRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedSMSHealthRepository) RecordFailure(ctx context.Context, provider string, p SMSHealthPolicy) (bool, error) {
+	return repo.cache.RecordFailure(ctx, provider, p)
+}
+
+func (repo *CachedSMSHealthRepository) RecordSuccess(ctx context.Context, provider string) error {
+	return repo.cache.RecordSuccess(ctx, provider)
+}
+
+func (repo *CachedSMSHealthRepository) Down(ctx context.Context, providers []string) ([]bool, error) {
+	return repo.cache.Down(ctx, providers)
+}
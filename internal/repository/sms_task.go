@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// smsTaskMaxErrLen 和 last_err 字段的长度一致
+const smsTaskMaxErrLen = 1024
+
+type SMSTaskRepository interface {
+	Create(ctx context.Context, t domain.SMSTask) (int64, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]domain.SMSTask, error)
+	// Claim 抢占任务，leaseUntil 之前其他实例不会再抢到它
+	Claim(ctx context.Context, t domain.SMSTask, leaseUntil time.Time) (bool, error)
+	UpdateResult(ctx context.Context, id int64, status string, nextRetryTime time.Time, lastErr string) error
+	ListDead(ctx context.Context, offset, limit int) ([]domain.SMSTask, error)
+	Requeue(ctx context.Context, id int64, deadline time.Time) (bool, error)
+}
+
+type smsTaskRepository struct {
+	dao dao.SMSTaskDAO
+}
+
+func NewSMSTaskRepository(dao dao.SMSTaskDAO) SMSTaskRepository {
+	return &smsTaskRepository{
+		dao: dao,
+	}
+}
+
+func (r *smsTaskRepository) Create(ctx context.Context, t domain.SMSTask) (int64, error) {
+	entity, err := r.domainToEntity(t)
+	if err != nil {
+		return 0, err
+	}
+	return r.dao.Insert(ctx, entity)
+}
+
+func (r *smsTaskRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]domain.SMSTask, error) {
+	tasks, err := r.dao.FindDue(ctx, now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.entitiesToDomain(tasks), nil
+}
+
+func (r *smsTaskRepository) Claim(ctx context.Context, t domain.SMSTask, leaseUntil time.Time) (bool, error) {
+	return r.dao.Claim(ctx, t.Id, t.NextRetryTime.UnixMilli(), leaseUntil.UnixMilli())
+}
+
+func (r *smsTaskRepository) UpdateResult(ctx context.Context, id int64, status string, nextRetryTime time.Time, lastErr string) error {
+	return r.dao.UpdateResult(ctx, id, status, nextRetryTime.UnixMilli(), truncate(lastErr, smsTaskMaxErrLen))
+}
+
+func (r *smsTaskRepository) ListDead(ctx context.Context, offset, limit int) ([]domain.SMSTask, error) {
+	tasks, err := r.dao.ListByStatus(ctx, domain.SMSTaskStatusDead, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.entitiesToDomain(tasks), nil
+}
+
+func (r *smsTaskRepository) Requeue(ctx context.Context, id int64, deadline time.Time) (bool, error) {
+	return r.dao.Requeue(ctx, id, deadline.UnixMilli())
+}
+
+func (r *smsTaskRepository) domainToEntity(t domain.SMSTask) (dao.SMSTask, error) {
+	args, err := json.Marshal(t.Args)
+	if err != nil {
+		return dao.SMSTask{}, err
+	}
+	numbers, err := json.Marshal(t.Numbers)
+	if err != nil {
+		return dao.SMSTask{}, err
+	}
+	return dao.SMSTask{
+		Id:            t.Id,
+		TplId:         t.TplId,
+		Args:          string(args),
+		Numbers:       string(numbers),
+		Status:        t.Status,
+		Attempts:      t.Attempts,
+		NextRetryTime: t.NextRetryTime.UnixMilli(),
+		Deadline:      t.Deadline.UnixMilli(),
+		LastErr:       truncate(t.LastErr, smsTaskMaxErrLen),
+	}, nil
+}
+
+func (r *smsTaskRepository) entitiesToDomain(tasks []dao.SMSTask) []domain.SMSTask {
+	res := make([]domain.SMSTask, 0, len(tasks))
+	for _, t := range tasks {
+		res = append(res, r.entityToDomain(t))
+	}
+	return res
+}
+
+func (r *smsTaskRepository) entityToDomain(t dao.SMSTask) domain.SMSTask {
+	var args, numbers []string
+	// 都是我们自己写进去的，解析失败只可能是被人改过，按空处理
+	_ = json.Unmarshal([]byte(t.Args), &args)
+	_ = json.Unmarshal([]byte(t.Numbers), &numbers)
+	return domain.SMSTask{
+		Id:            t.Id,
+		TplId:         t.TplId,
+		Args:          args,
+		Numbers:       numbers,
+		Status:        t.Status,
+		Attempts:      t.Attempts,
+		NextRetryTime: time.UnixMilli(t.NextRetryTime),
+		Deadline:      time.UnixMilli(t.Deadline),
+		LastErr:       t.LastErr,
+		Ctime:         time.UnixMilli(t.Ctime),
+		Utime:         time.UnixMilli(t.Utime),
+	}
+}
+
+// truncate 按字符截断，避免把一个汉字截成两半
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := 0
+	for i := range s {
+		if i > n {
+			break
+		}
+		cut = i
+	}
+	return s[:cut]
+}
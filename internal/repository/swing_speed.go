@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type SwingSpeedRepository interface {
+	// Replace 用 dists 替换用户当天的全部数据
+	Replace(ctx context.Context, userID int64, date time.Time, dists []domain.SwingSpeedDistribution) error
+	ListByUserIDAndDateRange(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) ([]domain.SwingSpeedDistribution, error)
+	// PeerSpeeds 返回同组每个用户在区间内的平均 90 分位速度，key 为用户 ID
+	PeerSpeeds(ctx context.Context, strokeType string, startDate, endDate time.Time,
+		gender int, birthFrom, birthTo time.Time) (map[int64]float64, error)
+}
+
+type swingSpeedRepository struct {
+	dao dao.SwingSpeedDAO
+}
+
+func NewSwingSpeedRepository(dao dao.SwingSpeedDAO) SwingSpeedRepository {
+	return &swingSpeedRepository{
+		dao: dao,
+	}
+}
+
+func (r *swingSpeedRepository) Replace(ctx context.Context, userID int64, date time.Time, dists []domain.SwingSpeedDistribution) error {
+	stats := make([]dao.SwingSpeedStat, 0, len(dists))
+	for _, d := range dists {
+		stat, err := r.domainToEntity(d)
+		if err != nil {
+			return err
+		}
+		stats = append(stats, stat)
+	}
+	return r.dao.Replace(ctx, userID, date, stats)
+}
+
+func (r *swingSpeedRepository) ListByUserIDAndDateRange(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) ([]domain.SwingSpeedDistribution, error) {
+	stats, err := r.dao.ListByUserIDAndDateRange(ctx, userID, strokeType, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SwingSpeedDistribution, 0, len(stats))
+	for _, stat := range stats {
+		d, err := r.entityToDomain(stat)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+func (r *swingSpeedRepository) PeerSpeeds(ctx context.Context, strokeType string, startDate, endDate time.Time,
+	gender int, birthFrom, birthTo time.Time) (map[int64]float64, error) {
+	metrics, err := r.dao.AvgP90ByPeers(ctx, strokeType, startDate, endDate,
+		gender, birthFrom.UnixMilli(), birthTo.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]float64, len(metrics))
+	for _, m := range metrics {
+		res[m.UserID] = m.Speed
+	}
+	return res, nil
+}
+
+func (r *swingSpeedRepository) domainToEntity(d domain.SwingSpeedDistribution) (dao.SwingSpeedStat, error) {
+	buckets, err := json.Marshal(d.Buckets)
+	if err != nil {
+		return dao.SwingSpeedStat{}, err
+	}
+	return dao.SwingSpeedStat{
+		UserID:      d.UserID,
+		SummaryDate: d.Date,
+		StrokeType:  d.StrokeType,
+		Buckets:     string(buckets),
+		SwingCount:  d.Count,
+		AvgSpeed:    d.AvgSpeed,
+		P50Speed:    d.P50Speed,
+		P90Speed:    d.P90Speed,
+	}, nil
+}
+
+func (r *swingSpeedRepository) entityToDomain(stat dao.SwingSpeedStat) (domain.SwingSpeedDistribution, error) {
+	var buckets []int
+	if stat.Buckets != "" {
+		if err := json.Unmarshal([]byte(stat.Buckets), &buckets); err != nil {
+			return domain.SwingSpeedDistribution{}, err
+		}
+	}
+	return domain.SwingSpeedDistribution{
+		UserID:     stat.UserID,
+		Date:       stat.SummaryDate,
+		StrokeType: stat.StrokeType,
+		Buckets:    buckets,
+		Count:      stat.SwingCount,
+		AvgSpeed:   stat.AvgSpeed,
+		P50Speed:   stat.P50Speed,
+		P90Speed:   stat.P90Speed,
+	}, nil
+}
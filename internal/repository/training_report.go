@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+var ErrReportNotFound = dao.ErrDataNotFound
+
+type TrainingReportRepository interface {
+	// Create 返回值表示报告是否是新创建的，已经存在时不会覆盖
+	Create(ctx context.Context, r domain.TrainingReport) (bool, error)
+	FindByPeriod(ctx context.Context, userID int64, period string, startDate time.Time) (domain.TrainingReport, error)
+	List(ctx context.Context, userID int64, period string, offset, limit int) ([]domain.TrainingReport, error)
+}
+
+type trainingReportRepository struct {
+	dao dao.TrainingReportDAO
+}
+
+func NewTrainingReportRepository(dao dao.TrainingReportDAO) TrainingReportRepository {
+	return &trainingReportRepository{
+		dao: dao,
+	}
+}
+
+func (r *trainingReportRepository) Create(ctx context.Context, report domain.TrainingReport) (bool, error) {
+	entity, err := r.domainToEntity(report)
+	if err != nil {
+		return false, err
+	}
+	return r.dao.Insert(ctx, entity)
+}
+
+func (r *trainingReportRepository) FindByPeriod(ctx context.Context, userID int64, period string, startDate time.Time) (domain.TrainingReport, error) {
+	entity, err := r.dao.FindByPeriod(ctx, userID, period, startDate)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+	return r.entityToDomain(entity)
+}
+
+func (r *trainingReportRepository) List(ctx context.Context, userID int64, period string, offset, limit int) ([]domain.TrainingReport, error) {
+	entities, err := r.dao.ListByUserID(ctx, userID, period, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.TrainingReport, 0, len(entities))
+	for _, entity := range entities {
+		report, err := r.entityToDomain(entity)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, report)
+	}
+	return res, nil
+}
+
+func (r *trainingReportRepository) domainToEntity(report domain.TrainingReport) (dao.TrainingReport, error) {
+	content, err := json.Marshal(report)
+	if err != nil {
+		return dao.TrainingReport{}, err
+	}
+	return dao.TrainingReport{
+		Id:        report.Id,
+		UserID:    report.UserID,
+		Period:    report.Period,
+		StartDate: report.StartDate,
+		EndDate:   report.EndDate,
+		Content:   string(content),
+	}, nil
+}
+
+func (r *trainingReportRepository) entityToDomain(entity dao.TrainingReport) (domain.TrainingReport, error) {
+	var report domain.TrainingReport
+	err := json.Unmarshal([]byte(entity.Content), &report)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+	// 以数据库中的字段为准
+	report.Id = entity.Id
+	report.UserID = entity.UserID
+	report.Period = entity.Period
+	report.StartDate = entity.StartDate
+	report.EndDate = entity.EndDate
+	report.Ctime = time.UnixMilli(entity.Ctime)
+	return report, nil
+}
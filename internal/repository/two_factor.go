@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+)
+
+var ErrTOTPNotFound = dao.ErrDataNotFound
+
+type TwoFactorRepository interface {
+	SaveTOTP(ctx context.Context, t domain.UserTOTP) error
+	FindTOTP(ctx context.Context, userID int64) (domain.UserTOTP, error)
+	EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+}
+
+type twoFactorRepository struct {
+	dao dao.TwoFactorDAO
+}
+
+func NewTwoFactorRepository(d dao.TwoFactorDAO) TwoFactorRepository {
+	return &twoFactorRepository{
+		dao: d,
+	}
+}
+
+func (r *twoFactorRepository) SaveTOTP(ctx context.Context, t domain.UserTOTP) error {
+	return r.dao.UpsertTOTP(ctx, dao.UserTOTP{
+		UserID: t.UserID,
+		Secret: t.Secret,
+	})
+}
+
+func (r *twoFactorRepository) FindTOTP(ctx context.Context, userID int64) (domain.UserTOTP, error) {
+	t, err := r.dao.FindTOTP(ctx, userID)
+	if err != nil {
+		return domain.UserTOTP{}, err
+	}
+	return domain.UserTOTP{
+		UserID:   t.UserID,
+		Secret:   t.Secret,
+		Enabled:  t.Enabled,
+		LastStep: t.LastStep,
+	}, nil
+}
+
+func (r *twoFactorRepository) EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	return r.dao.EnableTOTP(ctx, userID, step, codeHashes)
+}
+
+func (r *twoFactorRepository) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	return r.dao.UseTOTPStep(ctx, userID, step)
+}
+
+func (r *twoFactorRepository) DeleteTOTP(ctx context.Context, userID int64) error {
+	return r.dao.DeleteTOTP(ctx, userID)
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return r.dao.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	return r.dao.UseRecoveryCode(ctx, userID, codeHash)
+}
+
+func (r *twoFactorRepository) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	return r.dao.CountRecoveryCodes(ctx, userID)
+}
@@ -13,6 +13,8 @@ import (
 var (
 	ErrUserDuplicate = dao.ErrUserDuplicate
 	ErrUserNotFound  = dao.ErrDataNotFound
+	// ErrUserStateConflict 用户当前的状态不允许这次操作
+	ErrUserStateConflict = dao.ErrUserStateConflict
 )
 
 // UserRepository 与数据库交互
@@ -23,6 +25,20 @@ type UserRepository interface {
 	FindById(ctx context.Context, id int64) (domain.User, error)
 	// Update 更新数据，只有非 0 值才会更新
 	Update(ctx context.Context, u domain.User) error
+	SoftDelete(ctx context.Context, id int64) error
+	FindDeletedBefore(ctx context.Context, before time.Time, limit int) ([]int64, error)
+	HardDelete(ctx context.Context, id int64) error
+	BindPhone(ctx context.Context, id int64, phone string) error
+	UnbindPhone(ctx context.Context, id int64) error
+	// MergeByPhone 把手机号对应的纯手机号账号合并到 primaryID 中，返回被合并的账号 ID
+	MergeByPhone(ctx context.Context, primaryID int64, phone string) (int64, error)
+	Search(ctx context.Context, keyword string, offset, limit int) ([]domain.User, error)
+	SetDisabled(ctx context.Context, id int64, disabled bool) error
+	SetRole(ctx context.Context, id int64, role domain.Role) error
+	// SetGender 单独更新性别，Update 会忽略 0，没办法改回未知
+	SetGender(ctx context.Context, id int64, gender int) error
+	// CreateWithIdentity 创建一个只绑定了第三方身份的用户，返回新用户的 ID
+	CreateWithIdentity(ctx context.Context, identity domain.UserIdentity) (int64, error)
 }
 
 // CachedUserRepository 实现 UserRepository 接口
@@ -89,6 +105,94 @@ func (ur *CachedUserRepository) Update(ctx context.Context, u domain.User) error
 	return ur.cache.Delete(ctx, u.Id)
 }
 
+func (ur *CachedUserRepository) SoftDelete(ctx context.Context, id int64) error {
+	err := ur.dao.SoftDelete(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) FindDeletedBefore(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	return ur.dao.FindDeletedBefore(ctx, before.UnixMilli(), limit)
+}
+
+func (ur *CachedUserRepository) HardDelete(ctx context.Context, id int64) error {
+	err := ur.dao.HardDelete(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) BindPhone(ctx context.Context, id int64, phone string) error {
+	err := ur.dao.BindPhone(ctx, id, phone)
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) UnbindPhone(ctx context.Context, id int64) error {
+	err := ur.dao.UnbindPhone(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) MergeByPhone(ctx context.Context, primaryID int64, phone string) (int64, error) {
+	dupID, err := ur.dao.MergeByPhone(ctx, primaryID, phone)
+	if err != nil {
+		return 0, err
+	}
+	if err = ur.cache.Delete(ctx, primaryID); err != nil {
+		return dupID, err
+	}
+	return dupID, ur.cache.Delete(ctx, dupID)
+}
+
+func (ur *CachedUserRepository) Search(ctx context.Context, keyword string, offset, limit int) ([]domain.User, error) {
+	users, err := ur.dao.Search(ctx, keyword, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.User, 0, len(users))
+	for _, u := range users {
+		res = append(res, ur.entityToDomain(u))
+	}
+	return res, nil
+}
+
+func (ur *CachedUserRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	err := ur.dao.SetDisabled(ctx, id, disabled)
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) SetRole(ctx context.Context, id int64, role domain.Role) error {
+	err := ur.dao.SetRole(ctx, id, int(role))
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) SetGender(ctx context.Context, id int64, gender int) error {
+	err := ur.dao.SetGender(ctx, id, gender)
+	if err != nil {
+		return err
+	}
+	return ur.cache.Delete(ctx, id)
+}
+
+func (ur *CachedUserRepository) CreateWithIdentity(ctx context.Context, identity domain.UserIdentity) (int64, error) {
+	return ur.dao.InsertWithIdentity(ctx, dao.User{}, identityToEntity(identity))
+}
+
+// domainToEntity 角色和禁用状态有专门的修改入口，不会通过 Update 修改
 func (ur *CachedUserRepository) domainToEntity(u domain.User) dao.User {
 	return dao.User{
 		Id: u.Id,
@@ -117,6 +221,7 @@ func (ur *CachedUserRepository) domainToEntity(u domain.User) dao.User {
 			Valid:  u.AboutMe != "",
 		},
 		Password: u.Password,
+		Gender:   u.Gender,
 		HeightCm: u.HeightCM,
 		WeightKg: u.WeightKG,
 	}
@@ -127,6 +232,10 @@ func (ur *CachedUserRepository) entityToDomain(ue dao.User) domain.User {
 	if ue.Birthday.Valid {
 		birthday = time.UnixMilli(ue.Birthday.Int64)
 	}
+	var dtime time.Time
+	if ue.Dtime > 0 {
+		dtime = time.UnixMilli(ue.Dtime)
+	}
 	return domain.User{
 		Id:       ue.Id,
 		Username: ue.Username.String,
@@ -136,8 +245,12 @@ func (ur *CachedUserRepository) entityToDomain(ue dao.User) domain.User {
 		Nickname: ue.Nickname.String,
 		AboutMe:  ue.AboutMe.String,
 		Birthday: birthday,
+		Gender:   ue.Gender,
 		WeightKG: ue.WeightKg,
 		HeightCM: ue.HeightCm,
 		Ctime:    time.UnixMilli(ue.Ctime),
+		Dtime:    dtime,
+		Role:     domain.Role(ue.Role),
+		Disabled: ue.Disabled,
 	}
 }
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var (
+	ErrIdentityDuplicate = dao.ErrIdentityDuplicate
+	ErrIdentityNotFound  = dao.ErrDataNotFound
+)
+
+type UserIdentityRepository interface {
+	Create(ctx context.Context, i domain.UserIdentity) error
+	FindByProviderSubject(ctx context.Context, provider, subject string) (domain.UserIdentity, error)
+	ListByUserID(ctx context.Context, userID int64) ([]domain.UserIdentity, error)
+	Delete(ctx context.Context, userID int64, provider string) error
+}
+
+type userIdentityRepository struct {
+	dao dao.UserIdentityDAO
+}
+
+func NewUserIdentityRepository(d dao.UserIdentityDAO) UserIdentityRepository {
+	return &userIdentityRepository{
+		dao: d,
+	}
+}
+
+func (r *userIdentityRepository) Create(ctx context.Context, i domain.UserIdentity) error {
+	return r.dao.Insert(ctx, identityToEntity(i))
+}
+
+func (r *userIdentityRepository) FindByProviderSubject(ctx context.Context, provider, subject string) (domain.UserIdentity, error) {
+	i, err := r.dao.FindByProviderSubject(ctx, provider, subject)
+	if err != nil {
+		return domain.UserIdentity{}, err
+	}
+	return identityToDomain(i), nil
+}
+
+func (r *userIdentityRepository) ListByUserID(ctx context.Context, userID int64) ([]domain.UserIdentity, error) {
+	identities, err := r.dao.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.UserIdentity, 0, len(identities))
+	for _, i := range identities {
+		res = append(res, identityToDomain(i))
+	}
+	return res, nil
+}
+
+func (r *userIdentityRepository) Delete(ctx context.Context, userID int64, provider string) error {
+	return r.dao.Delete(ctx, userID, provider)
+}
+
+func identityToEntity(i domain.UserIdentity) dao.UserIdentity {
+	return dao.UserIdentity{
+		Id:       i.Id,
+		UserID:   i.UserID,
+		Provider: i.Provider,
+		Subject:  i.Subject,
+		Nickname: i.Nickname,
+	}
+}
+
+func identityToDomain(i dao.UserIdentity) domain.UserIdentity {
+	return domain.UserIdentity{
+		Id:       i.Id,
+		UserID:   i.UserID,
+		Provider: i.Provider,
+		Subject:  i.Subject,
+		Nickname: i.Nickname,
+		Ctime:    time.UnixMilli(i.Ctime),
+	}
+}
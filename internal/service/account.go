@@ -0,0 +1,225 @@
+package service
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrUserDeleted  = errors.New("账号已注销")
+	ErrPhoneNotBind = errors.New("账号没有绑定手机号")
+	ErrInvalidCode  = errors.New("验证码错误")
+	// ErrPhoneUsed 手机号已经被另外一个账号使用，如果那是个纯手机号账号，可以合并
+	ErrPhoneUsed = errors.New("手机号已经被其他账号使用")
+	// ErrAccountStateConflict 账号当前的状态不允许这次操作，例如已经绑定过手机号、没有设置账号不能解绑
+	ErrAccountStateConflict = repository.ErrUserStateConflict
+)
+
+// 短信验证码的业务
+const (
+	BizDeleteAccount = "delete_account"
+	BizBindPhone     = "bind_phone"
+)
+
+type AccountConfig struct {
+	// GracePeriod 软删除之后多久才真正删除数据
+	GracePeriod time.Duration
+	// BatchSize 每次清理的账号数量
+	BatchSize int
+	// FilePatterns 属于用户的文件，%d 会被替换成用户 ID，例如 ./data/export/export-%d-*
+	FilePatterns []string
+}
+
+type AccountService interface {
+	// SendDeleteCode 向账号绑定的手机号发送注销验证码
+	SendDeleteCode(ctx context.Context, uid int64) error
+	// Delete 校验验证码，通过后立刻软删除账号
+	// 会话由 web 层负责清理
+	Delete(ctx context.Context, uid int64, code string) error
+	// PurgeExpired 彻底删除所有超过宽限期的账号以及账号的个人数据
+	PurgeExpired(ctx context.Context) error
+
+	// SendBindPhoneCode 向要绑定的手机号发送验证码，绑定和合并账号都使用这个验证码
+	SendBindPhoneCode(ctx context.Context, phone string) error
+	// BindPhone 给当前账号绑定手机号
+	// 先校验验证码，手机号已经属于其他账号时返回 ErrPhoneUsed，这样只有手机号的主人才能知道它注册过
+	// 验证码已经用掉了，合并账号需要重新获取验证码
+	BindPhone(ctx context.Context, uid int64, phone, code string) error
+	// UnbindPhone 校验密码后解绑手机号
+	UnbindPhone(ctx context.Context, uid int64, password string) error
+	// MergeByPhone 把手机号对应的纯手机号账号合并到当前账号，返回被合并（已注销）的账号 ID
+	MergeByPhone(ctx context.Context, uid int64, phone, code string) (int64, error)
+}
+
+type accountService struct {
+	userRepo    repository.UserRepository
+	summaryRepo repository.DailySummaryRepository
+	codeSvc     CodeService
+	cfg         AccountConfig
+	logger      logger.Logger
+}
+
+func NewAccountService(userRepo repository.UserRepository, summaryRepo repository.DailySummaryRepository,
+	codeSvc CodeService, cfg AccountConfig, l logger.Logger) AccountService {
+	return &accountService{
+		userRepo:    userRepo,
+		summaryRepo: summaryRepo,
+		codeSvc:     codeSvc,
+		cfg:         cfg,
+		logger:      l,
+	}
+}
+
+func (s *accountService) SendDeleteCode(ctx context.Context, uid int64) error {
+	phone, err := s.phone(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return s.codeSvc.Send(ctx, BizDeleteAccount, phone)
+}
+
+func (s *accountService) Delete(ctx context.Context, uid int64, code string) error {
+	phone, err := s.phone(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if err = s.verify(ctx, BizDeleteAccount, phone, code); err != nil {
+		return err
+	}
+	return s.userRepo.SoftDelete(ctx, uid)
+}
+
+func (s *accountService) phone(ctx context.Context, uid int64) (string, error) {
+	u, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	if !u.Dtime.IsZero() {
+		return "", ErrUserDeleted
+	}
+	if u.Phone == "" {
+		return "", ErrPhoneNotBind
+	}
+	return u.Phone, nil
+}
+
+func (s *accountService) PurgeExpired(ctx context.Context) error {
+	before := time.Now().Add(-s.cfg.GracePeriod)
+	for {
+		ids, err := s.userRepo.FindDeletedBefore(ctx, before, s.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for _, id := range ids {
+			err = s.purge(ctx, id)
+			if err != nil {
+				// 失败的账号还是软删除状态，下一次调度会重试
+				s.logger.Error("彻底删除账号失败",
+					logger.Field{Key: "uid", Value: id},
+					logger.Field{Key: "err", Value: err.Error()})
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		if len(ids) < s.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *accountService) purge(ctx context.Context, uid int64) error {
+	// 先删文件和缓存，用户行放到最后删
+	// 这样中途失败的话账号还能被再次找到
+	for _, pattern := range s.cfg.FilePatterns {
+		paths, err := filepath.Glob(fmt.Sprintf(pattern, uid))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	err := s.summaryRepo.DeleteCacheByUserID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.HardDelete(ctx, uid)
+}
+
+func (s *accountService) SendBindPhoneCode(ctx context.Context, phone string) error {
+	return s.codeSvc.Send(ctx, BizBindPhone, phone)
+}
+
+func (s *accountService) BindPhone(ctx context.Context, uid int64, phone, code string) error {
+	// 先校验验证码再检查手机号有没有被占用，否则不需要验证码就能探测任意手机号是否注册过
+	if err := s.verify(ctx, BizBindPhone, phone, code); err != nil {
+		return err
+	}
+	_, err := s.userRepo.FindByPhone(ctx, phone)
+	if err == nil {
+		return ErrPhoneUsed
+	}
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return err
+	}
+	err = s.userRepo.BindPhone(ctx, uid, phone)
+	if errors.Is(err, repository.ErrUserDuplicate) {
+		// 并发绑定了同一个手机号
+		return ErrPhoneUsed
+	}
+	return err
+}
+
+func (s *accountService) UnbindPhone(ctx context.Context, uid int64, password string) error {
+	u, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	if err != nil {
+		return ErrInvalidUserOrPassword
+	}
+	return s.userRepo.UnbindPhone(ctx, uid)
+}
+
+func (s *accountService) MergeByPhone(ctx context.Context, uid int64, phone, code string) (int64, error) {
+	if err := s.verify(ctx, BizBindPhone, phone, code); err != nil {
+		return 0, err
+	}
+	dupID, err := s.userRepo.MergeByPhone(ctx, uid, phone)
+	if err != nil {
+		return 0, err
+	}
+	// 两个账号的每日汇总都变了
+	for _, id := range []int64{uid, dupID} {
+		if err = s.summaryRepo.DeleteCacheByUserID(ctx, id); err != nil {
+			s.logger.Warn("合并账号后删除缓存失败",
+				logger.Field{Key: "uid", Value: id},
+				logger.Field{Key: "err", Value: err.Error()})
+		}
+	}
+	return dupID, nil
+}
+
+func (s *accountService) verify(ctx context.Context, biz, phone, code string) error {
+	ok, err := s.codeSvc.Verify(ctx, biz, phone, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return nil
+}
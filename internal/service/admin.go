@@ -0,0 +1,127 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/pkg/logger"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrCannotDisableAdmin 系统管理员（包括自己）不能被禁用，避免所有管理员被互相禁用之后没人能恢复
+var ErrCannotDisableAdmin = errors.New("不能禁用系统管理员")
+
+// ErrCannotChangeOwnRole 不能修改自己的角色，避免最后一个系统管理员把自己降级
+var ErrCannotChangeOwnRole = errors.New("不能修改自己的角色")
+
+// ErrInvalidRole 角色不存在
+var ErrInvalidRole = errors.New("角色不存在")
+
+// ErrSMSTaskNotDead 只有死信可以重新投递
+var ErrSMSTaskNotDead = errors.New("短信任务不是死信")
+
+// smsRequeueMaxAge 人工重新投递的短信最多再重试这么久
+const smsRequeueMaxAge = time.Hour
+
+// AdminService 管理后台的操作，调用方负责校验权限
+type AdminService interface {
+	// SearchUsers 搜索没有注销的用户
+	SearchUsers(ctx context.Context, keyword string, offset, limit int) ([]domain.User, error)
+	// UserSummaries 查看任意用户区间内每一天的训练汇总
+	UserSummaries(ctx context.Context, uid int64, startDate, endDate time.Time) ([]domain.DailySummary, error)
+	// SetDisabled 禁用或者启用账号，会话由 web 层负责清理
+	SetDisabled(ctx context.Context, operatorID, uid int64, disabled bool) error
+	// SetRole 修改用户的角色，鉴权时以数据库中的角色为准，不需要重新登录
+	SetRole(ctx context.Context, operatorID, uid int64, role domain.Role) error
+	// SMSDeadLetters 查看重试次数用完或者过期的短信
+	SMSDeadLetters(ctx context.Context, offset, limit int) ([]domain.SMSTask, error)
+	// RequeueSMSTask 把死信重新放回重试队列
+	RequeueSMSTask(ctx context.Context, operatorID, id int64) error
+}
+
+type adminService struct {
+	userRepo    repository.UserRepository
+	summaryRepo repository.DailySummaryRepository
+	smsTaskRepo repository.SMSTaskRepository
+	logger      logger.Logger
+}
+
+func NewAdminService(userRepo repository.UserRepository, summaryRepo repository.DailySummaryRepository,
+	smsTaskRepo repository.SMSTaskRepository, l logger.Logger) AdminService {
+	return &adminService{
+		userRepo:    userRepo,
+		summaryRepo: summaryRepo,
+		smsTaskRepo: smsTaskRepo,
+		logger:      l,
+	}
+}
+
+func (s *adminService) SearchUsers(ctx context.Context, keyword string, offset, limit int) ([]domain.User, error) {
+	return s.userRepo.Search(ctx, keyword, offset, limit)
+}
+
+func (s *adminService) UserSummaries(ctx context.Context, uid int64, startDate, endDate time.Time) ([]domain.DailySummary, error) {
+	// 确认用户存在，区分"用户不存在"和"没有训练数据"
+	_, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return s.summaryRepo.ListByUserIDAndDateRange(ctx, uid, startDate, endDate)
+}
+
+func (s *adminService) SetDisabled(ctx context.Context, operatorID, uid int64, disabled bool) error {
+	u, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if disabled && (u.Role == domain.RoleSystemAdmin || uid == operatorID) {
+		return ErrCannotDisableAdmin
+	}
+	err = s.userRepo.SetDisabled(ctx, uid, disabled)
+	if err != nil {
+		return err
+	}
+	// 管理操作要留痕
+	s.logger.Info("管理员修改了账号禁用状态",
+		logger.Field{Key: "operator", Value: operatorID},
+		logger.Field{Key: "uid", Value: uid},
+		logger.Field{Key: "disabled", Value: disabled})
+	return nil
+}
+
+func (s *adminService) SetRole(ctx context.Context, operatorID, uid int64, role domain.Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	if uid == operatorID {
+		return ErrCannotChangeOwnRole
+	}
+	err := s.userRepo.SetRole(ctx, uid, role)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("管理员修改了用户角色",
+		logger.Field{Key: "operator", Value: operatorID},
+		logger.Field{Key: "uid", Value: uid},
+		logger.Field{Key: "role", Value: role.String()})
+	return nil
+}
+
+func (s *adminService) SMSDeadLetters(ctx context.Context, offset, limit int) ([]domain.SMSTask, error) {
+	return s.smsTaskRepo.ListDead(ctx, offset, limit)
+}
+
+func (s *adminService) RequeueSMSTask(ctx context.Context, operatorID, id int64) error {
+	ok, err := s.smsTaskRepo.Requeue(ctx, id, time.Now().Add(smsRequeueMaxAge))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSMSTaskNotDead
+	}
+	s.logger.Info("管理员重新投递了短信",
+		logger.Field{Key: "operator", Value: operatorID},
+		logger.Field{Key: "id", Value: id})
+	return nil
+}
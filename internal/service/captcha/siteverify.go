@@ -0,0 +1,56 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SiteVerifyService 适配 reCAPTCHA、hCaptcha、Turnstile 这类平台
+// 它们的服务端校验接口是一样的：POST secret、response、remoteip，返回 {"success": true}
+type SiteVerifyService struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewSiteVerifyService(verifyURL, secret string, client *http.Client) Service {
+	return &SiteVerifyService{
+		url:    verifyURL,
+		secret: secret,
+		client: client,
+	}
+}
+
+func (s *SiteVerifyService) Verify(ctx context.Context, token, ip string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	form := url.Values{}
+	form.Set("secret", s.secret)
+	form.Set("response", token)
+	form.Set("remoteip", ip)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("人机验证接口返回 HTTP 状态码 %d", resp.StatusCode)
+	}
+	var res struct {
+		Success bool `json:"success"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, err
+	}
+	return res.Success, nil
+}
@@ -0,0 +1,9 @@
+package captcha
+
+import "context"
+
+// Service 校验前端人机验证组件返回的 token
+type Service interface {
+	// Verify token 有效时返回 true，ip 是用户的 IP，部分平台会用它做额外的校验
+	Verify(ctx context.Context, token, ip string) (bool, error)
+}
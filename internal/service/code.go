@@ -14,7 +14,8 @@ var (
 	ErrCodeSendTooMany = repository.ErrCodeSendTooMany
 )
 
-const codeTplId = "2044585" // 短信模板 ID，用于发送验证码短信
+// codeTemplate 验证码短信默认使用的模板，某个业务需要不同的文案时配置 code_<biz> 模板
+const codeTemplate = "code"
 
 type CodeService interface {
 	Send(ctx context.Context, biz string, phone string) error
@@ -22,16 +23,19 @@ type CodeService interface {
 }
 
 type SMSCodeService struct {
-	sms    sms.Service
-	repo   repository.CodeRepository
-	logger logger.Logger
+	sms       sms.Service
+	repo      repository.CodeRepository
+	templates *sms.TemplateRegistry
+	logger    logger.Logger
 }
 
-func NewSMSCodeService(svc sms.Service, repo repository.CodeRepository, l logger.Logger) CodeService {
+func NewSMSCodeService(svc sms.Service, repo repository.CodeRepository, templates *sms.TemplateRegistry,
+	l logger.Logger) CodeService {
 	return &SMSCodeService{
-		sms:    svc,
-		repo:   repo,
-		logger: l,
+		sms:       svc,
+		repo:      repo,
+		templates: templates,
+		logger:    l,
 	}
 }
 
@@ -43,7 +47,7 @@ func (c *SMSCodeService) Send(ctx context.Context, biz string, phone string) err
 		return err
 	}
 
-	err = c.sms.Send(ctx, codeTplId, []string{code}, phone)
+	err = c.sms.Send(ctx, c.template(biz), []string{code}, phone)
 	if err != nil {
 		c.logger.Warn("发送验证码短信失败: ", logger.Field{
 			Key:   "SMSCodeService",
@@ -53,6 +57,13 @@ func (c *SMSCodeService) Send(ctx context.Context, biz string, phone string) err
 	return err
 }
 
+func (c *SMSCodeService) template(biz string) string {
+	if name := "code_" + biz; c.templates.Has(name) {
+		return name
+	}
+	return codeTemplate
+}
+
 func (c *SMSCodeService) Verify(ctx context.Context, biz string, phone string, inputCode string) (bool, error) {
 	ok, err := c.repo.Verify(ctx, biz, phone, inputCode)
 	if errors.Is(err, repository.ErrCodeVerifyTooManyTimes) {
@@ -7,6 +7,9 @@ import (
 	"time"
 )
 
+// BizDailySummary 每日汇总数据的缓存业务标识
+const BizDailySummary = "DailySummary"
+
 type DailySummaryService interface {
 	GetByDate(ctx context.Context, biz string, userID int64, date time.Time) (domain.DailySummary, error)
 	GetByDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (domain.DailySummary, error)
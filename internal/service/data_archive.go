@@ -0,0 +1,374 @@
+package service
+
+import (
+	"archive/zip"
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/notify"
+	"badminton-backend/pkg/logger"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrArchiveNotFound = repository.ErrArchiveNotFound
+	ErrArchiveExpired  = errors.New("下载链接已过期")
+)
+
+// DataArchiveConfig 个人数据下载包相关的配置
+type DataArchiveConfig struct {
+	// Dir 压缩包在本地磁盘上的存放目录
+	Dir string
+	// TokenTTL 下载凭证的有效期，过期之后压缩包会被删除
+	TokenTTL time.Duration
+	// JobTimeout 生成一个压缩包的最长时间
+	JobTimeout time.Duration
+	// DownloadURL 通知中的下载链接，%s 会被替换成下载凭证
+	DownloadURL string
+}
+
+// DataArchiveService 把我们保存的某个用户的所有数据打包成 zip 供用户下载
+type DataArchiveService interface {
+	// CreateJob 创建打包任务，任务在后台执行，完成后通知用户
+	CreateJob(ctx context.Context, userID int64) (domain.DataArchive, error)
+	// GetJob 查询任务，只能查询自己的任务
+	GetJob(ctx context.Context, userID int64, id int64) (domain.DataArchive, error)
+	// FindByToken 根据下载凭证查找已经完成并且还没过期的压缩包
+	FindByToken(ctx context.Context, token string) (domain.DataArchive, error)
+	// CleanExpired 删除所有已经过期的压缩包
+	CleanExpired(ctx context.Context) error
+	// FailStaleJobs 把已经中断的任务标记为失败，返回标记的数量
+	FailStaleJobs(ctx context.Context) (int64, error)
+}
+
+type dataArchiveService struct {
+	repo        repository.DataArchiveRepository
+	userRepo    repository.UserRepository
+	summaryRepo repository.DailySummaryRepository
+	swingRepo   repository.SwingSpeedRepository
+	reportRepo  repository.TrainingReportRepository
+	sessionRepo repository.SessionRepository
+	historyRepo repository.LoginHistoryRepository
+	notifier    notify.Notifier
+	cfg         DataArchiveConfig
+	l           logger.Logger
+}
+
+func NewDataArchiveService(repo repository.DataArchiveRepository,
+	userRepo repository.UserRepository,
+	summaryRepo repository.DailySummaryRepository,
+	swingRepo repository.SwingSpeedRepository,
+	reportRepo repository.TrainingReportRepository,
+	sessionRepo repository.SessionRepository,
+	historyRepo repository.LoginHistoryRepository,
+	notifier notify.Notifier, cfg DataArchiveConfig, l logger.Logger) DataArchiveService {
+	return &dataArchiveService{
+		repo:        repo,
+		userRepo:    userRepo,
+		summaryRepo: summaryRepo,
+		swingRepo:   swingRepo,
+		reportRepo:  reportRepo,
+		sessionRepo: sessionRepo,
+		historyRepo: historyRepo,
+		notifier:    notifier,
+		cfg:         cfg,
+		l:           l,
+	}
+}
+
+func (s *dataArchiveService) CreateJob(ctx context.Context, userID int64) (domain.DataArchive, error) {
+	a := domain.DataArchive{
+		UserID: userID,
+		Status: domain.JobStatusPending,
+	}
+	id, err := s.repo.Create(ctx, a)
+	if err != nil {
+		return domain.DataArchive{}, err
+	}
+	a.Id = id
+	go s.runJob(a)
+	return a, nil
+}
+
+func (s *dataArchiveService) GetJob(ctx context.Context, userID int64, id int64) (domain.DataArchive, error) {
+	a, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return domain.DataArchive{}, err
+	}
+	if a.UserID != userID {
+		return domain.DataArchive{}, ErrArchiveNotFound
+	}
+	return a, nil
+}
+
+func (s *dataArchiveService) FindByToken(ctx context.Context, token string) (domain.DataArchive, error) {
+	if token == "" {
+		return domain.DataArchive{}, ErrArchiveNotFound
+	}
+	a, err := s.repo.FindByToken(ctx, token)
+	if err != nil {
+		return domain.DataArchive{}, err
+	}
+	if a.Status != domain.JobStatusDone {
+		return domain.DataArchive{}, ErrArchiveNotFound
+	}
+	if a.ExpireAt.Before(time.Now()) {
+		return domain.DataArchive{}, ErrArchiveExpired
+	}
+	return a, nil
+}
+
+func (s *dataArchiveService) CleanExpired(ctx context.Context) error {
+	const batchSize = 100
+	for {
+		archives, err := s.repo.FindExpired(ctx, time.Now(), batchSize)
+		if err != nil {
+			return err
+		}
+		for _, a := range archives {
+			if a.FilePath != "" {
+				err = os.Remove(a.FilePath)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			if err = s.repo.Delete(ctx, a.Id); err != nil {
+				return err
+			}
+		}
+		if len(archives) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (s *dataArchiveService) FailStaleJobs(ctx context.Context) (int64, error) {
+	return s.repo.FailStale(ctx, staleJobBefore(s.cfg.JobTimeout), errMsgJobInterrupted)
+}
+
+func (s *dataArchiveService) runJob(a domain.DataArchive) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.JobTimeout)
+	defer cancel()
+
+	err := s.repo.Update(ctx, domain.DataArchive{Id: a.Id, Status: domain.JobStatusRunning})
+	if err != nil {
+		s.l.Error("更新数据打包任务状态失败",
+			logger.Field{Key: "job", Value: a.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		return
+	}
+
+	path := filepath.Join(s.cfg.Dir, fmt.Sprintf("archive-%d-%d.zip", a.UserID, a.Id))
+	err = s.archiveToFile(ctx, path, a.UserID)
+	if err != nil {
+		_ = os.Remove(path)
+		s.l.Error("执行数据打包任务失败",
+			logger.Field{Key: "job", Value: a.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		err = s.repo.Update(ctx, domain.DataArchive{Id: a.Id, Status: domain.JobStatusFailed, ErrMsg: err.Error()})
+		if err != nil {
+			s.l.Error("更新数据打包任务状态失败",
+				logger.Field{Key: "job", Value: a.Id},
+				logger.Field{Key: "err", Value: err.Error()})
+		}
+		return
+	}
+
+	token, err := newArchiveToken()
+	if err == nil {
+		a.Status = domain.JobStatusDone
+		a.FilePath = path
+		a.Token = token
+		a.ExpireAt = time.Now().Add(s.cfg.TokenTTL)
+		err = s.repo.Update(ctx, a)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		s.l.Error("更新数据打包任务状态失败",
+			logger.Field{Key: "job", Value: a.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		return
+	}
+	s.notify(ctx, a)
+}
+
+func (s *dataArchiveService) notify(ctx context.Context, a domain.DataArchive) {
+	u, err := s.userRepo.FindById(ctx, a.UserID)
+	if err != nil {
+		s.l.Error("发送数据下载通知时查询用户失败",
+			logger.Field{Key: "uid", Value: a.UserID},
+			logger.Field{Key: "err", Value: err.Error()})
+		return
+	}
+	link := fmt.Sprintf(s.cfg.DownloadURL, a.Token)
+	expireAt := a.ExpireAt.Format(time.DateTime)
+	err = s.notifier.Notify(ctx, u, notify.Notification{
+		Biz:     notify.BizDataArchive,
+		Content: fmt.Sprintf("你的个人数据已经打包完成，请在 %s 之前下载：%s", expireAt, link),
+		Args:    []string{expireAt, link},
+	})
+	if err != nil {
+		s.l.Warn("发送数据下载通知失败",
+			logger.Field{Key: "uid", Value: a.UserID},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+}
+
+func (s *dataArchiveService) archiveToFile(ctx context.Context, path string, userID int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	err = s.writeArchive(ctx, zw, userID)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeArchive 每一类数据写成压缩包中的一个 JSON 文件
+func (s *dataArchiveService) writeArchive(ctx context.Context, zw *zip.Writer, userID int64) error {
+	u, err := s.userRepo.FindById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err = writeZipJSON(zw, "profile.json", newArchiveProfile(u)); err != nil {
+		return err
+	}
+
+	w, err := zw.Create("daily_summaries.json")
+	if err != nil {
+		return err
+	}
+	if err = s.writeSummaries(ctx, w, userID); err != nil {
+		return err
+	}
+
+	// 所有的历史数据
+	start, end := time.Time{}, time.Now().AddDate(1, 0, 0)
+	dists, err := s.swingRepo.ListByUserIDAndDateRange(ctx, userID, "", start, end)
+	if err != nil {
+		return err
+	}
+	if err = writeZipJSON(zw, "swing_speed.json", dists); err != nil {
+		return err
+	}
+
+	var reports []domain.TrainingReport
+	for _, period := range []string{domain.ReportPeriodWeek, domain.ReportPeriodMonth} {
+		res, err := s.reportRepo.List(ctx, userID, period, 0, math.MaxInt32)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, res...)
+	}
+	if err = writeZipJSON(zw, "training_reports.json", reports); err != nil {
+		return err
+	}
+
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err = writeZipJSON(zw, "sessions.json", sessions); err != nil {
+		return err
+	}
+
+	history, err := s.historyRepo.List(ctx, userID, 0, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	return writeZipJSON(zw, "login_history.json", history)
+}
+
+// writeSummaries 每日汇总的数据量可能比较大，逐行写成一个 JSON 数组
+func (s *dataArchiveService) writeSummaries(ctx context.Context, w io.Writer, userID int64) error {
+	rw := &ndjsonRowWriter{w: w, columns: s.summaryRepo.ExportColumns()}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := s.summaryRepo.StreamByUserIDAndDateRange(ctx, userID, time.Time{}, time.Now().AddDate(1, 0, 0),
+		func(values []any) error {
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			return rw.WriteRow(values)
+		})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// archiveProfile 用户资料，不包含密码
+type archiveProfile struct {
+	Id       int64  `json:"id"`
+	Account  string `json:"account"`
+	Phone    string `json:"phone"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+	Gender   int    `json:"gender"`
+	WeightKG int    `json:"weight_kg"`
+	HeightCM int    `json:"height_cm"`
+	Birthday string `json:"birthday"`
+	AboutMe  string `json:"about_me"`
+	Ctime    string `json:"ctime"`
+}
+
+func newArchiveProfile(u domain.User) archiveProfile {
+	p := archiveProfile{
+		Id:       u.Id,
+		Account:  u.Account,
+		Phone:    u.Phone,
+		Username: u.Username,
+		Nickname: u.Nickname,
+		Gender:   u.Gender,
+		WeightKG: u.WeightKG,
+		HeightCM: u.HeightCM,
+		AboutMe:  u.AboutMe,
+		Ctime:    u.Ctime.Format(time.DateTime),
+	}
+	if !u.Birthday.IsZero() {
+		p.Birthday = u.Birthday.Format(time.DateOnly)
+	}
+	return p
+}
+
+func newArchiveToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/pkg/logger"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrUnknownExportFormat = errors.New("未知的导出格式")
+	ErrJobNotFound         = repository.ErrJobNotFound
+	ErrJobNotReady         = errors.New("任务还没有完成")
+)
+
+// ExportConfig 导出相关的配置
+type ExportConfig struct {
+	// Dir 异步导出文件在本地磁盘上的存放目录
+	Dir string
+	// SyncMaxRows 行数超过该值的导出走异步任务
+	SyncMaxRows int64
+	// JobTimeout 单个异步任务的最长执行时间
+	JobTimeout time.Duration
+}
+
+// ExportService 把用户的每日汇总数据导出为 CSV 或者 NDJSON
+type ExportService interface {
+	// ShouldAsync 判断这次导出的数据量是否需要走异步任务
+	ShouldAsync(ctx context.Context, userID int64, startDate, endDate time.Time) (bool, error)
+	// Export 把数据流式写入 w，返回写入的行数
+	Export(ctx context.Context, w io.Writer, userID int64, format string, startDate, endDate time.Time) (int64, error)
+	// CreateJob 创建异步导出任务，任务在后台执行
+	CreateJob(ctx context.Context, userID int64, format string, startDate, endDate time.Time) (domain.ExportJob, error)
+	// GetJob 查询任务，只能查询自己的任务
+	GetJob(ctx context.Context, userID int64, id int64) (domain.ExportJob, error)
+	// FailStaleJobs 把已经中断的任务标记为失败，返回标记的数量
+	FailStaleJobs(ctx context.Context) (int64, error)
+}
+
+type exportService struct {
+	summaryRepo repository.DailySummaryRepository
+	jobRepo     repository.ExportJobRepository
+	cfg         ExportConfig
+	l           logger.Logger
+}
+
+func NewExportService(summaryRepo repository.DailySummaryRepository,
+	jobRepo repository.ExportJobRepository, cfg ExportConfig, l logger.Logger) ExportService {
+	return &exportService{
+		summaryRepo: summaryRepo,
+		jobRepo:     jobRepo,
+		cfg:         cfg,
+		l:           l,
+	}
+}
+
+func (s *exportService) ShouldAsync(ctx context.Context, userID int64, startDate, endDate time.Time) (bool, error) {
+	cnt, err := s.summaryRepo.CountByUserIDAndDateRange(ctx, userID, startDate, endDate)
+	if err != nil {
+		return false, err
+	}
+	return cnt > s.cfg.SyncMaxRows, nil
+}
+
+func (s *exportService) Export(ctx context.Context, w io.Writer, userID int64, format string, startDate, endDate time.Time) (int64, error) {
+	rw, err := newRowWriter(format, w, s.summaryRepo.ExportColumns())
+	if err != nil {
+		return 0, err
+	}
+	var cnt int64
+	err = s.summaryRepo.StreamByUserIDAndDateRange(ctx, userID, startDate, endDate, func(values []any) error {
+		cnt++
+		return rw.WriteRow(values)
+	})
+	if err != nil {
+		return cnt, err
+	}
+	return cnt, rw.Flush()
+}
+
+func (s *exportService) CreateJob(ctx context.Context, userID int64, format string, startDate, endDate time.Time) (domain.ExportJob, error) {
+	if format != domain.ExportFormatCSV && format != domain.ExportFormatNDJSON {
+		return domain.ExportJob{}, ErrUnknownExportFormat
+	}
+	j := domain.ExportJob{
+		UserID:    userID,
+		Format:    format,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Status:    domain.JobStatusPending,
+	}
+	id, err := s.jobRepo.Create(ctx, j)
+	if err != nil {
+		return domain.ExportJob{}, err
+	}
+	j.Id = id
+	go s.runJob(j)
+	return j, nil
+}
+
+func (s *exportService) GetJob(ctx context.Context, userID int64, id int64) (domain.ExportJob, error) {
+	j, err := s.jobRepo.FindById(ctx, id)
+	if err != nil {
+		return domain.ExportJob{}, err
+	}
+	if j.UserID != userID {
+		return domain.ExportJob{}, ErrJobNotFound
+	}
+	return j, nil
+}
+
+func (s *exportService) FailStaleJobs(ctx context.Context) (int64, error) {
+	return s.jobRepo.FailStale(ctx, staleJobBefore(s.cfg.JobTimeout), errMsgJobInterrupted)
+}
+
+func (s *exportService) runJob(j domain.ExportJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.JobTimeout)
+	defer cancel()
+
+	err := s.jobRepo.Update(ctx, domain.ExportJob{Id: j.Id, Status: domain.JobStatusRunning})
+	if err != nil {
+		s.l.Error("更新导出任务状态失败",
+			logger.Field{Key: "job", Value: j.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		return
+	}
+
+	path := filepath.Join(s.cfg.Dir, fmt.Sprintf("export-%d-%d.%s", j.UserID, j.Id, j.Format))
+	cnt, err := s.exportToFile(ctx, path, j)
+	if err != nil {
+		_ = os.Remove(path)
+		s.l.Error("执行导出任务失败",
+			logger.Field{Key: "job", Value: j.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		err = s.jobRepo.Update(ctx, domain.ExportJob{Id: j.Id, Status: domain.JobStatusFailed, ErrMsg: err.Error()})
+	} else {
+		err = s.jobRepo.Update(ctx, domain.ExportJob{Id: j.Id, Status: domain.JobStatusDone, RowCount: cnt, FilePath: path})
+	}
+	if err != nil {
+		s.l.Error("更新导出任务状态失败",
+			logger.Field{Key: "job", Value: j.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+}
+
+func (s *exportService) exportToFile(ctx context.Context, path string, j domain.ExportJob) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(f)
+	cnt, err := s.Export(ctx, bw, j.UserID, j.Format, j.StartDate, j.EndDate)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return cnt, err
+}
+
+// rowWriter 按某种格式逐行写出数据
+type rowWriter interface {
+	WriteRow(values []any) error
+	Flush() error
+}
+
+func newRowWriter(format string, w io.Writer, columns []string) (rowWriter, error) {
+	switch format {
+	case domain.ExportFormatCSV:
+		cw := csv.NewWriter(w)
+		// 第一行是表头
+		if err := cw.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvRowWriter{w: cw, record: make([]string, len(columns))}, nil
+	case domain.ExportFormatNDJSON:
+		return &ndjsonRowWriter{w: w, columns: columns}, nil
+	default:
+		return nil, ErrUnknownExportFormat
+	}
+}
+
+type csvRowWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvRowWriter) WriteRow(values []any) error {
+	for i, v := range values {
+		c.record[i] = fmt.Sprint(exportValue(v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonRowWriter 每行一个 JSON 对象，字段顺序与列的顺序一致
+type ndjsonRowWriter struct {
+	w       io.Writer
+	columns []string
+	buf     bytes.Buffer
+}
+
+func (n *ndjsonRowWriter) WriteRow(values []any) error {
+	n.buf.Reset()
+	n.buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(n.columns[i])
+		val, err := json.Marshal(exportValue(v))
+		if err != nil {
+			return err
+		}
+		n.buf.Write(key)
+		n.buf.WriteByte(':')
+		n.buf.Write(val)
+	}
+	n.buf.WriteString("}\n")
+	_, err := n.w.Write(n.buf.Bytes())
+	return err
+}
+
+func (n *ndjsonRowWriter) Flush() error {
+	return nil
+}
+
+// exportValue 日期按 yyyy-MM-dd 输出，其余值保持原样
+func exportValue(v any) any {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.DateOnly)
+	}
+	return v
+}
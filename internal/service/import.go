@@ -0,0 +1,458 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/pkg/logger"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownImportSource = errors.New("未知的数据来源")
+	ErrUnknownImportFormat = errors.New("未知的文件格式")
+)
+
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+
+	// importDateColumn 日期列，每一行都必须有
+	importDateColumn = "summary_date"
+)
+
+// 后台任务在进程内的 goroutine 中执行，进程重启之后没有结束的任务就不会再有人更新
+// 创建之后超过 JobTimeout 还没有结束的任务一定已经中断了，由定时任务统一标记为失败
+const (
+	// staleJobGrace 超时之后再多等一会儿，给任务最后一次更新状态留出时间
+	staleJobGrace        = time.Minute
+	errMsgJobInterrupted = "任务被中断，请重新提交"
+)
+
+// staleJobBefore 在这个时间之前创建并且还没有结束的任务可以认为已经中断
+func staleJobBefore(timeout time.Duration) time.Time {
+	return time.Now().Add(-timeout - staleJobGrace)
+}
+
+// importSetters daily_summary 的列名 -> 把值写入 DailySummary 对应字段的函数
+var importSetters = map[string]func(ds *domain.DailySummary, v int){
+	"total_duration_seconds": func(ds *domain.DailySummary, v int) { ds.Duration = v },
+	"max_swing_speed":        func(ds *domain.DailySummary, v int) { ds.MaxSpeed = v },
+	"total_swings":           func(ds *domain.DailySummary, v int) { ds.TotalSwings = v },
+	"racket_rotation_count":  func(ds *domain.DailySummary, v int) { ds.Rotation = v },
+	"forehand_clear":         func(ds *domain.DailySummary, v int) { ds.ForehandClear = v },
+	"backhand_clear":         func(ds *domain.DailySummary, v int) { ds.BackhandClear = v },
+	"forehand_lift":          func(ds *domain.DailySummary, v int) { ds.ForehandLift = v },
+	"backhand_lift":          func(ds *domain.DailySummary, v int) { ds.BackhandLift = v },
+	"forehand_net":           func(ds *domain.DailySummary, v int) { ds.ForehandNet = v },
+	"backhand_net":           func(ds *domain.DailySummary, v int) { ds.BackhandNet = v },
+	"forehand_smash":         func(ds *domain.DailySummary, v int) { ds.ForehandSmash = v },
+	"backhand_smash":         func(ds *domain.DailySummary, v int) { ds.BackhandSmash = v },
+	"forehand_drop":          func(ds *domain.DailySummary, v int) { ds.ForehandDrop = v },
+	"backhand_drop":          func(ds *domain.DailySummary, v int) { ds.BackhandDrop = v },
+	"forehand_drive":         func(ds *domain.DailySummary, v int) { ds.ForehandDrive = v },
+	"backhand_drive":         func(ds *domain.DailySummary, v int) { ds.BackhandDrive = v },
+	"pickup_count":           func(ds *domain.DailySummary, v int) { ds.PickupCount = v },
+}
+
+// ImportSource 某个第三方 App 导出文件的格式描述
+type ImportSource struct {
+	// Format csv 或者 json，json 支持数组和每行一个对象两种形式
+	Format string
+	// DateLayout 日期格式，Go 的时间格式写法，默认 2006-01-02
+	DateLayout string
+	// Columns 源文件中的列名 -> daily_summary 的列名
+	// 配置文件中的 key 会被 viper 转成小写，所以源文件的列名匹配时不区分大小写
+	Columns map[string]string
+}
+
+// ImportConfig 导入相关的配置
+type ImportConfig struct {
+	// Dir 上传文件在本地磁盘上的存放目录
+	Dir string
+	// BatchSize 每批写入数据库的行数
+	BatchSize int
+	// MaxRowErrors 最多记录多少条行错误
+	MaxRowErrors int
+	// JobTimeout 单个导入任务的最长执行时间
+	JobTimeout time.Duration
+	Sources    map[string]ImportSource
+}
+
+// ImportService 从其他 App 的导出文件中批量导入历史训练数据
+type ImportService interface {
+	// CreateJob 保存上传的文件并创建异步导入任务
+	// 同一个文件重复上传时直接返回之前的任务，第二个返回值为 true
+	CreateJob(ctx context.Context, userID int64, source string, file io.Reader) (domain.ImportJob, bool, error)
+	// GetJob 查询导入进度和行错误，只能查询自己的任务
+	GetJob(ctx context.Context, userID int64, id int64) (domain.ImportJob, error)
+	// FailStaleJobs 把已经中断的任务标记为失败，返回标记的数量
+	FailStaleJobs(ctx context.Context) (int64, error)
+}
+
+type importService struct {
+	summaryRepo repository.DailySummaryRepository
+	jobRepo     repository.ImportJobRepository
+	cfg         ImportConfig
+	l           logger.Logger
+}
+
+func NewImportService(summaryRepo repository.DailySummaryRepository,
+	jobRepo repository.ImportJobRepository, cfg ImportConfig, l logger.Logger) ImportService {
+	return &importService{
+		summaryRepo: summaryRepo,
+		jobRepo:     jobRepo,
+		cfg:         cfg,
+		l:           l,
+	}
+}
+
+func (s *importService) CreateJob(ctx context.Context, userID int64, source string, file io.Reader) (domain.ImportJob, bool, error) {
+	src, ok := s.cfg.Sources[source]
+	if !ok {
+		return domain.ImportJob{}, false, ErrUnknownImportSource
+	}
+	if src.Format != ImportFormatCSV && src.Format != ImportFormatJSON {
+		return domain.ImportJob{}, false, ErrUnknownImportFormat
+	}
+
+	path, hash, err := s.saveFile(userID, src.Format, file)
+	if err != nil {
+		return domain.ImportJob{}, false, err
+	}
+
+	// 同一个文件已经导入过或者正在导入
+	// 已经中断但还没被定时任务标记的任务按失败处理，否则这个文件再也导入不了
+	existing, err := s.jobRepo.FindByFileHash(ctx, userID, hash)
+	switch {
+	case err == nil && existing.Status != domain.JobStatusFailed && !s.stale(existing):
+		return existing, true, nil
+	case err != nil && !errors.Is(err, repository.ErrJobNotFound):
+		return domain.ImportJob{}, false, err
+	}
+
+	j := domain.ImportJob{
+		UserID:   userID,
+		Source:   source,
+		Format:   src.Format,
+		FileHash: hash,
+		FilePath: path,
+		Status:   domain.JobStatusPending,
+	}
+	j.Id, err = s.jobRepo.Create(ctx, j)
+	if err != nil {
+		return domain.ImportJob{}, false, err
+	}
+	go s.runJob(j, src)
+	return j, false, nil
+}
+
+func (s *importService) FailStaleJobs(ctx context.Context) (int64, error) {
+	return s.jobRepo.FailStale(ctx, staleJobBefore(s.cfg.JobTimeout), errMsgJobInterrupted)
+}
+
+func (s *importService) stale(j domain.ImportJob) bool {
+	return (j.Status == domain.JobStatusPending || j.Status == domain.JobStatusRunning) &&
+		j.Ctime.Before(staleJobBefore(s.cfg.JobTimeout))
+}
+
+func (s *importService) GetJob(ctx context.Context, userID int64, id int64) (domain.ImportJob, error) {
+	j, err := s.jobRepo.FindById(ctx, id)
+	if err != nil {
+		return domain.ImportJob{}, err
+	}
+	if j.UserID != userID {
+		return domain.ImportJob{}, ErrJobNotFound
+	}
+	return j, nil
+}
+
+// saveFile 把上传的文件保存到本地，文件名由内容的哈希决定，重复上传会覆盖同一个文件
+func (s *importService) saveFile(userID int64, format string, file io.Reader) (string, string, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0o755); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.CreateTemp(s.cfg.Dir, "upload-*")
+	if err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(tmp, io.TeeReader(file, h))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	path := filepath.Join(s.cfg.Dir, fmt.Sprintf("import-%d-%s.%s", userID, hash, format))
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", "", err
+	}
+	return path, hash, nil
+}
+
+func (s *importService) runJob(j domain.ImportJob, src ImportSource) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.JobTimeout)
+	defer cancel()
+
+	s.updateJob(ctx, domain.ImportJob{Id: j.Id, Status: domain.JobStatusRunning})
+	err := s.importFile(ctx, &j, src)
+	if err != nil {
+		s.l.Error("执行导入任务失败",
+			logger.Field{Key: "job", Value: j.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		j.Status = domain.JobStatusFailed
+		j.ErrMsg = err.Error()
+	} else {
+		j.Status = domain.JobStatusDone
+	}
+	s.updateJob(ctx, j)
+}
+
+func (s *importService) importFile(ctx context.Context, j *domain.ImportJob, src ImportSource) error {
+	f, err := os.Open(j.FilePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	layout := src.DateLayout
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	columns := importColumns(src.Columns)
+	batch := make([]domain.DailySummary, 0, s.cfg.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.summaryRepo.UpsertBatch(ctx, BizDailySummary, batch, columns); err != nil {
+			return err
+		}
+		j.SuccessRows += len(batch)
+		batch = batch[:0]
+		// 每写入一批就更新一次进度
+		s.updateJob(ctx, domain.ImportJob{
+			Id:          j.Id,
+			TotalRows:   j.TotalRows,
+			SuccessRows: j.SuccessRows,
+			FailedRows:  j.FailedRows,
+			Errors:      j.Errors,
+		})
+		return nil
+	}
+
+	err = readImportRows(f, src.Format, func(row int, record map[string]string) error {
+		j.TotalRows = row
+		ds, err := toDailySummary(record, src.Columns, layout)
+		if err != nil {
+			j.FailedRows++
+			if len(j.Errors) < s.cfg.MaxRowErrors {
+				j.Errors = append(j.Errors, domain.ImportRowError{Row: row, Message: err.Error()})
+			}
+			return nil
+		}
+		ds.UserID = j.UserID
+		batch = append(batch, ds)
+		if len(batch) >= s.cfg.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (s *importService) updateJob(ctx context.Context, j domain.ImportJob) {
+	err := s.jobRepo.Update(ctx, j)
+	if err != nil {
+		s.l.Error("更新导入任务失败",
+			logger.Field{Key: "job", Value: j.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+}
+
+// readImportRows 逐行读取文件，record 的 key 是小写的源文件列名
+func readImportRows(r io.Reader, format string, fn func(row int, record map[string]string) error) error {
+	switch format {
+	case ImportFormatCSV:
+		return readCSVRows(r, fn)
+	case ImportFormatJSON:
+		return readJSONRows(r, fn)
+	default:
+		return ErrUnknownImportFormat
+	}
+}
+
+func readCSVRows(r io.Reader, fn func(row int, record map[string]string) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("读取表头失败 %w", err)
+	}
+	// 去掉 Excel 导出时带上的 UTF-8 BOM
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	for row := 1; ; row++ {
+		values, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("第 %d 行格式错误 %w", row, err)
+		}
+		record := make(map[string]string, len(header))
+		for i, v := range values {
+			if i < len(header) {
+				record[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		if err = fn(row, record); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONRows 同时支持 JSON 数组和每行一个 JSON 对象两种格式
+func readJSONRows(r io.Reader, fn func(row int, record map[string]string) error) error {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	isArray, err := startsWithArray(br)
+	if err != nil {
+		return err
+	}
+	if isArray {
+		// 跳过开头的 [
+		if _, err = dec.Token(); err != nil {
+			return err
+		}
+	}
+	for row := 1; ; row++ {
+		if isArray && !dec.More() {
+			return nil
+		}
+		var obj map[string]any
+		err = dec.Decode(&obj)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("第 %d 行格式错误 %w", row, err)
+		}
+		record := make(map[string]string, len(obj))
+		for k, v := range obj {
+			record[strings.ToLower(strings.TrimSpace(k))] = jsonValueString(v)
+		}
+		if err = fn(row, record); err != nil {
+			return err
+		}
+	}
+}
+
+// startsWithArray 查看第一个非空白字符是不是 [
+func startsWithArray(br *bufio.Reader) (bool, error) {
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b == '[', br.UnreadByte()
+	}
+}
+
+func jsonValueString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// importColumns 这个来源映射到的 daily_summary 列，导入时只覆盖这些列
+// 例如只导出了挥拍次数的 App 不会把已有的时长、速度清零
+func importColumns(columns map[string]string) []string {
+	res := make([]string, 0, len(columns))
+	for _, target := range columns {
+		if _, ok := importSetters[target]; ok && !slices.Contains(res, target) {
+			res = append(res, target)
+		}
+	}
+	// 固定顺序，生成的 SQL 才稳定
+	slices.Sort(res)
+	return res
+}
+
+// toDailySummary 按列映射把一行数据转换成 DailySummary，并校验每个字段
+func toDailySummary(record map[string]string, columns map[string]string, layout string) (domain.DailySummary, error) {
+	var ds domain.DailySummary
+	var hasDate bool
+	for srcCol, value := range record {
+		target, ok := columns[srcCol]
+		if !ok {
+			// 没有配置映射的列直接忽略
+			continue
+		}
+		if target == importDateColumn {
+			// 请求中的日期都按 UTC 的零点处理，和数据库驱动的时区一致
+			date, err := time.Parse(layout, value)
+			if err != nil {
+				return domain.DailySummary{}, fmt.Errorf("列 %s 的日期 %q 格式不正确", srcCol, value)
+			}
+			if date.After(time.Now()) {
+				return domain.DailySummary{}, fmt.Errorf("列 %s 的日期 %q 晚于今天", srcCol, value)
+			}
+			ds.Date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+			hasDate = true
+			continue
+		}
+		setter, ok := importSetters[target]
+		if !ok {
+			return domain.DailySummary{}, fmt.Errorf("列 %s 映射到了不支持的字段 %s", srcCol, target)
+		}
+		if value == "" {
+			continue
+		}
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil || num < 0 || num != math.Trunc(num) || num > math.MaxInt32 {
+			return domain.DailySummary{}, fmt.Errorf("列 %s 的值 %q 不是合法的非负整数", srcCol, value)
+		}
+		setter(&ds, int(num))
+	}
+	if !hasDate {
+		return domain.DailySummary{}, errors.New("缺少日期")
+	}
+	return ds, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/captcha"
+	"badminton-backend/pkg/logger"
+	"badminton-backend/pkg/ratelimit"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrLoginTooFrequent = errors.New("登录太频繁")
+	ErrCaptchaRequired  = errors.New("需要先完成人机验证")
+	ErrLoginLocked      = errors.New("登录失败次数太多，暂时锁定")
+)
+
+// LoginLockedError 带上剩余的锁定时长，errors.Is(err, ErrLoginLocked) 为 true
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%s，%s 之后再试", ErrLoginLocked.Error(), e.RetryAfter)
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+// LoginGuardConfig 账号密码登录的防暴力破解配置
+type LoginGuardConfig struct {
+	// Account 同一个账号连续失败之后的锁定策略
+	Account repository.LockoutPolicy
+	// IP 同一个 IP 连续失败之后的锁定策略，同一个出口 IP 后面可能有很多用户，阈值要比账号高
+	IP repository.LockoutPolicy
+	// AccountCaptchaAfter 账号失败多少次之后要求人机验证，0 表示不要求
+	AccountCaptchaAfter int
+	IPCaptchaAfter      int
+}
+
+// LoginGuard 限制账号密码登录的尝试次数
+type LoginGuard interface {
+	// Check 尝试登录之前调用，被限流、被锁定或者需要人机验证时返回错误
+	Check(ctx context.Context, account string, client domain.LoginClient) error
+	// Fail 记录一次密码错误
+	Fail(ctx context.Context, account string, client domain.LoginClient)
+	// CheckSecondFactor 第二步验证之前调用，账号被锁定时返回 LoginLockedError
+	CheckSecondFactor(ctx context.Context, u domain.User) error
+	// FailSecondFactor 记录一次第二步验证失败，和密码错误计入同一个账号的失败次数
+	FailSecondFactor(ctx context.Context, u domain.User)
+	// Succeed 整个登录流程通过之后（包括第二步验证）清零账号的失败次数
+	// IP 的不清零，否则攻击者用自己的账号登录一次就能重置
+	Succeed(ctx context.Context, u domain.User)
+}
+
+type loginGuard struct {
+	repo repository.LoginAttemptRepository
+	// ipLimiter 限制同一个 IP 的登录尝试频率，不区分成功失败
+	ipLimiter ratelimit.Limiter
+	// captcha 为 nil 时不要求人机验证
+	captcha captcha.Service
+	cfg     LoginGuardConfig
+	l       logger.Logger
+}
+
+func NewLoginGuard(repo repository.LoginAttemptRepository, ipLimiter ratelimit.Limiter,
+	captchaSvc captcha.Service, cfg LoginGuardConfig, l logger.Logger) LoginGuard {
+	return &loginGuard{
+		repo:      repo,
+		ipLimiter: ipLimiter,
+		captcha:   captchaSvc,
+		cfg:       cfg,
+		l:         l,
+	}
+}
+
+// Check Redis 出错时放行，不能因为防护功能不可用导致所有人都登录不了
+func (g *loginGuard) Check(ctx context.Context, account string, client domain.LoginClient) error {
+	limited, err := g.ipLimiter.Limit(ctx, "login:attempt:ip:"+client.IP)
+	if err != nil {
+		g.l.Error("登录限流失败", logger.Field{Key: "ip", Value: client.IP}, logger.Field{Key: "err", Value: err})
+	} else if limited {
+		return ErrLoginTooFrequent
+	}
+
+	accountFailures, accountLock, err := g.repo.State(ctx, accountSubject(account))
+	if err != nil {
+		g.l.Error("查询登录失败次数失败", logger.Field{Key: "account", Value: account}, logger.Field{Key: "err", Value: err})
+		return nil
+	}
+	ipFailures, ipLock, err := g.repo.State(ctx, ipSubject(client.IP))
+	if err != nil {
+		g.l.Error("查询登录失败次数失败", logger.Field{Key: "ip", Value: client.IP}, logger.Field{Key: "err", Value: err})
+		return nil
+	}
+	if lock := max(accountLock, ipLock); lock > 0 {
+		return &LoginLockedError{RetryAfter: lock}
+	}
+
+	if g.captcha == nil || !g.captchaRequired(accountFailures, ipFailures) {
+		return nil
+	}
+	ok, err := g.captcha.Verify(ctx, client.CaptchaToken, client.IP)
+	if err != nil {
+		// 人机验证服务不可用时不能放行，否则等于没有这一层防护，这时候只能依靠锁定
+		g.l.Error("人机验证失败", logger.Field{Key: "ip", Value: client.IP}, logger.Field{Key: "err", Value: err})
+		return ErrCaptchaRequired
+	}
+	if !ok {
+		return ErrCaptchaRequired
+	}
+	return nil
+}
+
+func (g *loginGuard) captchaRequired(accountFailures, ipFailures int64) bool {
+	return (g.cfg.AccountCaptchaAfter > 0 && accountFailures >= int64(g.cfg.AccountCaptchaAfter)) ||
+		(g.cfg.IPCaptchaAfter > 0 && ipFailures >= int64(g.cfg.IPCaptchaAfter))
+}
+
+func (g *loginGuard) Fail(ctx context.Context, account string, client domain.LoginClient) {
+	g.recordFailure(ctx, accountSubject(account), g.cfg.Account,
+		logger.Field{Key: "account", Value: account}, logger.Field{Key: "ip", Value: client.IP})
+	g.recordFailure(ctx, ipSubject(client.IP), g.cfg.IP,
+		logger.Field{Key: "ip", Value: client.IP})
+}
+
+func (g *loginGuard) recordFailure(ctx context.Context, subject string, p repository.LockoutPolicy, fields ...logger.Field) {
+	failures, lock, err := g.repo.RecordFailure(ctx, subject, p)
+	if err != nil {
+		g.l.Error("记录登录失败次数失败", append(fields, logger.Field{Key: "err", Value: err})...)
+		return
+	}
+	if lock > 0 {
+		g.l.Warn("登录失败次数太多，锁定",
+			append(fields,
+				logger.Field{Key: "subject", Value: subject},
+				logger.Field{Key: "failures", Value: failures},
+				logger.Field{Key: "lock", Value: lock.String()})...)
+	}
+}
+
+// CheckSecondFactor 和 Check 一样，Redis 出错时放行
+func (g *loginGuard) CheckSecondFactor(ctx context.Context, u domain.User) error {
+	_, lock, err := g.repo.State(ctx, userSubject(u))
+	if err != nil {
+		g.l.Error("查询登录失败次数失败", logger.Field{Key: "uid", Value: u.Id}, logger.Field{Key: "err", Value: err})
+		return nil
+	}
+	if lock > 0 {
+		return &LoginLockedError{RetryAfter: lock}
+	}
+	return nil
+}
+
+func (g *loginGuard) FailSecondFactor(ctx context.Context, u domain.User) {
+	g.recordFailure(ctx, userSubject(u), g.cfg.Account, logger.Field{Key: "uid", Value: u.Id})
+}
+
+func (g *loginGuard) Succeed(ctx context.Context, u domain.User) {
+	if err := g.repo.Reset(ctx, userSubject(u)); err != nil {
+		g.l.Error("清零登录失败次数失败", logger.Field{Key: "uid", Value: u.Id}, logger.Field{Key: "err", Value: err})
+	}
+}
+
+func accountSubject(account string) string {
+	return "account:" + account
+}
+
+// userSubject 有账号的用户和密码登录共用一个计数，只用手机号、第三方登录的用户按 id 计数
+func userSubject(u domain.User) string {
+	if u.Account != "" {
+		return accountSubject(u.Account)
+	}
+	return fmt.Sprintf("user:%d", u.Id)
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
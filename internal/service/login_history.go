@@ -0,0 +1,31 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"context"
+)
+
+type LoginHistoryService interface {
+	// Record 记录一次成功的登录
+	Record(ctx context.Context, r domain.LoginRecord) error
+	List(ctx context.Context, userID int64, offset, limit int) ([]domain.LoginRecord, error)
+}
+
+type loginHistoryService struct {
+	repo repository.LoginHistoryRepository
+}
+
+func NewLoginHistoryService(repo repository.LoginHistoryRepository) LoginHistoryService {
+	return &loginHistoryService{
+		repo: repo,
+	}
+}
+
+func (s *loginHistoryService) Record(ctx context.Context, r domain.LoginRecord) error {
+	return s.repo.Create(ctx, r)
+}
+
+func (s *loginHistoryService) List(ctx context.Context, userID int64, offset, limit int) ([]domain.LoginRecord, error) {
+	return s.repo.List(ctx, userID, offset, limit)
+}
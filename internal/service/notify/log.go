@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/pkg/logger"
+	"context"
+)
+
+// LogNotifier 把通知输出到日志，本地开发时使用
+type LogNotifier struct {
+	l logger.Logger
+}
+
+func NewLogNotifier(l logger.Logger) Notifier {
+	return &LogNotifier{
+		l: l,
+	}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, u domain.User, notification Notification) error {
+	n.l.Info("发送通知",
+		logger.Field{Key: "uid", Value: u.Id},
+		logger.Field{Key: "biz", Value: notification.Biz},
+		logger.Field{Key: "content", Value: notification.Content})
+	return nil
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service/sms"
+	"context"
+)
+
+// SMSNotifier 通过短信发送通知，业务类型就是短信模板注册表中的模板名
+type SMSNotifier struct {
+	svc sms.Service
+}
+
+func NewSMSNotifier(svc sms.Service) Notifier {
+	return &SMSNotifier{
+		svc: svc,
+	}
+}
+
+func (s *SMSNotifier) Notify(ctx context.Context, u domain.User, n Notification) error {
+	// 没有绑定手机号的用户收不到短信
+	if u.Phone == "" {
+		return nil
+	}
+	return s.svc.Send(ctx, n.Biz, n.Args, u.Phone)
+}
@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"badminton-backend/internal/domain"
+	"context"
+)
+
+// 通知的业务类型
+const (
+	BizTrainingReport = "training_report"
+	BizDataArchive    = "data_archive"
+)
+
+// Notification 需要发送给用户的一条通知
+type Notification struct {
+	Biz     string   // 业务类型，短信通知根据它选择短信模板
+	Content string   // 完整的文本内容
+	Args    []string // 短信模板参数
+}
+
+// Notifier 把通知送达给用户的抽象，具体渠道可以是短信、日志、推送等
+type Notifier interface {
+	Notify(ctx context.Context, u domain.User, n Notification) error
+}
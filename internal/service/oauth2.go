@@ -0,0 +1,214 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/oauth2"
+	"context"
+	"crypto/subtle"
+	"errors"
+)
+
+var (
+	ErrUnknownProvider = errors.New("不支持的第三方平台")
+	// ErrInvalidOAuth2State state 不存在、过期或者已经用过，需要重新发起授权
+	ErrInvalidOAuth2State = errors.New("授权已失效")
+	// ErrIdentityUsed 第三方身份已经绑定了其他账号
+	ErrIdentityUsed = errors.New("第三方账号已经绑定了其他账号")
+	// ErrProviderLinked 账号已经绑定过这个平台的其他身份
+	ErrProviderLinked   = errors.New("已经绑定过该平台的账号")
+	ErrIdentityNotFound = repository.ErrIdentityNotFound
+)
+
+// OAuth2Result 授权回调的结果
+type OAuth2Result struct {
+	Purpose string
+	User    domain.User
+	// Created 第一次使用第三方登录，新注册了账号
+	Created bool
+}
+
+type OAuth2Service interface {
+	// AuthURL 生成 state 和 PKCE 参数并返回授权页面的地址，绑定时 uid 是当前登录的用户
+	// nonce 需要交给发起授权的浏览器保存，回调时原样带回
+	AuthURL(ctx context.Context, provider, purpose string, uid int64) (url string, nonce string, err error)
+	// Callback 处理授权回调，登录时返回要登录的用户，绑定时返回被绑定的用户
+	// nonce 和发起授权时返回的不一致说明回调不是在同一个浏览器里完成的
+	Callback(ctx context.Context, state, code, nonce string) (OAuth2Result, error)
+	Identities(ctx context.Context, uid int64) ([]domain.UserIdentity, error)
+	// Unlink 解绑某个平台，解绑之后账号必须还有其他登录方式
+	Unlink(ctx context.Context, uid int64, provider string) error
+}
+
+type oauth2Service struct {
+	providers    map[string]oauth2.Provider
+	stateRepo    repository.OAuth2StateRepository
+	identityRepo repository.UserIdentityRepository
+	userRepo     repository.UserRepository
+}
+
+func NewOAuth2Service(providers map[string]oauth2.Provider, stateRepo repository.OAuth2StateRepository,
+	identityRepo repository.UserIdentityRepository, userRepo repository.UserRepository) OAuth2Service {
+	return &oauth2Service{
+		providers:    providers,
+		stateRepo:    stateRepo,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+	}
+}
+
+func (s *oauth2Service) AuthURL(ctx context.Context, provider, purpose string, uid int64) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	state, err := oauth2.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oauth2.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oauth2.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	err = s.stateRepo.Store(ctx, state, domain.OAuth2State{
+		Provider:     provider,
+		Purpose:      purpose,
+		UserID:       uid,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return p.AuthURL(state, oauth2.CodeChallengeS256(verifier)), nonce, nil
+}
+
+func (s *oauth2Service) Callback(ctx context.Context, state, code, nonce string) (OAuth2Result, error) {
+	// 先取出并删除 state，nonce 不一致时这个 state 也不能再用了
+	st, err := s.stateRepo.Take(ctx, state)
+	if errors.Is(err, repository.ErrOAuth2StateNotFound) {
+		return OAuth2Result{}, ErrInvalidOAuth2State
+	}
+	if err != nil {
+		return OAuth2Result{}, err
+	}
+	if st.Nonce == "" || subtle.ConstantTimeCompare([]byte(st.Nonce), []byte(nonce)) != 1 {
+		return OAuth2Result{}, ErrInvalidOAuth2State
+	}
+	p, ok := s.providers[st.Provider]
+	if !ok {
+		// 发起授权之后平台被下线了
+		return OAuth2Result{}, ErrUnknownProvider
+	}
+	identity, err := p.Exchange(ctx, code, st.CodeVerifier)
+	if err != nil {
+		return OAuth2Result{}, err
+	}
+	di := domain.UserIdentity{
+		Provider: st.Provider,
+		Subject:  identity.Subject,
+		Nickname: identity.Nickname,
+	}
+	if st.Purpose == domain.OAuth2PurposeLink {
+		u, err := s.link(ctx, st.UserID, di)
+		return OAuth2Result{Purpose: st.Purpose, User: u}, err
+	}
+	res, err := s.login(ctx, di)
+	res.Purpose = st.Purpose
+	return res, err
+}
+
+func (s *oauth2Service) login(ctx context.Context, di domain.UserIdentity) (OAuth2Result, error) {
+	var res OAuth2Result
+	found, err := s.identityRepo.FindByProviderSubject(ctx, di.Provider, di.Subject)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrIdentityNotFound):
+		// 第一次使用这个身份登录，注册一个新账号
+		_, err = s.userRepo.CreateWithIdentity(ctx, di)
+		if err != nil && !errors.Is(err, repository.ErrIdentityDuplicate) {
+			return res, err
+		}
+		// 注册成功，或者并发的回调已经注册过了，都重新查一次
+		res.Created = err == nil
+		found, err = s.identityRepo.FindByProviderSubject(ctx, di.Provider, di.Subject)
+		if err != nil {
+			return res, err
+		}
+	default:
+		return res, err
+	}
+	u, err := s.userRepo.FindById(ctx, found.UserID)
+	if err != nil {
+		return res, err
+	}
+	if !u.Dtime.IsZero() {
+		return res, ErrUserDeleted
+	}
+	if u.Disabled {
+		return res, ErrUserDisabled
+	}
+	res.User = u
+	return res, nil
+}
+
+func (s *oauth2Service) link(ctx context.Context, uid int64, di domain.UserIdentity) (domain.User, error) {
+	u, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if !u.Dtime.IsZero() {
+		return domain.User{}, ErrUserDeleted
+	}
+	found, err := s.identityRepo.FindByProviderSubject(ctx, di.Provider, di.Subject)
+	switch {
+	case err == nil && found.UserID == uid:
+		// 重复绑定
+		return u, nil
+	case err == nil:
+		return domain.User{}, ErrIdentityUsed
+	case !errors.Is(err, repository.ErrIdentityNotFound):
+		return domain.User{}, err
+	}
+	identities, err := s.identityRepo.ListByUserID(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	for _, i := range identities {
+		if i.Provider == di.Provider {
+			return domain.User{}, ErrProviderLinked
+		}
+	}
+	di.UserID = uid
+	err = s.identityRepo.Create(ctx, di)
+	if errors.Is(err, repository.ErrIdentityDuplicate) {
+		return domain.User{}, ErrIdentityUsed
+	}
+	return u, err
+}
+
+func (s *oauth2Service) Identities(ctx context.Context, uid int64) ([]domain.UserIdentity, error) {
+	return s.identityRepo.ListByUserID(ctx, uid)
+}
+
+func (s *oauth2Service) Unlink(ctx context.Context, uid int64, provider string) error {
+	u, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.Account == "" && u.Phone == "" {
+		identities, err := s.identityRepo.ListByUserID(ctx, uid)
+		if err != nil {
+			return err
+		}
+		// 没有账号和手机号的用户只能靠第三方身份登录，至少要留一个
+		if len(identities) <= 1 {
+			return ErrAccountStateConflict
+		}
+	}
+	return s.identityRepo.Delete(ctx, uid, provider)
+}
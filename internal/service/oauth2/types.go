@@ -0,0 +1,43 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var ErrNoSubject = errors.New("第三方平台没有返回用户标识")
+
+// Identity 第三方平台返回的用户身份
+type Identity struct {
+	Subject  string
+	Nickname string
+}
+
+// Provider 一个第三方身份平台，走授权码模式并且使用 PKCE
+// 不同平台的差异（字段名、是否需要再查询用户信息）由实现处理
+type Provider interface {
+	// AuthURL 返回跳转到平台授权页面的地址
+	// state 用来防止 CSRF，codeChallenge 是 PKCE 的 S256 challenge
+	AuthURL(state, codeChallenge string) string
+	// Exchange 用授权码换取 token，再查出用户在平台上的身份
+	Exchange(ctx context.Context, code, codeVerifier string) (Identity, error)
+}
+
+// RandomString 生成 URL 安全的随机字符串，用作 state 和 code verifier
+// 32 字节编码之后是 43 个字符，满足 RFC 7636 对 code verifier 长度的要求
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallengeS256 根据 code verifier 计算 S256 challenge
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/oauth2"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockAuthServer 模拟第三方平台的 token 和 userinfo 接口
+// 授权页面由测试直接调用 authorize 代替用户完成
+type mockAuthServer struct {
+	*httptest.Server
+	mu sync.Mutex
+	// codes 授权码对应的 code challenge 和用户标识，每个授权码只能用一次
+	codes  map[string]mockGrant
+	tokens map[string]string
+	seq    int
+}
+
+type mockGrant struct {
+	challenge string
+	subject   string
+}
+
+func newMockAuthServer(t *testing.T) *mockAuthServer {
+	s := &mockAuthServer{
+		codes:  map[string]mockGrant{},
+		tokens: map[string]string{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// authorize 模拟用户在授权页面同意授权，返回带回到回调地址上的 state 和 code
+func (s *mockAuthServer) authorize(t *testing.T, authURL, subject string) (string, string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("授权地址缺少 PKCE 参数 %s", authURL)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	code := fmt.Sprintf("code-%d", s.seq)
+	s.codes[code] = mockGrant{challenge: q.Get("code_challenge"), subject: subject}
+	return q.Get("state"), code
+}
+
+func (s *mockAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	grant, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	if !ok || oauth2.CodeChallengeS256(r.PostForm.Get("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	token := "token-" + grant.subject
+	s.tokens[token] = grant.subject
+	_ = json.NewEncoder(w).Encode(map[string]string{"access_token": token, "token_type": "Bearer"})
+}
+
+func (s *mockAuthServer) userinfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subject, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"sub": subject, "name": "nick-" + subject})
+}
+
+type memOAuth2StateRepo struct {
+	mu     sync.Mutex
+	states map[string]domain.OAuth2State
+}
+
+func (r *memOAuth2StateRepo) Store(ctx context.Context, state string, s domain.OAuth2State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state] = s
+	return nil
+}
+
+func (r *memOAuth2StateRepo) Take(ctx context.Context, state string) (domain.OAuth2State, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.states[state]
+	if !ok {
+		return domain.OAuth2State{}, repository.ErrOAuth2StateNotFound
+	}
+	delete(r.states, state)
+	return s, nil
+}
+
+type memIdentityRepo struct {
+	mu         sync.Mutex
+	identities []domain.UserIdentity
+}
+
+func (r *memIdentityRepo) Create(ctx context.Context, i domain.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.identities {
+		if e.Provider == i.Provider && e.Subject == i.Subject {
+			return repository.ErrIdentityDuplicate
+		}
+	}
+	r.identities = append(r.identities, i)
+	return nil
+}
+
+func (r *memIdentityRepo) FindByProviderSubject(ctx context.Context, provider, subject string) (domain.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.identities {
+		if e.Provider == provider && e.Subject == subject {
+			return e, nil
+		}
+	}
+	return domain.UserIdentity{}, repository.ErrIdentityNotFound
+}
+
+func (r *memIdentityRepo) ListByUserID(ctx context.Context, userID int64) ([]domain.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []domain.UserIdentity
+	for _, e := range r.identities {
+		if e.UserID == userID {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (r *memIdentityRepo) Delete(ctx context.Context, userID int64, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for idx, e := range r.identities {
+		if e.UserID == userID && e.Provider == provider {
+			r.identities = append(r.identities[:idx], r.identities[idx+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrIdentityNotFound
+}
+
+// memUserRepo 只实现第三方登录用到的方法，其他方法调用时会 panic
+type memUserRepo struct {
+	repository.UserRepository
+	identities *memIdentityRepo
+	mu         sync.Mutex
+	users      map[int64]domain.User
+	nextID     int64
+}
+
+func (r *memUserRepo) FindById(ctx context.Context, id int64) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return domain.User{}, repository.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (r *memUserRepo) CreateWithIdentity(ctx context.Context, identity domain.UserIdentity) (int64, error) {
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.mu.Unlock()
+	identity.UserID = id
+	if err := r.identities.Create(ctx, identity); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[id] = domain.User{Id: id}
+	return id, nil
+}
+
+type oauth2Fixture struct {
+	svc        OAuth2Service
+	server     *mockAuthServer
+	states     *memOAuth2StateRepo
+	identities *memIdentityRepo
+	users      *memUserRepo
+}
+
+func newOAuth2Fixture(t *testing.T) *oauth2Fixture {
+	server := newMockAuthServer(t)
+	provider := oauth2.NewGenericProvider(oauth2.Config{
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		AuthURL:       server.URL + "/authorize",
+		TokenURL:      server.URL + "/token",
+		UserInfoURL:   server.URL + "/userinfo",
+		RedirectURL:   "http://localhost:8080/api/v1/oauth2/callback",
+		NicknameField: "name",
+	}, server.Client())
+	states := &memOAuth2StateRepo{states: map[string]domain.OAuth2State{}}
+	identities := &memIdentityRepo{}
+	users := &memUserRepo{
+		identities: identities,
+		users: map[int64]domain.User{
+			1: {Id: 1, Account: "alice"},
+			2: {Id: 2, Account: "bob"},
+		},
+		nextID: 100,
+	}
+	return &oauth2Fixture{
+		svc:        NewOAuth2Service(map[string]oauth2.Provider{"mock": provider}, states, identities, users),
+		server:     server,
+		states:     states,
+		identities: identities,
+		users:      users,
+	}
+}
+
+// flow 走完一次授权：生成授权地址、用户在平台同意、浏览器带着 cookie 回调
+func (f *oauth2Fixture) flow(t *testing.T, purpose string, uid int64, subject string) (OAuth2Result, error) {
+	authURL, nonce, err := f.svc.AuthURL(context.Background(), "mock", purpose, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := f.server.authorize(t, authURL, subject)
+	return f.svc.Callback(context.Background(), state, code, nonce)
+}
+
+func TestOAuth2LoginWithPKCE(t *testing.T) {
+	f := newOAuth2Fixture(t)
+
+	res, err := f.flow(t, domain.OAuth2PurposeLogin, 0, "sub-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Created || res.User.Id == 0 {
+		t.Fatalf("第一次登录应该注册新账号 %+v", res)
+	}
+	identity, err := f.identities.FindByProviderSubject(context.Background(), "mock", "sub-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != res.User.Id || identity.Nickname != "nick-sub-1" {
+		t.Fatalf("绑定的身份不对 %+v", identity)
+	}
+
+	again, err := f.flow(t, domain.OAuth2PurposeLogin, 0, "sub-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created || again.User.Id != res.User.Id {
+		t.Fatalf("再次登录应该是同一个账号 %+v", again)
+	}
+}
+
+func TestOAuth2CallbackRejectsWrongVerifier(t *testing.T) {
+	f := newOAuth2Fixture(t)
+	authURL, nonce, err := f.svc.AuthURL(context.Background(), "mock", domain.OAuth2PurposeLogin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := f.server.authorize(t, authURL, "sub-1")
+	// 授权码被截获之后，没有 code verifier 也换不到 token
+	f.states.mu.Lock()
+	st := f.states.states[state]
+	st.CodeVerifier = "intercepted"
+	f.states.states[state] = st
+	f.states.mu.Unlock()
+
+	if _, err = f.svc.Callback(context.Background(), state, code, nonce); err == nil {
+		t.Fatal("code verifier 不对时应该换取 token 失败")
+	}
+	if len(f.identities.identities) != 0 {
+		t.Fatal("换取 token 失败时不应该注册账号")
+	}
+}
+
+func TestOAuth2StateCannotBeReused(t *testing.T) {
+	f := newOAuth2Fixture(t)
+	authURL, nonce, err := f.svc.AuthURL(context.Background(), "mock", domain.OAuth2PurposeLogin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := f.server.authorize(t, authURL, "sub-1")
+	if _, err = f.svc.Callback(context.Background(), state, code, nonce); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.svc.Callback(context.Background(), state, code, nonce)
+	if !errors.Is(err, ErrInvalidOAuth2State) {
+		t.Fatalf("重复使用 state 应该失败 %v", err)
+	}
+}
+
+func TestOAuth2CallbackRequiresBrowserNonce(t *testing.T) {
+	f := newOAuth2Fixture(t)
+	// 攻击者自己发起绑定，把回调链接交给受害者的浏览器，受害者没有对应的 cookie
+	authURL, nonce, err := f.svc.AuthURL(context.Background(), "mock", domain.OAuth2PurposeLink, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := f.server.authorize(t, authURL, "attacker")
+	for _, n := range []string{"", "other-browser"} {
+		_, err = f.svc.Callback(context.Background(), state, code, n)
+		if !errors.Is(err, ErrInvalidOAuth2State) {
+			t.Fatalf("nonce %q 应该被拒绝 %v", n, err)
+		}
+	}
+	// nonce 不对时 state 已经作废，再带上正确的 nonce 也不行
+	_, err = f.svc.Callback(context.Background(), state, code, nonce)
+	if !errors.Is(err, ErrInvalidOAuth2State) {
+		t.Fatalf("被拒绝过的 state 不能再使用 %v", err)
+	}
+	if len(f.identities.identities) != 0 {
+		t.Fatal("nonce 不对时不应该绑定身份")
+	}
+}
+
+func TestOAuth2LinkAndUnlink(t *testing.T) {
+	f := newOAuth2Fixture(t)
+	ctx := context.Background()
+
+	res, err := f.flow(t, domain.OAuth2PurposeLink, 1, "alice-sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Purpose != domain.OAuth2PurposeLink || res.User.Id != 1 {
+		t.Fatalf("绑定结果不对 %+v", res)
+	}
+	identities, err := f.svc.Identities(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Subject != "alice-sub" {
+		t.Fatalf("绑定之后应该能查到身份 %+v", identities)
+	}
+
+	// 同一个身份重复绑定不报错
+	if _, err = f.flow(t, domain.OAuth2PurposeLink, 1, "alice-sub"); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个平台不能再绑定另一个身份
+	_, err = f.flow(t, domain.OAuth2PurposeLink, 1, "alice-other")
+	if !errors.Is(err, ErrProviderLinked) {
+		t.Fatalf("同一平台绑定第二个身份应该失败 %v", err)
+	}
+
+	if err = f.svc.Unlink(ctx, 1, "mock"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.svc.Unlink(ctx, 1, "mock"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("没有绑定时解绑应该返回 not found %v", err)
+	}
+}
+
+func TestOAuth2UnlinkKeepsLastLoginMethod(t *testing.T) {
+	f := newOAuth2Fixture(t)
+	res, err := f.flow(t, domain.OAuth2PurposeLogin, 0, "only-sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第三方登录注册的账号没有账号密码和手机号
+	err = f.svc.Unlink(context.Background(), res.User.Id, "mock")
+	if !errors.Is(err, ErrAccountStateConflict) {
+		t.Fatalf("唯一的登录方式不能解绑 %v", err)
+	}
+}
+
+func TestOAuth2LinkIdentityUsedByOtherUser(t *testing.T) {
+	f := newOAuth2Fixture(t)
+	if _, err := f.flow(t, domain.OAuth2PurposeLink, 2, "shared-sub"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := f.flow(t, domain.OAuth2PurposeLink, 1, "shared-sub")
+	if !errors.Is(err, ErrIdentityUsed) {
+		t.Fatalf("已经绑定到别的账号的身份不能再绑定 %v", err)
+	}
+	found, err := f.identities.FindByProviderSubject(context.Background(), "mock", "shared-sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.UserID != 2 {
+		t.Fatalf("原来的绑定不应该被改动 %+v", found)
+	}
+}
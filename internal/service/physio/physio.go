@@ -0,0 +1,126 @@
+package physio
+
+import (
+	"badminton-backend/internal/domain"
+	"math"
+	"time"
+)
+
+const (
+	AcuteDays   = 7  // 急性负荷统计天数
+	ChronicDays = 28 // 慢性负荷统计天数
+
+	defaultWeightKG = 65.0 // 用户没有填写体重时使用的默认体重
+
+	minMET = 4.0 // 休闲娱乐强度的羽毛球 MET
+	maxMET = 7.0 // 比赛强度的羽毛球 MET
+
+	refSwingsPerMinute = 15.0  // 高强度训练的参考挥拍频率（次/分钟）
+	refSwingSpeed      = 250.0 // 高强度训练的参考最大挥拍速度（km/h）
+)
+
+// Intensity 估算训练强度，取值范围 [0, 1]
+// 主要依据挥拍频率，最大挥拍速度作为辅助
+func Intensity(ds domain.DailySummary) float64 {
+	minutes := float64(ds.Duration) / 60
+	if minutes <= 0 {
+		return 0
+	}
+	rate := clamp(float64(ds.TotalSwings)/minutes/refSwingsPerMinute, 0, 1)
+	speed := clamp(float64(ds.MaxSpeed)/refSwingSpeed, 0, 1)
+	return rate*0.8 + speed*0.2
+}
+
+// Calories 估算一天训练消耗的热量（千卡）
+// 身高、体重、生日都齐全时，使用 Mifflin-St Jeor 公式算出的基础代谢修正 MET 值；
+// 否则退化为 MET × 体重 × 小时 的经典公式
+func Calories(u domain.User, ds domain.DailySummary) float64 {
+	minutes := float64(ds.Duration) / 60
+	if minutes <= 0 {
+		return 0
+	}
+	met := minMET + (maxMET-minMET)*Intensity(ds)
+
+	weight := float64(u.WeightKG)
+	if weight <= 0 {
+		weight = defaultWeightKG
+	}
+	age := Age(u.Birthday, ds.Date)
+	if u.HeightCM <= 0 || age <= 0 {
+		return round(met * weight * minutes / 60)
+	}
+	// 没有存储性别，取男女公式常数的平均值
+	bmr := 10*weight + 6.25*float64(u.HeightCM) - 5*float64(age) - 78
+	return round(met * bmr / 1440 * minutes)
+}
+
+// TrainingLoad 计算单日训练负荷
+// 参考 session-RPE 方法：训练分钟数 × 主观疲劳度，疲劳度由训练强度映射到 1~10
+func TrainingLoad(ds domain.DailySummary) float64 {
+	minutes := float64(ds.Duration) / 60
+	if minutes <= 0 {
+		return 0
+	}
+	rpe := 1 + 9*Intensity(ds)
+	return round(minutes * rpe)
+}
+
+// Workload 计算截止到 asOf（含当天）的急性负荷、慢性负荷以及急慢性负荷比
+// loads 的 key 是日期（time.DateOnly 格式），value 是当天的训练负荷
+func Workload(loads map[string]float64, asOf time.Time) (acute, chronic, ratio float64) {
+	var acuteSum, chronicSum float64
+	for i := 0; i < ChronicDays; i++ {
+		load := loads[asOf.AddDate(0, 0, -i).Format(time.DateOnly)]
+		if i < AcuteDays {
+			acuteSum += load
+		}
+		chronicSum += load
+	}
+	acute = round(acuteSum / AcuteDays)
+	chronic = round(chronicSum / ChronicDays)
+	if chronic > 0 {
+		ratio = round(acute / chronic)
+	}
+	return acute, chronic, ratio
+}
+
+// InjuryRisk 根据急慢性负荷比给出受伤风险等级
+func InjuryRisk(chronic, ratio float64) string {
+	switch {
+	case chronic <= 0:
+		return domain.InjuryRiskUnknown
+	case ratio < 0.8:
+		return domain.InjuryRiskUndertraining
+	case ratio <= 1.3:
+		return domain.InjuryRiskOptimal
+	case ratio <= 1.5:
+		return domain.InjuryRiskCaution
+	default:
+		return domain.InjuryRiskHigh
+	}
+}
+
+// Age 计算在 at 这一天的周岁年龄，生日未设置时返回 0
+func Age(birthday time.Time, at time.Time) int {
+	if birthday.IsZero() || at.IsZero() {
+		return 0
+	}
+	age := at.Year() - birthday.Year()
+	if at.Month() < birthday.Month() ||
+		(at.Month() == birthday.Month() && at.Day() < birthday.Day()) {
+		age--
+	}
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// round 保留两位小数
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,201 @@
+package async
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/sms"
+	"badminton-backend/pkg/logger"
+	"badminton-backend/pkg/ratelimit"
+	"context"
+	"sync"
+	"time"
+)
+
+// limiterKey 限制的是调用服务商的总频率，所有短信共用一个 key
+const limiterKey = "sms:send"
+
+type Config struct {
+	// MaxAttempts 最多尝试发送几次，包括第一次同步发送，用完之后进入死信
+	MaxAttempts int
+	// BaseBackoff 第一次重试的间隔，之后每次翻倍，最多 MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// MaxAge 超过这个时间还没发出去就不再重试，验证码这时候已经过期了
+	MaxAge time.Duration
+	// BatchSize 每一轮最多处理多少个任务
+	BatchSize int
+	// Workers 同时发送的任务数
+	Workers int
+	// Lease 单次发送的最长时间，抢占任务的时候也用它作为租期
+	Lease time.Duration
+}
+
+// Service 同步发送失败或者触发限流时把短信放进 MySQL 里的队列，由 Retry 在后台重试
+// 放进队列之后 Send 返回 nil，调用方当作已经发送成功处理
+type Service struct {
+	svc  sms.Service
+	repo repository.SMSTaskRepository
+	// templates 发送之前校验模板参数，参数不对的短信重试也发不出去，不能放进队列
+	templates *sms.TemplateRegistry
+	// limiter 限制调用服务商的频率，为 nil 时不限制
+	limiter ratelimit.Limiter
+	cfg     Config
+	l       logger.Logger
+}
+
+func NewService(svc sms.Service, repo repository.SMSTaskRepository, templates *sms.TemplateRegistry,
+	limiter ratelimit.Limiter, cfg Config, l logger.Logger) *Service {
+	return &Service{
+		svc:       svc,
+		repo:      repo,
+		templates: templates,
+		limiter:   limiter,
+		cfg:       cfg,
+		l:         l,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	if _, err := s.templates.Get(tplId, args); err != nil {
+		return err
+	}
+	now := time.Now()
+	task := domain.SMSTask{
+		TplId:    tplId,
+		Args:     args,
+		Numbers:  numbers,
+		Status:   domain.SMSTaskStatusPending,
+		Deadline: now.Add(s.cfg.MaxAge),
+	}
+	if s.limited(ctx) {
+		task.NextRetryTime = now.Add(s.cfg.BaseBackoff)
+		task.LastErr = "触发限流"
+		return s.enqueue(ctx, task, nil)
+	}
+
+	err := s.svc.Send(ctx, tplId, args, numbers...)
+	if err == nil {
+		return nil
+	}
+	task.Attempts = 1
+	task.NextRetryTime = now.Add(s.backoff(1))
+	task.LastErr = err.Error()
+	return s.enqueue(ctx, task, err)
+}
+
+// enqueue 放进队列失败时返回发送的错误，让调用方知道短信没有发出去
+func (s *Service) enqueue(ctx context.Context, task domain.SMSTask, sendErr error) error {
+	id, err := s.repo.Create(ctx, task)
+	if err != nil {
+		s.l.Error("短信放入重试队列失败",
+			logger.Field{Key: "tplId", Value: task.TplId},
+			logger.Field{Key: "sendErr", Value: sendErr},
+			logger.Field{Key: "err", Value: err})
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	s.l.Warn("短信放入重试队列",
+		logger.Field{Key: "id", Value: id},
+		logger.Field{Key: "tplId", Value: task.TplId},
+		logger.Field{Key: "reason", Value: task.LastErr})
+	return nil
+}
+
+// limited 限流器出错时按没有限流处理
+func (s *Service) limited(ctx context.Context) bool {
+	if s.limiter == nil {
+		return false
+	}
+	limited, err := s.limiter.Limit(ctx, limiterKey)
+	if err != nil {
+		s.l.Error("短信限流失败", logger.Field{Key: "err", Value: err})
+		return false
+	}
+	return limited
+}
+
+// Retry 重试到时间的任务，由后台任务周期性调用
+// 多个实例同时执行也没有关系，每个任务只会被一个实例抢到
+func (s *Service) Retry(ctx context.Context) error {
+	tasks, err := s.repo.FindDue(ctx, time.Now(), s.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	sem := make(chan struct{}, s.cfg.Workers)
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		if ctx.Err() != nil || s.limited(ctx) {
+			// 还在限流的话剩下的留到下一轮
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(t domain.SMSTask) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.retry(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (s *Service) retry(ctx context.Context, t domain.SMSTask) {
+	now := time.Now()
+	ok, err := s.repo.Claim(ctx, t, now.Add(s.cfg.Lease))
+	if err != nil {
+		s.l.Error("抢占短信重试任务失败", logger.Field{Key: "id", Value: t.Id}, logger.Field{Key: "err", Value: err})
+		return
+	}
+	if !ok {
+		return
+	}
+	attempts := t.Attempts + 1
+	if now.After(t.Deadline) {
+		s.dead(ctx, t, attempts, "超过最长等待时间，不再重试")
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, s.cfg.Lease)
+	err = s.svc.Send(sendCtx, t.TplId, t.Args, t.Numbers...)
+	cancel()
+	if err == nil {
+		s.update(ctx, t.Id, domain.SMSTaskStatusSucceeded, now, "")
+		s.l.Info("短信重试成功", logger.Field{Key: "id", Value: t.Id}, logger.Field{Key: "attempts", Value: attempts})
+		return
+	}
+	if attempts >= s.cfg.MaxAttempts {
+		s.dead(ctx, t, attempts, err.Error())
+		return
+	}
+	s.update(ctx, t.Id, domain.SMSTaskStatusPending, now.Add(s.backoff(attempts)), err.Error())
+}
+
+func (s *Service) dead(ctx context.Context, t domain.SMSTask, attempts int, reason string) {
+	s.update(ctx, t.Id, domain.SMSTaskStatusDead, time.Now(), reason)
+	s.l.Error("短信进入死信",
+		logger.Field{Key: "id", Value: t.Id},
+		logger.Field{Key: "tplId", Value: t.TplId},
+		logger.Field{Key: "attempts", Value: attempts},
+		logger.Field{Key: "reason", Value: reason})
+}
+
+func (s *Service) update(ctx context.Context, id int64, status string, next time.Time, lastErr string) {
+	if err := s.repo.UpdateResult(ctx, id, status, next, lastErr); err != nil {
+		// 租期过了之后任务会被重新抢到，最多是多发一次
+		s.l.Error("更新短信重试任务失败", logger.Field{Key: "id", Value: id}, logger.Field{Key: "err", Value: err})
+	}
+}
+
+// backoff 第 attempts 次失败之后等多久
+func (s *Service) backoff(attempts int) time.Duration {
+	d := s.cfg.BaseBackoff
+	for i := 1; i < attempts && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, s.cfg.MaxBackoff)
+}
@@ -0,0 +1,50 @@
+package failover
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/sms"
+	"badminton-backend/pkg/logger"
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrAllProvidersFailed 所有服务商都发送失败了
+var ErrAllProvidersFailed = errors.New("所有短信服务商都发送失败")
+
+// FailoverService 轮流使用各个服务商分摊压力，发送失败时换下一个重试
+// 超时的请求服务商可能已经发出去了，重试会导致用户收到两条，对此敏感的场景用 TimeoutFailoverService
+type FailoverService struct {
+	providers []Provider
+	idx       atomic.Uint64
+	health    *health
+}
+
+func NewFailoverService(providers []Provider, repo repository.SMSHealthRepository,
+	policy repository.SMSHealthPolicy, l logger.Logger) sms.Service {
+	return &FailoverService{
+		providers: providers,
+		health:    &health{repo: repo, policy: policy, l: l},
+	}
+}
+
+func (f *FailoverService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	start := int(f.idx.Add(1) % uint64(len(f.providers)))
+	down := f.health.down(ctx, f.providers)
+	for _, p := range order(f.providers, down, start) {
+		err := p.Svc.Send(ctx, tplId, args, numbers...)
+		if err == nil {
+			f.health.succeed(ctx, p.Name)
+			return nil
+		}
+		if ctx.Err() != nil {
+			// 调用方已经不等了，没必要再换服务商
+			return err
+		}
+		f.health.l.Warn("短信发送失败，换下一个服务商",
+			logger.Field{Key: "provider", Value: p.Name},
+			logger.Field{Key: "err", Value: err})
+		f.health.fail(ctx, p.Name, err)
+	}
+	return ErrAllProvidersFailed
+}
@@ -0,0 +1,209 @@
+package failover
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/sms/memory"
+	"badminton-backend/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errProvider = errors.New("服务商故障")
+
+// memHealthRepo 在内存中记录连续失败次数，达到阈值之后标记为不可用，不处理窗口和冷却时间
+type memHealthRepo struct {
+	mu       sync.Mutex
+	failures map[string]int
+	down     map[string]bool
+	// downErr 不为 nil 时 Down 返回它，用来模拟 Redis 故障
+	downErr error
+}
+
+func newMemHealthRepo() *memHealthRepo {
+	return &memHealthRepo{
+		failures: map[string]int{},
+		down:     map[string]bool{},
+	}
+}
+
+func (r *memHealthRepo) RecordFailure(ctx context.Context, provider string, p repository.SMSHealthPolicy) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[provider]++
+	if r.failures[provider] >= p.Threshold && !r.down[provider] {
+		r.down[provider] = true
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *memHealthRepo) RecordSuccess(ctx context.Context, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, provider)
+	return nil
+}
+
+func (r *memHealthRepo) Down(ctx context.Context, providers []string) ([]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.downErr != nil {
+		return nil, r.downErr
+	}
+	res := make([]bool, len(providers))
+	for i, p := range providers {
+		res[i] = r.down[p]
+	}
+	return res, nil
+}
+
+// newTestFailover 创建 n 个内存服务商，名字依次是 p0、p1……
+func newTestFailover(n int, threshold int) (*FailoverService, []*memory.Service, *memHealthRepo) {
+	svcs := make([]*memory.Service, 0, n)
+	providers := make([]Provider, 0, n)
+	for i := 0; i < n; i++ {
+		svc := memory.NewService()
+		svcs = append(svcs, svc)
+		providers = append(providers, Provider{Name: fmt.Sprintf("p%d", i), Svc: svc})
+	}
+	repo := newMemHealthRepo()
+	policy := repository.SMSHealthPolicy{Window: time.Minute, Threshold: threshold, Cooldown: time.Minute}
+	f := NewFailoverService(providers, repo, policy, logger.NewZapLogger(zap.NewNop()))
+	return f.(*FailoverService), svcs, repo
+}
+
+// sentBy 返回收到发给 number 的短信的服务商下标，没有服务商发送时返回 -1
+func sentBy(t *testing.T, svcs []*memory.Service, number string) int {
+	res := -1
+	for i, svc := range svcs {
+		if _, ok := svc.Last(number); ok {
+			if res >= 0 {
+				t.Fatalf("%s 被服务商 %d 和 %d 重复发送", number, res, i)
+			}
+			res = i
+		}
+	}
+	return res
+}
+
+func TestFailoverService_RoundRobin(t *testing.T) {
+	f, svcs, _ := newTestFailover(3, 3)
+	// 第一次发送从下标 1 开始，之后依次轮换
+	for i, want := range []int{1, 2, 0, 1} {
+		number := fmt.Sprintf("1380000000%d", i)
+		if err := f.Send(context.Background(), "tpl", []string{"123456"}, number); err != nil {
+			t.Fatal(err)
+		}
+		if got := sentBy(t, svcs, number); got != want {
+			t.Fatalf("第 %d 条短信由服务商 %d 发送，期望 %d", i+1, got, want)
+		}
+	}
+}
+
+func TestFailoverService_Failover(t *testing.T) {
+	f, svcs, repo := newTestFailover(3, 3)
+	svcs[1].SetError(errProvider)
+	if err := f.Send(context.Background(), "tpl", nil, "13800000000"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentBy(t, svcs, "13800000000"); got != 2 {
+		t.Fatalf("短信由服务商 %d 发送，期望换到 2", got)
+	}
+	if repo.failures["p1"] != 1 {
+		t.Fatalf("p1 的失败次数是 %d，期望 1", repo.failures["p1"])
+	}
+
+	// 恢复之后发送成功会清零失败次数
+	svcs[1].SetError(nil)
+	f.idx.Store(0)
+	if err := f.Send(context.Background(), "tpl", nil, "13800000001"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentBy(t, svcs, "13800000001"); got != 1 {
+		t.Fatalf("短信由服务商 %d 发送，期望 1", got)
+	}
+	if _, ok := repo.failures["p1"]; ok {
+		t.Fatal("发送成功之后应该清零失败次数")
+	}
+}
+
+func TestFailoverService_SkipDown(t *testing.T) {
+	f, svcs, repo := newTestFailover(3, 1)
+	svcs[1].SetError(errProvider)
+	if err := f.Send(context.Background(), "tpl", nil, "13800000000"); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.down["p1"] {
+		t.Fatal("p1 达到失败阈值之后应该标记为不可用")
+	}
+
+	// 轮到 p1 时也先用可用的服务商，p1 排到最后
+	svcs[1].SetError(nil)
+	f.idx.Store(0)
+	if err := f.Send(context.Background(), "tpl", nil, "13800000001"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentBy(t, svcs, "13800000001"); got != 2 {
+		t.Fatalf("短信由服务商 %d 发送，期望跳过不可用的 p1 用 2", got)
+	}
+
+	// 可用的服务商都失败之后，不可用的也要试一遍
+	svcs[0].SetError(errProvider)
+	svcs[2].SetError(errProvider)
+	if err := f.Send(context.Background(), "tpl", nil, "13800000002"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentBy(t, svcs, "13800000002"); got != 1 {
+		t.Fatalf("短信由服务商 %d 发送，期望最后试不可用的 p1", got)
+	}
+}
+
+func TestFailoverService_AllFailed(t *testing.T) {
+	f, svcs, repo := newTestFailover(2, 3)
+	for _, svc := range svcs {
+		svc.SetError(errProvider)
+	}
+	err := f.Send(context.Background(), "tpl", nil, "13800000000")
+	if !errors.Is(err, ErrAllProvidersFailed) {
+		t.Fatalf("返回 %v，期望 ErrAllProvidersFailed", err)
+	}
+	if repo.failures["p0"] != 1 || repo.failures["p1"] != 1 {
+		t.Fatalf("每个服务商都应该试一次，失败次数 %v", repo.failures)
+	}
+}
+
+func TestFailoverService_HealthUnavailable(t *testing.T) {
+	f, svcs, repo := newTestFailover(2, 1)
+	repo.down["p1"] = true
+	// 查询状态失败时按全部可用处理
+	repo.downErr = errors.New("redis down")
+	if err := f.Send(context.Background(), "tpl", nil, "13800000000"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentBy(t, svcs, "13800000000"); got != 1 {
+		t.Fatalf("短信由服务商 %d 发送，期望按轮换用 1", got)
+	}
+}
+
+func TestFailoverService_Canceled(t *testing.T) {
+	f, svcs, repo := newTestFailover(2, 3)
+	svcs[1].SetError(errProvider)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := f.Send(ctx, "tpl", nil, "13800000000")
+	if !errors.Is(err, errProvider) {
+		t.Fatalf("返回 %v，期望服务商的错误", err)
+	}
+	// 调用方已经不等了，不换服务商，也不算服务商的失败
+	if got := sentBy(t, svcs, "13800000000"); got != -1 {
+		t.Fatalf("调用方取消之后不应该再换服务商 %d", got)
+	}
+	if len(repo.failures) != 0 {
+		t.Fatalf("调用方取消不应该记录失败 %v", repo.failures)
+	}
+}
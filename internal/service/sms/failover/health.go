@@ -0,0 +1,72 @@
+package failover
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/sms"
+	"badminton-backend/pkg/logger"
+	"context"
+)
+
+// Provider 一个短信服务商
+type Provider struct {
+	// Name 在 Redis 中区分服务商的状态，所有实例要一致
+	Name string
+	Svc  sms.Service
+}
+
+// health 服务商是否可用保存在 Redis 中，一个实例发现服务商不可用之后所有实例一起切换
+type health struct {
+	repo   repository.SMSHealthRepository
+	policy repository.SMSHealthPolicy
+	l      logger.Logger
+}
+
+// down 查询失败时按全部可用处理，最多是多试几次
+func (h *health) down(ctx context.Context, providers []Provider) []bool {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name)
+	}
+	res, err := h.repo.Down(ctx, names)
+	if err != nil {
+		h.l.Error("查询短信服务商状态失败", logger.Field{Key: "err", Value: err})
+		return make([]bool, len(providers))
+	}
+	return res
+}
+
+func (h *health) fail(ctx context.Context, name string, cause error) {
+	tripped, err := h.repo.RecordFailure(ctx, name, h.policy)
+	if err != nil {
+		h.l.Error("记录短信服务商失败次数失败", logger.Field{Key: "provider", Value: name}, logger.Field{Key: "err", Value: err})
+		return
+	}
+	if tripped {
+		h.l.Warn("短信服务商连续失败，暂停使用",
+			logger.Field{Key: "provider", Value: name},
+			logger.Field{Key: "cooldown", Value: h.policy.Cooldown.String()},
+			logger.Field{Key: "err", Value: cause})
+	}
+}
+
+func (h *health) succeed(ctx context.Context, name string) {
+	if err := h.repo.RecordSuccess(ctx, name); err != nil {
+		h.l.Error("清零短信服务商失败次数失败", logger.Field{Key: "provider", Value: name}, logger.Field{Key: "err", Value: err})
+	}
+}
+
+// order 从 start 开始轮流排列服务商，可用的排在前面。全部不可用时也都试一遍
+func order(providers []Provider, down []bool, start int) []Provider {
+	n := len(providers)
+	res := make([]Provider, 0, n)
+	var unhealthy []Provider
+	for i := 0; i < n; i++ {
+		idx := (start + i) % n
+		if down[idx] {
+			unhealthy = append(unhealthy, providers[idx])
+			continue
+		}
+		res = append(res, providers[idx])
+	}
+	return append(res, unhealthy...)
+}
@@ -0,0 +1,46 @@
+package failover
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/sms"
+	"badminton-backend/pkg/logger"
+	"context"
+	"errors"
+	"time"
+)
+
+// TimeoutFailoverService 平时固定使用第一个可用的服务商，连续超时之后才切换到下一个
+// 超时的请求不重试，避免服务商其实已经发出去了导致用户收到两条
+type TimeoutFailoverService struct {
+	providers []Provider
+	// timeout 单次发送的超时时间
+	timeout time.Duration
+	health  *health
+}
+
+func NewTimeoutFailoverService(providers []Provider, timeout time.Duration, repo repository.SMSHealthRepository,
+	policy repository.SMSHealthPolicy, l logger.Logger) sms.Service {
+	return &TimeoutFailoverService{
+		providers: providers,
+		timeout:   timeout,
+		health:    &health{repo: repo, policy: policy, l: l},
+	}
+}
+
+func (t *TimeoutFailoverService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	down := t.health.down(ctx, t.providers)
+	p := order(t.providers, down, 0)[0]
+
+	sendCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	err := p.Svc.Send(sendCtx, tplId, args, numbers...)
+	switch {
+	case err == nil:
+		t.health.succeed(ctx, p.Name)
+	// 服务商的 SDK 不一定会保留 context 的错误，所以看 sendCtx 本身有没有超时
+	// 只有我们自己设置的超时才算，调用方取消的不算服务商的问题
+	case errors.Is(sendCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+		t.health.fail(ctx, p.Name, err)
+	}
+	return err
+}
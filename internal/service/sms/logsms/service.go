@@ -0,0 +1,25 @@
+package logsms
+
+import (
+	"badminton-backend/pkg/logger"
+	"context"
+)
+
+// Service 不真正发送短信，只把内容输出到日志，本地开发时从日志里看验证码
+type Service struct {
+	l logger.Logger
+}
+
+func NewService(l logger.Logger) *Service {
+	return &Service{
+		l: l,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.l.Info("发送短信",
+		logger.Field{Key: "tplId", Value: tplId},
+		logger.Field{Key: "args", Value: args},
+		logger.Field{Key: "numbers", Value: numbers})
+	return nil
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Message 一条发出去的短信
+type Message struct {
+	TplId  string
+	Args   []string
+	Number string
+	Ctime  time.Time
+}
+
+// Service 不真正发送短信，只记在内存中，本地开发和测试时使用
+type Service struct {
+	mu sync.Mutex
+	// last 每个手机号最后收到的一条短信
+	last map[string]Message
+	// err 不为 nil 时 Send 返回它，用来模拟服务商故障
+	err error
+}
+
+func NewService() *Service {
+	return &Service{
+		last: make(map[string]Message),
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	now := time.Now()
+	for _, number := range numbers {
+		s.last[number] = Message{TplId: tplId, Args: args, Number: number, Ctime: now}
+	}
+	return nil
+}
+
+// Last 返回手机号最后收到的一条短信
+func (s *Service) Last(number string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg, ok := s.last[number]
+	return msg, ok
+}
+
+// SetError 之后的 Send 都返回 err，传 nil 恢复正常
+func (s *Service) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
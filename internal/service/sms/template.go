@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTemplateNotFound    = errors.New("短信模板不存在")
+	ErrInvalidTemplateArgs = errors.New("短信模板参数不对")
+)
+
+// Template 业务使用的逻辑模板，各个服务商在自己的后台审核通过之后有各自的模板 ID
+type Template struct {
+	// Params 参数名，发送时的参数按这个顺序传入
+	// 腾讯云按位置替换，只用到参数的个数；阿里云按名字替换
+	Params []string
+	// Providers 服务商 -> 模板 ID
+	Providers map[string]string
+}
+
+// TemplateRegistry 逻辑模板名 -> 模板，Service.Send 的 tplId 是逻辑模板名
+type TemplateRegistry struct {
+	templates map[string]Template
+}
+
+func NewTemplateRegistry(templates map[string]Template) *TemplateRegistry {
+	return &TemplateRegistry{
+		templates: templates,
+	}
+}
+
+// Has 是否配置了这个模板
+func (r *TemplateRegistry) Has(name string) bool {
+	_, ok := r.templates[name]
+	return ok
+}
+
+// Get 返回模板，并且校验参数
+func (r *TemplateRegistry) Get(name string, args []string) (Template, error) {
+	tpl, ok := r.templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if len(args) != len(tpl.Params) {
+		return Template{}, fmt.Errorf("%w: %s 需要 %d 个参数，实际 %d 个",
+			ErrInvalidTemplateArgs, name, len(tpl.Params), len(args))
+	}
+	for i, arg := range args {
+		if arg == "" {
+			return Template{}, fmt.Errorf("%w: %s 的参数 %s 为空", ErrInvalidTemplateArgs, name, tpl.Params[i])
+		}
+	}
+	return tpl, nil
+}
+
+// ProviderID 返回模板在服务商那里的 ID
+func (r *TemplateRegistry) ProviderID(name, provider string) (string, error) {
+	tpl, ok := r.templates[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	id, ok := tpl.Providers[provider]
+	if !ok || id == "" {
+		return "", fmt.Errorf("%w: %s 没有配置 %s 的模板 ID", ErrTemplateNotFound, name, provider)
+	}
+	return id, nil
+}
+
+// CheckProvider 启动时确认每个模板都配置了服务商的模板 ID，避免切换到这个服务商之后才发现发不出去
+func (r *TemplateRegistry) CheckProvider(provider string) error {
+	for name := range r.templates {
+		if _, err := r.ProviderID(name, provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,37 +1,49 @@
 package tencent
 
 import (
+	"badminton-backend/internal/service/sms"
 	"context"
 	"fmt"
 	"github.com/ecodeclub/ekit"
 	"github.com/ecodeclub/ekit/slice"
-	sms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
+	tencentSMS "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
 )
 
+// provider 在模板注册表中的服务商名字
+const provider = "tencent"
+
 // Service 是实现了 sms.Service 接口的具体类型，用于调用腾讯云的短信服务
 // 它封装了调用腾讯云 SMS API 的相关操作
 type Service struct {
-	client   *sms.Client // 腾讯云短信服务的客户端
-	appId    *string     // 腾讯云短信应用的 ID
-	signName *string     // 短信签名名称
+	client    *tencentSMS.Client // 腾讯云短信服务的客户端
+	appId     *string            // 腾讯云短信应用的 ID
+	signName  *string            // 短信签名名称
+	templates *sms.TemplateRegistry
 }
 
 // NewService 创建并返回一个新的 Service 实例
 // client 是腾讯云短信服务的客户端，appId 和 signName 分别是短信应用 ID 和签名名称
-func NewService(c *sms.Client, appId string,
-	signName string) *Service {
+func NewService(c *tencentSMS.Client, appId string,
+	signName string, templates *sms.TemplateRegistry) *Service {
 	return &Service{
-		client:   c,
-		appId:    ekit.ToPtr[string](appId),
-		signName: ekit.ToPtr[string](signName),
+		client:    c,
+		appId:     ekit.ToPtr[string](appId),
+		signName:  ekit.ToPtr[string](signName),
+		templates: templates,
 	}
 }
 
 // Send 实现了 sms.Service 接口的 Send 方法，调用腾讯云的 API 发送短信
-// tplId 是短信模板 ID，args 是模板中占位符的参数，numbers 是目标手机号
+// tplId 是逻辑模板名，args 是模板中占位符的参数，numbers 是目标手机号
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	// 换成腾讯云的模板 ID
+	id, err := s.templates.ProviderID(tplId, provider)
+	if err != nil {
+		return err
+	}
+
 	// 创建短信请求对象
-	req := sms.NewSendSmsRequest()
+	req := tencentSMS.NewSendSmsRequest()
 
 	// 将手机号列表转换为指针切片
 	req.PhoneNumberSet = toStringPtrSlice(numbers)
@@ -46,7 +58,7 @@ func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers
 	req.TemplateParamSet = toStringPtrSlice(args)
 
 	// 设置短信模板 ID
-	req.TemplateId = ekit.ToPtr[string](tplId)
+	req.TemplateId = ekit.ToPtr[string](id)
 
 	// 设置短信签名名称
 	req.SignName = s.signName
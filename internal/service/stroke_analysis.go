@@ -0,0 +1,224 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrUnknownStrokeReference = errors.New("未知的参考画像")
+
+// strokeCategories 击球类别，每一类都由正手和反手两种击球组成
+var strokeCategories = []struct {
+	name  string
+	label string
+	// 反手杀球本身就很少见，不检查反手占比
+	checkBackhand bool
+}{
+	{name: "clear", label: "高远球", checkBackhand: true},
+	{name: "lift", label: "挑球", checkBackhand: true},
+	{name: "net", label: "搓球", checkBackhand: true},
+	{name: "smash", label: "杀球", checkBackhand: false},
+	{name: "drop", label: "吊球", checkBackhand: true},
+	{name: "drive", label: "抽球", checkBackhand: true},
+}
+
+// strokeLabels 击球类型的中文名称，用于生成建议文案
+var strokeLabels = map[string]string{
+	"forehand_clear": "正手高远球",
+	"backhand_clear": "反手高远球",
+	"forehand_lift":  "正手挑球",
+	"backhand_lift":  "反手挑球",
+	"forehand_net":   "正手搓球",
+	"backhand_net":   "反手搓球",
+	"forehand_smash": "正手杀球",
+	"backhand_smash": "反手杀球",
+	"forehand_drop":  "正手吊球",
+	"backhand_drop":  "反手吊球",
+	"forehand_drive": "正手抽球",
+	"backhand_drive": "反手抽球",
+}
+
+// StrokeAnalysisConfig 击球结构分析的阈值和教练设定的理想画像
+type StrokeAnalysisConfig struct {
+	// MinBackhandRatio 每一类击球中反手的最低占比
+	MinBackhandRatio float64
+	// MinCategoryStrokes 某一类击球总数少于该值时样本太少，不检查反手占比
+	MinCategoryStrokes int
+	// DeviationRatio 实际占比相对参考占比偏离超过该比例时给出建议
+	DeviationRatio float64
+	// MinExpectedShare 参考占比低于该值的击球类型不检查偏离
+	MinExpectedShare float64
+	// Ideal 击球类型 -> 理想占比
+	Ideal map[string]float64
+}
+
+// StrokeAnalysisService 对比用户的击球结构与参考画像，找出薄弱环节并给出建议
+type StrokeAnalysisService interface {
+	Analyze(ctx context.Context, userID int64, reference string, startDate, endDate time.Time) (domain.StrokeAnalysis, error)
+}
+
+type strokeAnalysisService struct {
+	repo repository.DailySummaryRepository
+	cfg  StrokeAnalysisConfig
+}
+
+func NewStrokeAnalysisService(repo repository.DailySummaryRepository, cfg StrokeAnalysisConfig) StrokeAnalysisService {
+	return &strokeAnalysisService{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+func (s *strokeAnalysisService) Analyze(ctx context.Context, userID int64, reference string, startDate, endDate time.Time) (domain.StrokeAnalysis, error) {
+	refMix, err := s.referenceMix(ctx, reference, startDate, endDate)
+	if err != nil {
+		return domain.StrokeAnalysis{}, err
+	}
+	agg, err := s.repo.FindByUserIDAndDateRange(ctx, userID, startDate, endDate)
+	if err != nil {
+		return domain.StrokeAnalysis{}, err
+	}
+	counts := agg.StrokeCounts()
+	res := domain.StrokeAnalysis{
+		Reference:    reference,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		TotalStrokes: sumCounts(counts),
+		Mix:          shares(counts),
+		ReferenceMix: refMix,
+	}
+	if res.TotalStrokes == 0 {
+		return res, nil
+	}
+
+	res.Recommendations = append(res.Recommendations, s.checkBackhand(counts)...)
+
+	// 网前球只看最近 7 天
+	recent, err := s.repo.FindByUserIDAndDateRange(ctx, userID, endDate.AddDate(0, 0, -6), endDate)
+	if err != nil {
+		return domain.StrokeAnalysis{}, err
+	}
+	recentCounts := recent.StrokeCounts()
+	if sumCounts(recentCounts) > 0 && recentCounts["forehand_net"]+recentCounts["backhand_net"] == 0 {
+		res.Recommendations = append(res.Recommendations, domain.StrokeRecommendation{
+			Code:       domain.RecommendationNoNetPlay,
+			Severity:   domain.SeverityWarning,
+			StrokeType: "net",
+			Message:    "最近 7 天没有网前球，建议加入搓球、推扑等网前练习",
+		})
+	}
+
+	res.Recommendations = append(res.Recommendations, s.checkDeviation(res.Mix, refMix)...)
+	return res, nil
+}
+
+// referenceMix 现在还没有俱乐部和教练与学员的关系，平均画像取所有用户的汇总（仓储层缓存一小时），
+// 理想画像由运营按教练的建议写在配置里。有了俱乐部之后再按俱乐部、教练分别计算和设置
+func (s *strokeAnalysisService) referenceMix(ctx context.Context, reference string, startDate, endDate time.Time) (map[string]float64, error) {
+	switch reference {
+	case domain.StrokeReferenceIdeal:
+		return s.cfg.Ideal, nil
+	case domain.StrokeReferenceAverage:
+		agg, err := s.repo.AggregateByDateRange(ctx, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		return shares(agg.StrokeCounts()), nil
+	default:
+		return nil, ErrUnknownStrokeReference
+	}
+}
+
+// checkBackhand 检查每一类击球中反手的占比
+func (s *strokeAnalysisService) checkBackhand(counts map[string]int) []domain.StrokeRecommendation {
+	var res []domain.StrokeRecommendation
+	for _, c := range strokeCategories {
+		if !c.checkBackhand {
+			continue
+		}
+		backhand := counts["backhand_"+c.name]
+		total := counts["forehand_"+c.name] + backhand
+		if total < s.cfg.MinCategoryStrokes {
+			continue
+		}
+		ratio := float64(backhand) / float64(total)
+		if ratio >= s.cfg.MinBackhandRatio {
+			continue
+		}
+		res = append(res, domain.StrokeRecommendation{
+			Code:       domain.RecommendationBackhandLow,
+			Severity:   domain.SeverityWarning,
+			StrokeType: c.name,
+			Actual:     roundShare(ratio),
+			Expected:   s.cfg.MinBackhandRatio,
+			Message: fmt.Sprintf("反手%s只占%s的 %.0f%%，建议加强反手%s练习",
+				c.label, c.label, ratio*100, c.label),
+		})
+	}
+	return res
+}
+
+// checkDeviation 对比每种击球类型与参考画像的占比
+func (s *strokeAnalysisService) checkDeviation(mix, refMix map[string]float64) []domain.StrokeRecommendation {
+	var res []domain.StrokeRecommendation
+	for _, strokeType := range domain.StrokeTypes {
+		expected := refMix[strokeType]
+		if expected < s.cfg.MinExpectedShare {
+			continue
+		}
+		actual := mix[strokeType]
+		switch {
+		case actual < expected*(1-s.cfg.DeviationRatio):
+			res = append(res, domain.StrokeRecommendation{
+				Code:       domain.RecommendationUnderused,
+				Severity:   domain.SeverityInfo,
+				StrokeType: strokeType,
+				Actual:     actual,
+				Expected:   expected,
+				Message:    fmt.Sprintf("%s占比 %.0f%%，低于参考的 %.0f%%", strokeLabels[strokeType], actual*100, expected*100),
+			})
+		case actual > expected*(1+s.cfg.DeviationRatio):
+			res = append(res, domain.StrokeRecommendation{
+				Code:       domain.RecommendationOverused,
+				Severity:   domain.SeverityInfo,
+				StrokeType: strokeType,
+				Actual:     actual,
+				Expected:   expected,
+				Message:    fmt.Sprintf("%s占比 %.0f%%，高于参考的 %.0f%%", strokeLabels[strokeType], actual*100, expected*100),
+			})
+		}
+	}
+	return res
+}
+
+func sumCounts(counts map[string]int) int {
+	var total int
+	for _, cnt := range counts {
+		total += cnt
+	}
+	return total
+}
+
+// shares 把击球次数换算成占总击球数的比例
+func shares(counts map[string]int) map[string]float64 {
+	total := sumCounts(counts)
+	res := make(map[string]float64, len(counts))
+	for strokeType, cnt := range counts {
+		if total > 0 {
+			res[strokeType] = roundShare(float64(cnt) / float64(total))
+		} else {
+			res[strokeType] = 0
+		}
+	}
+	return res
+}
+
+// roundShare 比例保留四位小数
+func roundShare(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/physio"
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	ErrUnknownStrokeType = errors.New("未知的击球类型")
+	ErrProfileIncomplete = errors.New("请先完善性别和生日")
+)
+
+// SwingSpeedService 挥拍速度分布的统计与分析
+type SwingSpeedService interface {
+	// Upload 上传某一天完整的挥拍速度样本，key 为击球类型，重复上传会覆盖当天的数据
+	Upload(ctx context.Context, userID int64, date time.Time, samples map[string][]int) error
+	// GetByDate 返回某一天所有击球类型的速度分布，包括整体分布
+	GetByDate(ctx context.Context, userID int64, date time.Time) ([]domain.SwingSpeedDistribution, error)
+	// Trend 返回区间内每一天的速度分布，用于观察分布随时间的变化
+	Trend(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) ([]domain.SwingSpeedDistribution, error)
+	// Percentile 计算用户在同性别、同年龄段用户中的排名
+	Percentile(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) (domain.SwingSpeedPercentile, error)
+}
+
+type swingSpeedService struct {
+	repo     repository.SwingSpeedRepository
+	userRepo repository.UserRepository
+}
+
+func NewSwingSpeedService(repo repository.SwingSpeedRepository, userRepo repository.UserRepository) SwingSpeedService {
+	return &swingSpeedService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *swingSpeedService) Upload(ctx context.Context, userID int64, date time.Time, samples map[string][]int) error {
+	dists := make([]domain.SwingSpeedDistribution, 0, len(samples)+1)
+	all := make([]int, 0)
+	for _, strokeType := range domain.StrokeTypes {
+		speeds, ok := samples[strokeType]
+		if !ok {
+			continue
+		}
+		all = append(all, speeds...)
+		dists = append(dists, newSwingSpeedDistribution(userID, date, strokeType, speeds))
+	}
+	// 出现了不认识的击球类型
+	if len(dists) != len(samples) {
+		return ErrUnknownStrokeType
+	}
+	dists = append(dists, newSwingSpeedDistribution(userID, date, domain.StrokeTypeAll, all))
+	return s.repo.Replace(ctx, userID, date, dists)
+}
+
+func (s *swingSpeedService) GetByDate(ctx context.Context, userID int64, date time.Time) ([]domain.SwingSpeedDistribution, error) {
+	return s.repo.ListByUserIDAndDateRange(ctx, userID, "", date, date)
+}
+
+func (s *swingSpeedService) Trend(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) ([]domain.SwingSpeedDistribution, error) {
+	if !isValidStrokeType(strokeType) {
+		return nil, ErrUnknownStrokeType
+	}
+	return s.repo.ListByUserIDAndDateRange(ctx, userID, strokeType, startDate, endDate)
+}
+
+func (s *swingSpeedService) Percentile(ctx context.Context, userID int64, strokeType string, startDate, endDate time.Time) (domain.SwingSpeedPercentile, error) {
+	if !isValidStrokeType(strokeType) {
+		return domain.SwingSpeedPercentile{}, ErrUnknownStrokeType
+	}
+	u, err := s.userRepo.FindById(ctx, userID)
+	if err != nil {
+		return domain.SwingSpeedPercentile{}, err
+	}
+	age := physio.Age(u.Birthday, endDate)
+	if u.Gender == domain.GenderUnknown || age <= 0 {
+		return domain.SwingSpeedPercentile{}, ErrProfileIncomplete
+	}
+
+	// 按 10 岁划分年龄段，例如 20~29 岁
+	ageFrom := age / 10 * 10
+	ageTo := ageFrom + 9
+	birthFrom := endDate.AddDate(-(ageTo + 1), 0, 1)
+	birthTo := endDate.AddDate(-ageFrom, 0, 0)
+	peers, err := s.repo.PeerSpeeds(ctx, strokeType, startDate, endDate, u.Gender, birthFrom, birthTo)
+	if err != nil {
+		return domain.SwingSpeedPercentile{}, err
+	}
+
+	res := domain.SwingSpeedPercentile{
+		StrokeType: strokeType,
+		Gender:     u.Gender,
+		AgeFrom:    ageFrom,
+		AgeTo:      ageTo,
+		PeerCount:  len(peers),
+	}
+	userSpeed, ok := peers[userID]
+	if !ok {
+		// 用户在区间内没有数据，无法排名
+		return res, nil
+	}
+	res.UserSpeed = math.Round(userSpeed*100) / 100
+
+	var below, equal int
+	for _, speed := range peers {
+		switch {
+		case speed < userSpeed:
+			below++
+		case speed == userSpeed:
+			equal++
+		}
+	}
+	// 与自己速度相同的用户各算一半，避免只有一个人时排名为 0
+	res.Percentile = math.Round((float64(below)+float64(equal)/2)/float64(len(peers))*10000) / 100
+	return res, nil
+}
+
+func isValidStrokeType(strokeType string) bool {
+	return strokeType == domain.StrokeTypeAll || slices.Contains(domain.StrokeTypes, strokeType)
+}
+
+// newSwingSpeedDistribution 根据原始速度样本计算直方图、平均值和分位数
+func newSwingSpeedDistribution(userID int64, date time.Time, strokeType string, speeds []int) domain.SwingSpeedDistribution {
+	d := domain.SwingSpeedDistribution{
+		UserID:     userID,
+		Date:       date,
+		StrokeType: strokeType,
+		Buckets:    make([]int, domain.SwingSpeedBucketCount),
+		Count:      len(speeds),
+	}
+	if len(speeds) == 0 {
+		return d
+	}
+
+	sorted := slices.Clone(speeds)
+	slices.Sort(sorted)
+	var sum int
+	for _, speed := range sorted {
+		sum += speed
+		idx := speed / domain.SwingSpeedBucketWidth
+		idx = max(0, min(idx, domain.SwingSpeedBucketCount-1))
+		d.Buckets[idx]++
+	}
+	d.AvgSpeed = math.Round(float64(sum)/float64(len(sorted))*100) / 100
+	d.P50Speed = percentile(sorted, 50)
+	d.P90Speed = percentile(sorted, 90)
+	return d
+}
+
+// percentile 最近秩法计算分位数，sorted 必须是升序且非空
+func percentile(sorted []int, p int) int {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
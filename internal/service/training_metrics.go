@@ -0,0 +1,65 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/physio"
+	"context"
+	"time"
+)
+
+// TrainingMetricsService 结合用户的身体数据计算热量消耗、训练负荷以及急慢性负荷比
+type TrainingMetricsService interface {
+	GetByDate(ctx context.Context, userID int64, date time.Time) (domain.TrainingMetrics, error)
+	// GetByDateRange 热量和训练负荷为区间内的累计值，急慢性负荷比以 endDate 为准
+	GetByDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (domain.TrainingMetrics, error)
+}
+
+type trainingMetricsService struct {
+	summaryRepo repository.DailySummaryRepository
+	userRepo    repository.UserRepository
+}
+
+func NewTrainingMetricsService(summaryRepo repository.DailySummaryRepository,
+	userRepo repository.UserRepository) TrainingMetricsService {
+	return &trainingMetricsService{
+		summaryRepo: summaryRepo,
+		userRepo:    userRepo,
+	}
+}
+
+func (s *trainingMetricsService) GetByDate(ctx context.Context, userID int64, date time.Time) (domain.TrainingMetrics, error) {
+	return s.GetByDateRange(ctx, userID, date, date)
+}
+
+func (s *trainingMetricsService) GetByDateRange(ctx context.Context, userID int64, startDate, endDate time.Time) (domain.TrainingMetrics, error) {
+	u, err := s.userRepo.FindById(ctx, userID)
+	if err != nil {
+		return domain.TrainingMetrics{}, err
+	}
+
+	// 计算急慢性负荷比需要 endDate 之前 28 天的数据，一次查询把两部分都取出来
+	from := endDate.AddDate(0, 0, -(physio.ChronicDays - 1))
+	if startDate.Before(from) {
+		from = startDate
+	}
+	summaries, err := s.summaryRepo.ListByUserIDAndDateRange(ctx, userID, from, endDate)
+	if err != nil {
+		return domain.TrainingMetrics{}, err
+	}
+
+	var res domain.TrainingMetrics
+	loads := make(map[string]float64, len(summaries))
+	for _, ds := range summaries {
+		load := physio.TrainingLoad(ds)
+		loads[ds.Date.Format(time.DateOnly)] += load
+		if ds.Date.Before(startDate) {
+			continue
+		}
+		res.Calories += physio.Calories(u, ds)
+		res.TrainingLoad += load
+	}
+	res.AcuteLoad, res.ChronicLoad, res.ACWR = physio.Workload(loads, endDate)
+	res.InjuryRisk = physio.InjuryRisk(res.ChronicLoad, res.ACWR)
+	return res, nil
+}
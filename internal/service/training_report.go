@@ -0,0 +1,287 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service/notify"
+	"badminton-backend/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownReportPeriod = errors.New("未知的报告周期")
+	ErrReportNotFound      = repository.ErrReportNotFound
+)
+
+// streakLookbackDays 计算连续训练天数时最多往前看多少天
+const streakLookbackDays = 365
+
+// TrainingReportService 生成并查询周报/月报
+type TrainingReportService interface {
+	// Generate 生成某个用户某个周期的报告，报告已经存在时直接返回已有的报告
+	// 第二个返回值表示报告是否是本次新生成的
+	Generate(ctx context.Context, userID int64, period string, startDate time.Time) (domain.TrainingReport, bool, error)
+	// GenerateAll 为该周期内有训练数据的所有用户生成报告，并通知新生成报告的用户
+	GenerateAll(ctx context.Context, period string, startDate time.Time) error
+	Latest(ctx context.Context, userID int64, period string) (domain.TrainingReport, error)
+	List(ctx context.Context, userID int64, period string, offset, limit int) ([]domain.TrainingReport, error)
+}
+
+type trainingReportService struct {
+	repo        repository.TrainingReportRepository
+	summaryRepo repository.DailySummaryRepository
+	userRepo    repository.UserRepository
+	notifier    notify.Notifier
+	l           logger.Logger
+}
+
+func NewTrainingReportService(repo repository.TrainingReportRepository,
+	summaryRepo repository.DailySummaryRepository,
+	userRepo repository.UserRepository,
+	notifier notify.Notifier,
+	l logger.Logger) TrainingReportService {
+	return &trainingReportService{
+		repo:        repo,
+		summaryRepo: summaryRepo,
+		userRepo:    userRepo,
+		notifier:    notifier,
+		l:           l,
+	}
+}
+
+// PeriodRange 返回 t 所在周期的第一天和最后一天，周以周一为第一天
+func PeriodRange(period string, t time.Time) (time.Time, time.Time, error) {
+	// t 所在时区的日期，转换成 UTC 的零点，和请求中解析出来的日期一致
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case domain.ReportPeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 6), nil
+	case domain.ReportPeriodMonth:
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, -1), nil
+	default:
+		return time.Time{}, time.Time{}, ErrUnknownReportPeriod
+	}
+}
+
+func (s *trainingReportService) Generate(ctx context.Context, userID int64, period string, startDate time.Time) (domain.TrainingReport, bool, error) {
+	start, end, err := PeriodRange(period, startDate)
+	if err != nil {
+		return domain.TrainingReport{}, false, err
+	}
+	report, err := s.repo.FindByPeriod(ctx, userID, period, start)
+	switch {
+	case err == nil:
+		return report, false, nil
+	case !errors.Is(err, repository.ErrReportNotFound):
+		return domain.TrainingReport{}, false, err
+	}
+
+	report, err = s.build(ctx, userID, period, start, end)
+	if err != nil {
+		return domain.TrainingReport{}, false, err
+	}
+	created, err := s.repo.Create(ctx, report)
+	if err != nil {
+		return domain.TrainingReport{}, false, err
+	}
+	if !created {
+		// 并发生成时别的实例已经写入了，以数据库中的为准
+		report, err = s.repo.FindByPeriod(ctx, userID, period, start)
+		return report, false, err
+	}
+	return report, true, nil
+}
+
+func (s *trainingReportService) GenerateAll(ctx context.Context, period string, startDate time.Time) error {
+	start, end, err := PeriodRange(period, startDate)
+	if err != nil {
+		return err
+	}
+	uids, err := s.summaryRepo.FindUserIDsByDateRange(ctx, start, end)
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		report, created, err := s.Generate(ctx, uid, period, start)
+		if err != nil {
+			// 单个用户失败不影响其他用户，下一次调度会重试
+			s.l.Error("生成训练报告失败",
+				logger.Field{Key: "uid", Value: uid},
+				logger.Field{Key: "period", Value: period},
+				logger.Field{Key: "err", Value: err.Error()})
+			continue
+		}
+		if !created {
+			continue
+		}
+		s.notify(ctx, report)
+	}
+	return nil
+}
+
+func (s *trainingReportService) Latest(ctx context.Context, userID int64, period string) (domain.TrainingReport, error) {
+	reports, err := s.List(ctx, userID, period, 0, 1)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+	if len(reports) == 0 {
+		return domain.TrainingReport{}, ErrReportNotFound
+	}
+	return reports[0], nil
+}
+
+func (s *trainingReportService) List(ctx context.Context, userID int64, period string, offset, limit int) ([]domain.TrainingReport, error) {
+	if period != domain.ReportPeriodWeek && period != domain.ReportPeriodMonth {
+		return nil, ErrUnknownReportPeriod
+	}
+	return s.repo.List(ctx, userID, period, offset, limit)
+}
+
+func (s *trainingReportService) build(ctx context.Context, userID int64, period string, start, end time.Time) (domain.TrainingReport, error) {
+	totals, err := s.summaryRepo.FindByUserIDAndDateRange(ctx, userID, start, end)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+	prevStart, prevEnd, _ := PeriodRange(period, start.AddDate(0, 0, -1))
+	prevTotals, err := s.summaryRepo.FindByUserIDAndDateRange(ctx, userID, prevStart, prevEnd)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+	days, err := s.summaryRepo.ListByUserIDAndDateRange(ctx, userID, end.AddDate(0, 0, -streakLookbackDays), end)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+	prevBest, err := s.summaryRepo.MaxByUserIDBefore(ctx, userID, start)
+	if err != nil {
+		return domain.TrainingReport{}, err
+	}
+
+	trained := make(map[string]bool, len(days))
+	var activeDays, prevActiveDays int
+	var current []domain.DailySummary
+	for _, ds := range days {
+		if ds.Duration <= 0 && ds.TotalSwings <= 0 {
+			continue
+		}
+		trained[ds.Date.Format(time.DateOnly)] = true
+		switch {
+		case !ds.Date.Before(start):
+			activeDays++
+			current = append(current, ds)
+		case !ds.Date.Before(prevStart):
+			prevActiveDays++
+		}
+	}
+
+	return domain.TrainingReport{
+		UserID:           userID,
+		Period:           period,
+		StartDate:        start,
+		EndDate:          end,
+		Totals:           totals,
+		ActiveDays:       activeDays,
+		DurationChange:   changePercent(totals.Duration, prevTotals.Duration),
+		SwingsChange:     changePercent(totals.TotalSwings, prevTotals.TotalSwings),
+		ActiveDaysChange: activeDays - prevActiveDays,
+		Records:          personalRecords(current, prevBest),
+		Streak:           streak(trained, end),
+	}, nil
+}
+
+func (s *trainingReportService) notify(ctx context.Context, report domain.TrainingReport) {
+	u, err := s.userRepo.FindById(ctx, report.UserID)
+	if err != nil {
+		s.l.Error("发送训练报告通知时查询用户失败",
+			logger.Field{Key: "uid", Value: report.UserID},
+			logger.Field{Key: "err", Value: err.Error()})
+		return
+	}
+	// 报告在周期结束之后才生成，收到通知时已经是下一个周期了
+	periodName := "上周"
+	if report.Period == domain.ReportPeriodMonth {
+		periodName = "上月"
+	}
+	minutes := strconv.Itoa(report.Totals.Duration / 60)
+	err = s.notifier.Notify(ctx, u, notify.Notification{
+		Biz: notify.BizTrainingReport,
+		Content: fmt.Sprintf("%s你训练了 %d 天，共 %s 分钟，挥拍 %d 次，已连续训练 %d 天",
+			periodName, report.ActiveDays, minutes, report.Totals.TotalSwings, report.Streak),
+		Args: []string{periodName, strconv.Itoa(report.ActiveDays), minutes,
+			strconv.Itoa(report.Totals.TotalSwings)},
+	})
+	if err != nil {
+		s.l.Warn("发送训练报告通知失败",
+			logger.Field{Key: "uid", Value: report.UserID},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+}
+
+// changePercent 相对上一周期的变化百分比
+func changePercent(cur, prev int) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return math.Round(float64(cur-prev)/float64(prev)*10000) / 100
+}
+
+// personalRecords 找出本周期内超过历史最好成绩的指标
+func personalRecords(days []domain.DailySummary, prevBest domain.DailySummary) []domain.PersonalRecord {
+	var best struct {
+		swings, duration, speed domain.DailySummary
+	}
+	for _, ds := range days {
+		if ds.TotalSwings > best.swings.TotalSwings {
+			best.swings = ds
+		}
+		if ds.Duration > best.duration.Duration {
+			best.duration = ds
+		}
+		if ds.MaxSpeed > best.speed.MaxSpeed {
+			best.speed = ds
+		}
+	}
+
+	var res []domain.PersonalRecord
+	if best.swings.TotalSwings > prevBest.TotalSwings {
+		res = append(res, domain.PersonalRecord{
+			Metric:   domain.RecordMaxDailySwings,
+			Value:    best.swings.TotalSwings,
+			Previous: prevBest.TotalSwings,
+			Date:     best.swings.Date,
+		})
+	}
+	if best.duration.Duration > prevBest.Duration {
+		res = append(res, domain.PersonalRecord{
+			Metric:   domain.RecordMaxDailyDuration,
+			Value:    best.duration.Duration,
+			Previous: prevBest.Duration,
+			Date:     best.duration.Date,
+		})
+	}
+	if best.speed.MaxSpeed > prevBest.MaxSpeed {
+		res = append(res, domain.PersonalRecord{
+			Metric:   domain.RecordMaxSwingSpeed,
+			Value:    best.speed.MaxSpeed,
+			Previous: prevBest.MaxSpeed,
+			Date:     best.speed.Date,
+		})
+	}
+	return res
+}
+
+// streak 从 end 往前数连续有训练的天数
+func streak(trained map[string]bool, end time.Time) int {
+	var cnt int
+	for day := end; trained[day.Format(time.DateOnly)]; day = day.AddDate(0, 0, -1) {
+		cnt++
+	}
+	return cnt
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/pkg/logger"
+	"badminton-backend/pkg/totp"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BizTwoFactor 短信作为第二步验证时验证码的业务
+const BizTwoFactor = "two_factor"
+
+var (
+	ErrTOTPEnabled    = errors.New("已经开启了二次验证")
+	ErrTOTPNotEnabled = errors.New("没有开启二次验证")
+	// ErrPreAuthInvalid 预登录凭证不存在、过期、已经用过或者失败次数太多，需要重新登录
+	ErrPreAuthInvalid = errors.New("登录已失效，请重新登录")
+	// ErrTwoFactorMethodUnavailable 这次登录不能使用这种验证方式
+	ErrTwoFactorMethodUnavailable = errors.New("不支持这种验证方式")
+)
+
+type TwoFactorConfig struct {
+	// Issuer 验证器 App 中显示的服务名称
+	Issuer string
+	// RequiredRole 这个角色及以上的账号即使没有开启 TOTP，也要用短信做第二步验证
+	RequiredRole domain.Role
+	// MaxAttempts 一个预登录凭证最多允许输错的次数
+	MaxAttempts int64
+	// RecoveryCodeCount 每次生成的恢复码数量
+	RecoveryCodeCount int
+	// SecretCipher 加密数据库中的 TOTP 密钥
+	SecretCipher cipher.AEAD
+}
+
+type TwoFactorService interface {
+	Status(ctx context.Context, uid int64) (domain.TwoFactorStatus, error)
+	// SetupTOTP 生成新的密钥，返回密钥和 otpauth 地址，用户确认之前不会生效
+	SetupTOTP(ctx context.Context, uid int64) (string, string, error)
+	// ConfirmTOTP 用验证器 App 上的验证码确认密钥，返回只展示一次的恢复码
+	ConfirmTOTP(ctx context.Context, uid int64, code string) ([]string, error)
+	// DisableTOTP 关闭二次验证，code 可以是验证码也可以是恢复码
+	DisableTOTP(ctx context.Context, uid int64, code string) error
+	// RegenerateRecoveryCodes 作废之前的恢复码并生成一组新的
+	RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error)
+
+	// Begin 第一步登录通过之后调用，需要二次验证时返回预登录凭证和可用的验证方式
+	// 返回空的凭证说明不需要二次验证，可以直接登录
+	Begin(ctx context.Context, u domain.User, loginMethod string) (string, []string, error)
+	// SendSMSCode 给预登录的用户发送短信验证码
+	SendSMSCode(ctx context.Context, token string) error
+	// Verify 校验第二步，通过之后预登录凭证失效，返回要登录的用户
+	Verify(ctx context.Context, token, method, code string) (domain.PreAuth, error)
+}
+
+type twoFactorService struct {
+	repo        repository.TwoFactorRepository
+	preAuthRepo repository.PreAuthRepository
+	userRepo    repository.UserRepository
+	codeSvc     CodeService
+	// guard 第二步验证失败也计入账号的失败次数，否则拿到密码之后可以无限次猜 TOTP
+	guard  LoginGuard
+	cfg    TwoFactorConfig
+	logger logger.Logger
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepository, preAuthRepo repository.PreAuthRepository,
+	userRepo repository.UserRepository, codeSvc CodeService, guard LoginGuard,
+	cfg TwoFactorConfig, l logger.Logger) TwoFactorService {
+	return &twoFactorService{
+		repo:        repo,
+		preAuthRepo: preAuthRepo,
+		userRepo:    userRepo,
+		codeSvc:     codeSvc,
+		guard:       guard,
+		cfg:         cfg,
+		logger:      l,
+	}
+}
+
+func (s *twoFactorService) Status(ctx context.Context, uid int64) (domain.TwoFactorStatus, error) {
+	t, err := s.repo.FindTOTP(ctx, uid)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return domain.TwoFactorStatus{}, nil
+	}
+	if err != nil {
+		return domain.TwoFactorStatus{}, err
+	}
+	if !t.Enabled {
+		return domain.TwoFactorStatus{}, nil
+	}
+	cnt, err := s.repo.CountRecoveryCodes(ctx, uid)
+	return domain.TwoFactorStatus{
+		TOTPEnabled:       true,
+		RecoveryCodesLeft: cnt,
+	}, err
+}
+
+func (s *twoFactorService) SetupTOTP(ctx context.Context, uid int64) (string, string, error) {
+	u, err := s.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.repo.SaveTOTP(ctx, domain.UserTOTP{UserID: uid, Secret: encrypted})
+	if errors.Is(err, repository.ErrUserStateConflict) {
+		return "", "", ErrTOTPEnabled
+	}
+	if err != nil {
+		return "", "", err
+	}
+	account := u.Account
+	if account == "" {
+		account = u.Phone
+	}
+	if account == "" {
+		account = fmt.Sprintf("user-%d", uid)
+	}
+	return secret, totp.URI(s.cfg.Issuer, account, secret), nil
+}
+
+func (s *twoFactorService) ConfirmTOTP(ctx context.Context, uid int64, code string) ([]string, error) {
+	t, err := s.repo.FindTOTP(ctx, uid)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return nil, ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+	secret, err := s.decrypt(t.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.EnableTOTP(ctx, uid, step, hashes)
+	if errors.Is(err, repository.ErrUserStateConflict) {
+		return nil, ErrTOTPEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) DisableTOTP(ctx context.Context, uid int64, code string) error {
+	ok, err := s.verifyTOTP(ctx, uid, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ok, err = s.repo.UseRecoveryCode(ctx, uid, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return s.repo.DeleteTOTP(ctx, uid)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error) {
+	ok, err := s.verifyTOTP(ctx, uid, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	return codes, s.repo.ReplaceRecoveryCodes(ctx, uid, hashes)
+}
+
+func (s *twoFactorService) Begin(ctx context.Context, u domain.User, loginMethod string) (string, []string, error) {
+	t, err := s.repo.FindTOTP(ctx, u.Id)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		return "", nil, err
+	}
+	totpEnabled := err == nil && t.Enabled
+
+	var methods []string
+	if totpEnabled {
+		methods = append(methods, domain.TwoFactorTOTP, domain.TwoFactorRecovery)
+	}
+	// 短信登录本身就是用的手机，不能再用短信做第二步
+	if u.Phone != "" && loginMethod != domain.LoginMethodSMS {
+		methods = append(methods, domain.TwoFactorSMS)
+	}
+	if !totpEnabled && u.Role < s.cfg.RequiredRole {
+		s.guard.Succeed(ctx, u)
+		return "", nil, nil
+	}
+	if len(methods) == 0 {
+		// 没有开启 TOTP 也没有其他可用的方式，不能把用户锁在外面
+		s.logger.Warn("账号需要二次验证但是没有可用的验证方式",
+			logger.Field{Key: "uid", Value: u.Id},
+			logger.Field{Key: "login_method", Value: loginMethod})
+		s.guard.Succeed(ctx, u)
+		return "", nil, nil
+	}
+	// 第二步验证失败太多次被锁定之后，换一种第一步的登录方式也不能继续
+	if err = s.guard.CheckSecondFactor(ctx, u); err != nil {
+		return "", nil, err
+	}
+
+	token, err := newPreAuthToken()
+	if err != nil {
+		return "", nil, err
+	}
+	err = s.preAuthRepo.Create(ctx, token, domain.PreAuth{
+		UserID:      u.Id,
+		Role:        u.Role,
+		LoginMethod: loginMethod,
+		Methods:     methods,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, methods, nil
+}
+
+func (s *twoFactorService) SendSMSCode(ctx context.Context, token string) error {
+	pa, err := s.findPreAuth(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(pa.Methods, domain.TwoFactorSMS) {
+		return ErrTwoFactorMethodUnavailable
+	}
+	u, err := s.userRepo.FindById(ctx, pa.UserID)
+	if err != nil {
+		return err
+	}
+	if u.Phone == "" {
+		return ErrPhoneNotBind
+	}
+	return s.codeSvc.Send(ctx, BizTwoFactor, u.Phone)
+}
+
+func (s *twoFactorService) Verify(ctx context.Context, token, method, code string) (domain.PreAuth, error) {
+	pa, err := s.findPreAuth(ctx, token)
+	if err != nil {
+		return domain.PreAuth{}, err
+	}
+	if !slices.Contains(pa.Methods, method) {
+		return domain.PreAuth{}, ErrTwoFactorMethodUnavailable
+	}
+	u, err := s.userRepo.FindById(ctx, pa.UserID)
+	if err != nil {
+		return domain.PreAuth{}, err
+	}
+	// 预登录凭证有自己的次数限制，但是可以反复重新登录拿新的凭证，所以还要按账号限制
+	if err = s.guard.CheckSecondFactor(ctx, u); err != nil {
+		return domain.PreAuth{}, err
+	}
+
+	var ok bool
+	switch method {
+	case domain.TwoFactorTOTP:
+		ok, err = s.verifyTOTP(ctx, pa.UserID, code)
+	case domain.TwoFactorRecovery:
+		ok, err = s.repo.UseRecoveryCode(ctx, pa.UserID, hashRecoveryCode(code))
+		if ok {
+			s.logger.Info("使用恢复码登录", logger.Field{Key: "uid", Value: pa.UserID})
+		}
+	case domain.TwoFactorSMS:
+		ok, err = s.codeSvc.Verify(ctx, BizTwoFactor, u.Phone, code)
+	}
+	if err != nil {
+		return domain.PreAuth{}, err
+	}
+	if !ok {
+		s.guard.FailSecondFactor(ctx, u)
+		failures, err := s.preAuthRepo.IncrFailures(ctx, token)
+		if errors.Is(err, repository.ErrPreAuthNotFound) {
+			return domain.PreAuth{}, ErrPreAuthInvalid
+		}
+		if err != nil {
+			return domain.PreAuth{}, err
+		}
+		if failures >= s.cfg.MaxAttempts {
+			// 输错太多次，只能从第一步重新开始
+			_, _ = s.preAuthRepo.Consume(ctx, token)
+			return domain.PreAuth{}, ErrPreAuthInvalid
+		}
+		return domain.PreAuth{}, ErrInvalidCode
+	}
+
+	// 并发的两个请求只有一个能用掉凭证
+	consumed, err := s.preAuthRepo.Consume(ctx, token)
+	if err != nil {
+		return domain.PreAuth{}, err
+	}
+	if !consumed {
+		return domain.PreAuth{}, ErrPreAuthInvalid
+	}
+	s.guard.Succeed(ctx, u)
+	return pa, nil
+}
+
+func (s *twoFactorService) findPreAuth(ctx context.Context, token string) (domain.PreAuth, error) {
+	pa, err := s.preAuthRepo.Find(ctx, token)
+	if errors.Is(err, repository.ErrPreAuthNotFound) {
+		return domain.PreAuth{}, ErrPreAuthInvalid
+	}
+	return pa, err
+}
+
+// verifyTOTP 校验已经启用的 TOTP 验证码，同一个验证码只能使用一次
+func (s *twoFactorService) verifyTOTP(ctx context.Context, uid int64, code string) (bool, error) {
+	t, err := s.repo.FindTOTP(ctx, uid)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return false, ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return false, err
+	}
+	if !t.Enabled {
+		return false, ErrTOTPNotEnabled
+	}
+	secret, err := s.decrypt(t.Secret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok {
+		return false, nil
+	}
+	return s.repo.UseTOTPStep(ctx, uid, step)
+}
+
+// generateRecoveryCodes 返回明文的恢复码以及对应的哈希，数据库只保存哈希
+func (s *twoFactorService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, s.cfg.RecoveryCodeCount)
+	hashes := make([]string, 0, s.cfg.RecoveryCodeCount)
+	for i := 0; i < s.cfg.RecoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode 恢复码是足够长的随机串，用 SHA-256 就够了；忽略大小写和分隔符
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *twoFactorService) encrypt(plain string) (string, error) {
+	nonce := make([]byte, s.cfg.SecretCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.cfg.SecretCipher.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *twoFactorService) decrypt(encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	size := s.cfg.SecretCipher.NonceSize()
+	if len(data) < size {
+		return "", errors.New("TOTP 密钥格式不对")
+	}
+	plain, err := s.cfg.SecretCipher.Open(nil, data[:size], data[size:], nil)
+	return string(plain), err
+}
+
+func newPreAuthToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
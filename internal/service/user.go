@@ -9,29 +9,41 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// BizResetPassword 重置密码时短信验证码的业务
+const BizResetPassword = "reset_password"
+
 var (
 	ErrUserDuplicateEmail    = repository.ErrUserDuplicate
+	ErrUserNotFound          = repository.ErrUserNotFound
 	ErrInvalidUserOrPassword = errors.New("用户名或密码不正确")
+	ErrUserDisabled          = errors.New("账号已被禁用")
 )
 
 type UserService interface {
 	Signup(ctx context.Context, u domain.User) error
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
-	Login(ctx context.Context, account, password string) (domain.User, error)
+	// Login 账号密码登录，client 用于限制失败次数，见 LoginGuard
+	Login(ctx context.Context, account, password string, client domain.LoginClient) (domain.User, error)
 	Profile(ctx context.Context, id int64) (domain.User, error)
 	UpdateNonSensitiveInfo(ctx context.Context, user domain.User) error
+	// ChangePassword 校验旧密码之后修改密码
+	ChangePassword(ctx context.Context, id int64, oldPassword, newPassword string) error
+	// ResetPassword 忘记密码时通过手机号重置密码，调用方需要先校验短信验证码
+	ResetPassword(ctx context.Context, phone, newPassword string) (domain.User, error)
 }
 
 // UserService 表示用户相关的业务逻辑服务
 type userService struct {
 	repo   repository.UserRepository // 引用repository层的UserRepository对象，用于数据访问
+	guard  LoginGuard
 	logger logger.Logger
 }
 
 // NewUserService 实现 UserService 接口
-func NewUserService(repo repository.UserRepository, l logger.Logger) UserService {
+func NewUserService(repo repository.UserRepository, guard LoginGuard, l logger.Logger) UserService {
 	return &userService{
 		repo:   repo,
+		guard:  guard,
 		logger: l,
 	}
 }
@@ -51,6 +63,13 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 	// 大部分人会命中这个分支
 	u, err := svc.repo.FindByPhone(ctx, phone)       // 从数据库中查找用户
 	if !errors.Is(err, repository.ErrUserNotFound) { // 如果用户已经存在，则直接返回
+		if err == nil && !u.Dtime.IsZero() {
+			// 注销中的账号在彻底删除之前，手机号不能再用来登录或注册
+			return domain.User{}, ErrUserDeleted
+		}
+		if err == nil && u.Disabled {
+			return domain.User{}, ErrUserDisabled
+		}
 		return u, err
 	}
 	// 如果找不到用户，则执行用户注册操作
@@ -65,16 +84,33 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 	return svc.repo.FindByPhone(ctx, phone)
 }
 
-func (svc *userService) Login(ctx context.Context, account, password string) (domain.User, error) {
+func (svc *userService) Login(ctx context.Context, account, password string, client domain.LoginClient) (domain.User, error) {
+	if err := svc.guard.Check(ctx, account, client); err != nil {
+		return domain.User{}, err
+	}
 	u, err := svc.repo.FindByAccount(ctx, account)
 	if errors.Is(err, repository.ErrUserNotFound) {
+		// 账号不存在也算失败，否则可以用来枚举账号
+		svc.guard.Fail(ctx, account, client)
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
 
+	if err != nil {
+		return domain.User{}, err
+	}
+
 	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	if err != nil {
+		svc.guard.Fail(ctx, account, client)
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
+	// 失败次数等第二步验证也通过之后再清零，见 TwoFactorService.Begin
+	if !u.Dtime.IsZero() {
+		return domain.User{}, ErrUserDeleted
+	}
+	if u.Disabled {
+		return domain.User{}, ErrUserDisabled
+	}
 	return u, err
 }
 
@@ -83,9 +119,45 @@ func (svc *userService) UpdateNonSensitiveInfo(ctx context.Context, user domain.
 	user.Account = ""
 	user.Phone = ""
 	user.Password = ""
-	return svc.repo.Update(ctx, user)
+	err := svc.repo.Update(ctx, user)
+	if err != nil {
+		return err
+	}
+	// Update 只更新非 0 字段，性别改回未知时需要单独更新
+	return svc.repo.SetGender(ctx, user.Id, user.Gender)
 }
 
 func (svc *userService) Profile(ctx context.Context, id int64) (domain.User, error) {
 	return svc.repo.FindById(ctx, id)
 }
+
+func (svc *userService) ChangePassword(ctx context.Context, id int64, oldPassword, newPassword string) error {
+	u, err := svc.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(oldPassword))
+	if err != nil {
+		return ErrInvalidUserOrPassword
+	}
+	return svc.updatePassword(ctx, id, newPassword)
+}
+
+func (svc *userService) ResetPassword(ctx context.Context, phone, newPassword string) (domain.User, error) {
+	u, err := svc.repo.FindByPhone(ctx, phone)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if !u.Dtime.IsZero() {
+		return domain.User{}, ErrUserDeleted
+	}
+	return u, svc.updatePassword(ctx, u.Id, newPassword)
+}
+
+func (svc *userService) updatePassword(ctx context.Context, id int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return svc.repo.Update(ctx, domain.User{Id: id, Password: string(hash)})
+}
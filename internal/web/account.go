@@ -0,0 +1,323 @@
+package web
+
+import (
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"errors"
+	regexp "github.com/dlclark/regexp2"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var _ handler = &AccountHandler{}
+
+// AccountHandler 处理注销账号、绑定手机号、合并账号
+type AccountHandler struct {
+	svc           service.AccountService
+	phoneRegexExp *regexp.Regexp
+	ijwt.Handler
+	l logger.Logger
+}
+
+func NewAccountHandler(svc service.AccountService, jwthdl ijwt.Handler, l logger.Logger) *AccountHandler {
+	return &AccountHandler{
+		svc:           svc,
+		phoneRegexExp: regexp.MustCompile(phoneRegexPattern, regexp.None),
+		Handler:       jwthdl,
+		l:             l,
+	}
+}
+
+func (h *AccountHandler) RegisterRoutes(server *gin.Engine) {
+	ug := server.Group("/api/v1/user")
+	ug.POST("/delete/code/send", h.SendDeleteCode)
+	ug.POST("/delete", h.Delete)
+
+	ug.POST("/phone/code/send", h.SendBindPhoneCode)
+	ug.POST("/phone/bind", h.BindPhone)
+	ug.POST("/phone/unbind", h.UnbindPhone)
+	ug.POST("/phone/merge", h.MergeByPhone)
+}
+
+func (h *AccountHandler) SendDeleteCode(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.SendDeleteCode(ctx, uc.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrPhoneNotBind):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "请先绑定手机号",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14003,
+			Msg:  "短信发送太频繁，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *AccountHandler) Delete(ctx *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.Delete(ctx, uc.Id, req.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidCode):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+		return
+	case errors.Is(err, service.ErrPhoneNotBind):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "请先绑定手机号",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+
+	// 账号已经软删除，注销已经成功了，清理会话失败不能再告诉用户注销失败
+	// JWT 中间件会拒绝已注销账号的请求，没清理掉的会话也用不了
+	if err = h.ClearAllSessions(ctx, uc.Id); err != nil {
+		h.l.Warn("注销账号之后清理会话失败",
+			logger.Field{Key: "uid", Value: uc.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+	if err = h.ClearToken(ctx); err != nil {
+		h.l.Warn("注销账号之后清理当前会话失败",
+			logger.Field{Key: "uid", Value: uc.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "账号已注销",
+	})
+}
+
+func (h *AccountHandler) SendBindPhoneCode(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !h.checkPhone(ctx, req.Phone) {
+		return
+	}
+	err := h.svc.SendBindPhoneCode(ctx, req.Phone)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14003,
+			Msg:  "短信发送太频繁，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *AccountHandler) BindPhone(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !h.checkPhone(ctx, req.Phone) {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.BindPhone(ctx, uc.Id, req.Phone, req.Code)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "绑定成功",
+		})
+	case errors.Is(err, service.ErrInvalidCode):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+	case errors.Is(err, service.ErrPhoneUsed):
+		// 验证码已经用掉了，前端可以引导用户重新获取验证码之后合并账号
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "手机号已经注册了其他账号，重新获取验证码后可以合并账号",
+		})
+	case errors.Is(err, service.ErrAccountStateConflict):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "账号已经绑定了手机号",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *AccountHandler) UnbindPhone(ctx *gin.Context) {
+	type Req struct {
+		Password string `json:"password"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.UnbindPhone(ctx, uc.Id, req.Password)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "解绑成功",
+		})
+	case errors.Is(err, service.ErrInvalidUserOrPassword):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "密码不正确",
+		})
+	case errors.Is(err, service.ErrAccountStateConflict):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "没有绑定手机号，或者没有设置账号，不能解绑",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *AccountHandler) MergeByPhone(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !h.checkPhone(ctx, req.Phone) {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	dupID, err := h.svc.MergeByPhone(ctx, uc.Id, req.Phone, req.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidCode):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+		return
+	case errors.Is(err, service.ErrUserNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "手机号没有注册",
+		})
+		return
+	case errors.Is(err, service.ErrAccountStateConflict):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "只能把纯手机号账号合并到还没有绑定手机号的账号",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	// 被合并的账号已经注销，它的登录都要失效
+	err = h.ClearAllSessions(ctx, dupID)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "合并成功",
+	})
+}
+
+// checkPhone 校验手机号格式，不合法时直接写回错误响应
+func (h *AccountHandler) checkPhone(ctx *gin.Context, phone string) bool {
+	isPhone, err := h.phoneRegexExp.MatchString(phone)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return false
+	}
+	if !isPhone {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "手机号格式不正确",
+		})
+		return false
+	}
+	return true
+}
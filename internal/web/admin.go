@@ -0,0 +1,349 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/internal/web/middleware"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// adminMaxSummaryDays 管理后台一次最多查看的天数
+const adminMaxSummaryDays = 366
+
+var _ handler = &AdminHandler{}
+
+// AdminHandler 管理后台接口，每个接口按权限授权
+type AdminHandler struct {
+	svc service.AdminService
+	ijwt.Handler
+}
+
+func NewAdminHandler(svc service.AdminService, jwthdl ijwt.Handler) *AdminHandler {
+	return &AdminHandler{
+		svc:     svc,
+		Handler: jwthdl,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/api/v1/admin")
+	g.POST("/users/search", middleware.RequirePermissions(domain.PermUserRead), h.SearchUsers)
+	g.POST("/users/summaries", middleware.RequirePermissions(domain.PermSummaryRead), h.UserSummaries)
+	g.POST("/users/disable", middleware.RequirePermissions(domain.PermUserDisable), h.Disable)
+	g.POST("/users/enable", middleware.RequirePermissions(domain.PermUserDisable), h.Enable)
+	g.POST("/users/role", middleware.RequirePermissions(domain.PermRoleManage), h.SetRole)
+	g.POST("/sms/dead-letters", middleware.RequirePermissions(domain.PermSMSQueue), h.SMSDeadLetters)
+	g.POST("/sms/requeue", middleware.RequirePermissions(domain.PermSMSQueue), h.RequeueSMS)
+}
+
+type AdminUserVO struct {
+	Id       int64
+	Account  string
+	Phone    string
+	Nickname string
+	Role     string
+	Disabled bool
+	Ctime    string
+}
+
+func (h *AdminHandler) SearchUsers(ctx *gin.Context) {
+	type Req struct {
+		Keyword string `json:"keyword"`
+		Offset  int    `json:"offset"`
+		Limit   int    `json:"limit"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	users, err := h.svc.SearchUsers(ctx, req.Keyword, req.Offset, req.Limit)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	vos := make([]AdminUserVO, 0, len(users))
+	for _, u := range users {
+		vos = append(vos, AdminUserVO{
+			Id:       u.Id,
+			Account:  u.Account,
+			Phone:    u.Phone,
+			Nickname: u.Nickname,
+			Role:     u.Role.String(),
+			Disabled: u.Disabled,
+			Ctime:    u.Ctime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: vos,
+	})
+}
+
+func (h *AdminHandler) UserSummaries(ctx *gin.Context) {
+	type Req struct {
+		UserID       int64  `json:"user_id"`
+		StartDateStr string `json:"start_date"`
+		EndDateStr   string `json:"end_date"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	startDate, err := time.Parse(time.DateOnly, req.StartDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+	endDate, err := time.Parse(time.DateOnly, req.EndDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+	if endDate.Before(startDate) || endDate.Sub(startDate) > adminMaxSummaryDays*24*time.Hour {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期范围不对，最多查询一年",
+		})
+		return
+	}
+	summaries, err := h.svc.UserSummaries(ctx, req.UserID, startDate, endDate)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: summaries,
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "用户不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *AdminHandler) Disable(ctx *gin.Context) {
+	h.setDisabled(ctx, true)
+}
+
+func (h *AdminHandler) Enable(ctx *gin.Context) {
+	h.setDisabled(ctx, false)
+}
+
+func (h *AdminHandler) setDisabled(ctx *gin.Context, disabled bool) {
+	type Req struct {
+		UserID int64 `json:"user_id"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.SetDisabled(ctx, uc.Id, req.UserID, disabled)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrUserNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "用户不存在",
+		})
+		return
+	case errors.Is(err, service.ErrCannotDisableAdmin):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "不能禁用系统管理员",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if disabled {
+		// 被禁用的账号立刻下线
+		if err = h.ClearAllSessions(ctx, req.UserID); err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 25001,
+				Msg:  "服务异常",
+			})
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+	})
+}
+
+func (h *AdminHandler) SetRole(ctx *gin.Context) {
+	type Req struct {
+		UserID int64 `json:"user_id"`
+		Role   int   `json:"role"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.SetRole(ctx, uc.Id, req.UserID, domain.Role(req.Role))
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "用户不存在",
+		})
+	case errors.Is(err, service.ErrInvalidRole):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "角色不存在",
+		})
+	case errors.Is(err, service.ErrCannotChangeOwnRole):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "不能修改自己的角色",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+// SMSTaskVO 不返回模板参数，里面可能是验证码
+type SMSTaskVO struct {
+	Id       int64
+	TplId    string
+	Numbers  []string
+	Attempts int
+	LastErr  string
+	Ctime    string
+	Utime    string
+}
+
+func (h *AdminHandler) SMSDeadLetters(ctx *gin.Context) {
+	type Req struct {
+		Offset int `json:"offset"`
+		Limit  int `json:"limit"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	tasks, err := h.svc.SMSDeadLetters(ctx, req.Offset, req.Limit)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	vos := make([]SMSTaskVO, 0, len(tasks))
+	for _, t := range tasks {
+		vos = append(vos, SMSTaskVO{
+			Id:       t.Id,
+			TplId:    t.TplId,
+			Numbers:  t.Numbers,
+			Attempts: t.Attempts,
+			LastErr:  t.LastErr,
+			Ctime:    t.Ctime.Format(time.DateTime),
+			Utime:    t.Utime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: vos,
+	})
+}
+
+func (h *AdminHandler) RequeueSMS(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.RequeueSMSTask(ctx, uc.Id, req.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrSMSTaskNotDead):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "死信不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
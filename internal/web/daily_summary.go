@@ -1,6 +1,7 @@
 package web
 
 import (
+	"badminton-backend/internal/domain"
 	"badminton-backend/internal/service"
 	ijwt "badminton-backend/internal/web/jwt"
 	"github.com/gin-gonic/gin"
@@ -9,16 +10,25 @@ import (
 )
 
 const (
-	bizDailySummary = "DailySummary"
+	bizDailySummary = service.BizDailySummary
 )
 
+// DailySummaryVO 汇总数据以及基于身体数据推算出的生理指标
+// 汇总字段通过匿名嵌入平铺在 JSON 中，保持原有的响应格式不变
+type DailySummaryVO struct {
+	domain.DailySummary
+	Metrics domain.TrainingMetrics
+}
+
 type DailySummaryHandler struct {
-	svc service.DailySummaryService
+	svc        service.DailySummaryService
+	metricsSvc service.TrainingMetricsService
 }
 
-func NewDailySummaryHandler(svc service.DailySummaryService) *DailySummaryHandler {
+func NewDailySummaryHandler(svc service.DailySummaryService, metricsSvc service.TrainingMetricsService) *DailySummaryHandler {
 	return &DailySummaryHandler{
-		svc: svc,
+		svc:        svc,
+		metricsSvc: metricsSvc,
 	}
 }
 
@@ -60,11 +70,22 @@ func (h *DailySummaryHandler) GetByDate(ctx *gin.Context) {
 		})
 		return
 	}
+	metrics, err := h.metricsSvc.GetByDate(ctx, uc.Id, date)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
 
 	ctx.JSON(http.StatusOK, Result{
 		Code: 10000,
 		Msg:  "OK",
-		Data: summary,
+		Data: DailySummaryVO{
+			DailySummary: summary,
+			Metrics:      metrics,
+		},
 	})
 }
 
@@ -107,10 +128,21 @@ func (h *DailySummaryHandler) GetByDateRange(ctx *gin.Context) {
 		})
 		return
 	}
+	metrics, err := h.metricsSvc.GetByDateRange(ctx, uc.Id, startDate, endDate)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
 
 	ctx.JSON(http.StatusOK, Result{
 		Code: 10000,
 		Msg:  "OK",
-		Data: summaries,
+		Data: DailySummaryVO{
+			DailySummary: summaries,
+			Metrics:      metrics,
+		},
 	})
 }
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+var _ handler = &DataArchiveHandler{}
+
+// DataArchiveHandler 个人数据下载相关的接口
+type DataArchiveHandler struct {
+	svc service.DataArchiveService
+}
+
+func NewDataArchiveHandler(svc service.DataArchiveService) *DataArchiveHandler {
+	return &DataArchiveHandler{
+		svc: svc,
+	}
+}
+
+func (h *DataArchiveHandler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	g := v1.Group("/user/data-archive")
+
+	g.POST("", h.Create)
+	g.POST("/job", h.GetJob)
+	// 凭下载凭证下载，不需要登录，见 JWT 中间件
+	g.GET("/download", h.Download)
+}
+
+func (h *DataArchiveHandler) Create(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	a, err := h.svc.CreateJob(ctx, uc.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: dataArchiveVO(a),
+	})
+}
+
+func (h *DataArchiveHandler) GetJob(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	a, err := h.svc.GetJob(ctx, uc.Id, req.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: dataArchiveVO(a),
+		})
+	case errors.Is(err, service.ErrArchiveNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "任务不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *DataArchiveHandler) Download(ctx *gin.Context) {
+	a, err := h.svc.FindByToken(ctx, ctx.Query("token"))
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrArchiveNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "下载链接不存在",
+		})
+		return
+	case errors.Is(err, service.ErrArchiveExpired):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "下载链接已过期",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.Header("Content-Type", "application/zip")
+	ctx.FileAttachment(a.FilePath, fmt.Sprintf("badminton-data-%s.zip", a.Ctime.Format("20060102")))
+}
+
+// DataArchiveVO 返回给前端的打包任务，不暴露文件在服务器上的路径
+type DataArchiveVO struct {
+	Id       int64
+	Status   string
+	Token    string
+	ExpireAt string
+	ErrMsg   string
+}
+
+func dataArchiveVO(a domain.DataArchive) DataArchiveVO {
+	vo := DataArchiveVO{
+		Id:     a.Id,
+		Status: a.Status,
+		Token:  a.Token,
+		ErrMsg: a.ErrMsg,
+	}
+	if !a.ExpireAt.IsZero() {
+		vo.ExpireAt = a.ExpireAt.Format(time.DateTime)
+	}
+	return vo
+}
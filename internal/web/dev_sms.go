@@ -0,0 +1,60 @@
+package web
+
+import (
+	"badminton-backend/internal/service/sms/memory"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+var _ handler = &DevSMSHandler{}
+
+// DevSMSHandler 开发和自动化测试时查看最后发出的短信，只在开发环境注册
+// 只有 sms.provider 配置为 memory 时才有数据
+type DevSMSHandler struct {
+	store *memory.Service
+}
+
+func NewDevSMSHandler(store *memory.Service) *DevSMSHandler {
+	return &DevSMSHandler{
+		store: store,
+	}
+}
+
+const devSMSLastPath = "/internal/dev/sms/last"
+
+func (h *DevSMSHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET(devSMSLastPath, h.Last)
+}
+
+// PublicPaths 不需要登录就能访问的路由，只有注册了这个 handler 才放行
+func (h *DevSMSHandler) PublicPaths() []string {
+	return []string{devSMSLastPath}
+}
+
+type DevSMSVO struct {
+	TplId string
+	// Args 验证码短信的第一个参数就是验证码
+	Args  []string
+	Ctime string
+}
+
+func (h *DevSMSHandler) Last(ctx *gin.Context) {
+	msg, ok := h.store.Last(ctx.Query("phone"))
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "这个手机号没有收到过短信",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: DevSMSVO{
+			TplId: msg.TplId,
+			Args:  msg.Args,
+			Ctime: msg.Ctime.Format(time.DateTime),
+		},
+	})
+}
@@ -0,0 +1,228 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var _ handler = &ExportHandler{}
+
+// ExportHandler 数据导出相关的接口
+type ExportHandler struct {
+	svc service.ExportService
+	l   logger.Logger
+}
+
+func NewExportHandler(svc service.ExportService, l logger.Logger) *ExportHandler {
+	return &ExportHandler{
+		svc: svc,
+		l:   l,
+	}
+}
+
+func (h *ExportHandler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	g := v1.Group("/export")
+
+	g.POST("/daily-summary", h.ExportDailySummary)
+	g.POST("/job", h.GetJob)
+	g.GET("/job/:id/download", h.Download)
+}
+
+// ExportDailySummary 数据量小时直接流式返回文件，数据量大或者指定了 async 时创建异步任务
+func (h *ExportHandler) ExportDailySummary(ctx *gin.Context) {
+	type Req struct {
+		Format       string `json:"format"`
+		StartDateStr string `json:"start_date"`
+		EndDateStr   string `json:"end_date"`
+		Async        bool   `json:"async"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if req.Format == "" {
+		req.Format = domain.ExportFormatCSV
+	}
+	contentType, ok := exportContentTypes[req.Format]
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "导出格式不正确",
+		})
+		return
+	}
+	startDate, err := time.Parse(time.DateOnly, req.StartDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+	endDate, err := time.Parse(time.DateOnly, req.EndDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	async := req.Async
+	if !async {
+		async, err = h.svc.ShouldAsync(ctx, uc.Id, startDate, endDate)
+		if err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 25001,
+				Msg:  "服务异常",
+			})
+			return
+		}
+	}
+
+	if async {
+		j, err := h.svc.CreateJob(ctx, uc.Id, req.Format, startDate, endDate)
+		if err != nil {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 25001,
+				Msg:  "服务异常",
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "数据量较大，已创建导出任务",
+			Data: exportJobVO(j),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("daily_summary_%s_%s.%s", req.StartDateStr, req.EndDateStr, req.Format)
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+	_, err = h.svc.Export(ctx, ctx.Writer, uc.Id, req.Format, startDate, endDate)
+	if err != nil {
+		// 响应头已经写出去了，只能记录日志并中断
+		h.l.Error("导出数据失败",
+			logger.Field{Key: "uid", Value: uc.Id},
+			logger.Field{Key: "err", Value: err.Error()})
+		ctx.Abort()
+	}
+}
+
+func (h *ExportHandler) GetJob(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	j, err := h.svc.GetJob(ctx, uc.Id, req.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: exportJobVO(j),
+		})
+	case errors.Is(err, service.ErrJobNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "任务不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *ExportHandler) Download(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "任务 ID 不正确",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	j, err := h.svc.GetJob(ctx, uc.Id, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrJobNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "任务不存在",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if j.Status != domain.JobStatusDone {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14005,
+			Msg:  "导出任务还没有完成",
+		})
+		return
+	}
+	ctx.Header("Content-Type", exportContentTypes[j.Format])
+	ctx.FileAttachment(j.FilePath, filepath.Base(j.FilePath))
+}
+
+var exportContentTypes = map[string]string{
+	domain.ExportFormatCSV:    "text/csv; charset=utf-8",
+	domain.ExportFormatNDJSON: "application/x-ndjson",
+}
+
+// ExportJobVO 返回给前端的导出任务，不暴露文件在服务器上的路径
+type ExportJobVO struct {
+	Id        int64
+	Format    string
+	StartDate string
+	EndDate   string
+	Status    string
+	RowCount  int64
+	ErrMsg    string
+}
+
+func exportJobVO(j domain.ExportJob) ExportJobVO {
+	return ExportJobVO{
+		Id:        j.Id,
+		Format:    j.Format,
+		StartDate: j.StartDate.Format(time.DateOnly),
+		EndDate:   j.EndDate.Format(time.DateOnly),
+		Status:    j.Status,
+		RowCount:  j.RowCount,
+		ErrMsg:    j.ErrMsg,
+	}
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var _ handler = &ImportHandler{}
+
+// maxImportFileSize 上传文件的大小上限
+const maxImportFileSize = 64 << 20
+
+// ImportHandler 导入历史训练数据相关的接口
+type ImportHandler struct {
+	svc service.ImportService
+}
+
+func NewImportHandler(svc service.ImportService) *ImportHandler {
+	return &ImportHandler{
+		svc: svc,
+	}
+}
+
+func (h *ImportHandler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	g := v1.Group("/import")
+
+	g.POST("/daily-summary", h.ImportDailySummary)
+	g.POST("/job", h.GetJob)
+}
+
+// ImportDailySummary 上传文件使用 multipart/form-data，字段 file 为文件，source 为数据来源
+func (h *ImportHandler) ImportDailySummary(ctx *gin.Context) {
+	source := ctx.PostForm("source")
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "请上传文件",
+		})
+		return
+	}
+	if fh.Size > maxImportFileSize {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "文件太大",
+		})
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	defer file.Close()
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	j, duplicated, err := h.svc.CreateJob(ctx, uc.Id, source, file)
+	switch {
+	case err == nil && duplicated:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "该文件已经导入过",
+			Data: importJobVO(j),
+		})
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "已创建导入任务",
+			Data: importJobVO(j),
+		})
+	case errors.Is(err, service.ErrUnknownImportSource), errors.Is(err, service.ErrUnknownImportFormat):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "数据来源不正确",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *ImportHandler) GetJob(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	j, err := h.svc.GetJob(ctx, uc.Id, req.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: importJobVO(j),
+		})
+	case errors.Is(err, service.ErrJobNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "任务不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+// ImportJobVO 返回给前端的导入任务，包括进度和每一行的错误
+type ImportJobVO struct {
+	Id          int64
+	Source      string
+	Status      string
+	TotalRows   int
+	SuccessRows int
+	FailedRows  int
+	Errors      []domain.ImportRowError
+	ErrMsg      string
+}
+
+func importJobVO(j domain.ImportJob) ImportJobVO {
+	return ImportJobVO{
+		Id:          j.Id,
+		Source:      j.Source,
+		Status:      j.Status,
+		TotalRows:   j.TotalRows,
+		SuccessRows: j.SuccessRows,
+		FailedRows:  j.FailedRows,
+		Errors:      j.Errors,
+		ErrMsg:      j.ErrMsg,
+	}
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	ijwt "badminton-backend/internal/web/jwt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var _ handler = &JWKSHandler{}
+
+// JWKSHandler 公开 access token 的公钥，其他服务可以自己校验 token
+type JWKSHandler struct {
+	keys *ijwt.Keys
+}
+
+func NewJWKSHandler(keys *ijwt.Keys) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+func (h *JWKSHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET("/.well-known/jwks.json", h.JWKS)
+}
+
+// JWKS 按照 RFC 7517 的格式返回，而不是包装在 Result 中，方便标准的 JWT 库直接使用
+func (h *JWKSHandler) JWKS(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"keys": h.keys.Access.JWKS(),
+	})
+}
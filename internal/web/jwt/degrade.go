@@ -0,0 +1,144 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// DegradeConfig Redis 不可用时会话校验的降级配置
+type DegradeConfig struct {
+	// Enabled 是否允许降级。不允许时 Redis 出错的请求一律按未登录处理
+	Enabled bool
+	// MaxDuration Redis 连续不可用超过这个时间之后不再降级，避免长时间只依赖 JWT
+	MaxDuration time.Duration
+}
+
+type revokedSsid struct {
+	uid    int64
+	expire time.Time
+	// pending 撤销还没有写到 Redis 中，Redis 恢复后需要补上
+	pending bool
+}
+
+type revokedUser struct {
+	// before 这个时间之前签发的 token 都失效
+	before time.Time
+	// keep 不失效的会话，空字符串代表所有会话都失效
+	keep    string
+	expire  time.Time
+	pending bool
+}
+
+// revokedCache 进程内记录最近撤销的会话
+// 降级期间无法从 Redis 确认会话是否有效，只能依靠它拦截已经退出登录的 token。
+// 记录保留到 access token 的最长有效期，再早撤销的会话对应的 token 已经全部过期了。
+// 它只在本进程内有效，所以降级的时长必须有上限
+type revokedCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	ssids map[string]revokedSsid
+	users map[int64]revokedUser
+	// dirty 可能存在还没有写到 Redis 中的撤销记录
+	dirty bool
+}
+
+func newRevokedCache(ttl time.Duration) *revokedCache {
+	return &revokedCache{
+		ttl:   ttl,
+		ssids: make(map[string]revokedSsid),
+		users: make(map[int64]revokedUser),
+	}
+}
+
+func (c *revokedCache) addSsid(uid int64, ssid string, pending bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.prune(now)
+	c.ssids[ssid] = revokedSsid{uid: uid, expire: now.Add(c.ttl), pending: pending}
+	c.dirty = c.dirty || pending
+}
+
+func (c *revokedCache) addUser(uid int64, keep string, pending bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.prune(now)
+	c.users[uid] = revokedUser{before: now, keep: keep, expire: now.Add(c.ttl), pending: pending}
+	c.dirty = c.dirty || pending
+}
+
+// revoked token 对应的会话是否已经被撤销
+func (c *revokedCache) revoked(uc UserClaims) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.ssids[uc.Ssid]; ok {
+		return true
+	}
+	u, ok := c.users[uc.Id]
+	if !ok || uc.Ssid == u.keep {
+		return false
+	}
+	// 没有签发时间的是旧 token，一律按撤销处理
+	return uc.IssuedAt == nil || uc.IssuedAt.Time.Before(u.before)
+}
+
+// pending 返回还没有写到 Redis 中的撤销记录
+func (c *revokedCache) pending() (map[string]revokedSsid, map[int64]revokedUser) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ssids := make(map[string]revokedSsid)
+	for ssid, r := range c.ssids {
+		if r.pending {
+			ssids[ssid] = r
+		}
+	}
+	users := make(map[int64]revokedUser)
+	for uid, r := range c.users {
+		if r.pending {
+			users[uid] = r
+		}
+	}
+	c.dirty = len(ssids) > 0 || len(users) > 0
+	return ssids, users
+}
+
+// hasPending 每次请求都会调用，所以只看标记，不遍历记录
+func (c *revokedCache) hasPending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirty
+}
+
+// markSsidSynced 标记撤销记录已经写到了 Redis，记录本身继续保留，防止 Redis 再次不可用
+func (c *revokedCache) markSsidSynced(ssid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.ssids[ssid]; ok {
+		r.pending = false
+		c.ssids[ssid] = r
+	}
+}
+
+func (c *revokedCache) markUserSynced(uid int64, before time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 同步期间又撤销了一次的话，留给下一次同步
+	if r, ok := c.users[uid]; ok && r.before.Equal(before) {
+		r.pending = false
+		c.users[uid] = r
+	}
+}
+
+func (c *revokedCache) prune(now time.Time) {
+	for ssid, r := range c.ssids {
+		if now.After(r.expire) {
+			delete(c.ssids, ssid)
+		}
+	}
+	for uid, r := range c.users {
+		if now.After(r.expire) {
+			delete(c.users, uid)
+		}
+	}
+}
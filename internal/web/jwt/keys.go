@@ -0,0 +1,232 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"sort"
+)
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var ErrUnknownKey = errors.New("未知的签名密钥")
+
+// KeyConfig 一个签名密钥的配置
+// 对称算法只需要 Secret；非对称算法签名需要私钥，只用来校验的旧密钥可以只配置公钥
+type KeyConfig struct {
+	ID             string // 写在 token 头部的 kid
+	Alg            string
+	Secret         string
+	PrivateKeyFile string // PEM 格式
+	PublicKeyFile  string // PEM 格式，没有配置时从私钥推导
+}
+
+// KeySetConfig 一类 token 的密钥配置
+// 轮换密钥时先把新密钥加到 Keys 中，等所有服务都认识它之后再修改 SigningKey，
+// 旧 token 全部过期之后再移除旧密钥
+type KeySetConfig struct {
+	SigningKey string // 签发新 token 使用的密钥 ID
+	Keys       []KeyConfig
+}
+
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   any // 只用来校验的密钥为 nil
+	verifyKey any
+}
+
+// Keys access token 和 refresh token 使用不同的密钥，避免 refresh token 被当成 access token 使用
+type Keys struct {
+	Access  *KeySet
+	Refresh *KeySet
+}
+
+// KeySet 一类 token（access 或者 refresh）可以同时存在多个有效的密钥
+// 签名时使用指定的密钥并在头部写入 kid，校验时根据 kid 选择密钥
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+	methods []string
+}
+
+func NewKeySet(cfg KeySetConfig) (*KeySet, error) {
+	ks := &KeySet{
+		keys: make(map[string]*key, len(cfg.Keys)),
+	}
+	methods := make(map[string]struct{})
+	for _, kc := range cfg.Keys {
+		if _, ok := ks.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("密钥 %s 重复", kc.ID)
+		}
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("加载密钥 %s 失败 %w", kc.ID, err)
+		}
+		ks.keys[kc.ID] = k
+		if _, ok := methods[kc.Alg]; !ok {
+			methods[kc.Alg] = struct{}{}
+			ks.methods = append(ks.methods, kc.Alg)
+		}
+	}
+	signing, ok := ks.keys[cfg.SigningKey]
+	if !ok {
+		return nil, fmt.Errorf("签名密钥 %s 不存在", cfg.SigningKey)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("签名密钥 %s 没有配置私钥", cfg.SigningKey)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// Sign 使用当前的签名密钥签发 token
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.id
+	return token.SignedString(ks.signing.signKey)
+}
+
+// Parse 校验 token 的签名并解析 claims
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc, jwt.WithValidMethods(ks.methods))
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	k := ks.signing
+	// 没有 kid 的是引入密钥配置之前签发的 token，使用当前的签名密钥校验
+	if kid, ok := token.Header["kid"].(string); ok {
+		k, ok = ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+	// 防止用 A 算法的 token 去匹配 B 算法的密钥
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, ErrUnknownKey
+	}
+	return k.verifyKey, nil
+}
+
+// JWK JSON Web Key，只包含公钥部分
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS 所有非对称密钥的公钥，其他服务可以用它们校验 token
+// 对称密钥不能公开，不会出现在这里
+func (ks *KeySet) JWKS() []JWK {
+	res := make([]JWK, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			res = append(res, JWK{
+				Kty: "RSA",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			res = append(res, JWK{
+				Kty: "OKP",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Kid < res[j].Kid
+	})
+	return res
+}
+
+func loadKey(kc KeyConfig) (*key, error) {
+	if kc.ID == "" {
+		return nil, errors.New("密钥 ID 不能为空")
+	}
+	k := &key{id: kc.ID}
+	switch kc.Alg {
+	case AlgHS256:
+		if kc.Secret == "" {
+			return nil, errors.New("HS256 需要配置 secret")
+		}
+		k.method = jwt.SigningMethodHS256
+		k.signKey = []byte(kc.Secret)
+		k.verifyKey = k.signKey
+		return k, nil
+	case AlgRS256:
+		k.method = jwt.SigningMethodRS256
+	case AlgEdDSA:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("不支持的签名算法 %s", kc.Alg)
+	}
+
+	if kc.PrivateKeyFile != "" {
+		data, err := os.ReadFile(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var priv crypto.Signer
+		if kc.Alg == AlgRS256 {
+			priv, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+		} else {
+			var edPriv crypto.PrivateKey
+			edPriv, err = jwt.ParseEdPrivateKeyFromPEM(data)
+			if err == nil {
+				var ok bool
+				if priv, ok = edPriv.(ed25519.PrivateKey); !ok {
+					err = jwt.ErrNotEdPrivateKey
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		k.signKey = priv
+		k.verifyKey = priv.Public()
+	}
+	if kc.PublicKeyFile != "" {
+		data, err := os.ReadFile(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if kc.Alg == AlgRS256 {
+			k.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		} else {
+			k.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if k.verifyKey == nil {
+		return nil, fmt.Errorf("%s 需要配置私钥或者公钥", kc.Alg)
+	}
+	return k, nil
+}
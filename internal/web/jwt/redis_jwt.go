@@ -1,44 +1,62 @@
 package jwt
 
 import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/cache"
+	"badminton-backend/pkg/logger"
+	"context"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var AccessTokenKey = []byte("moyn8y9abnd7q4zkq2m73yw8tu9j5ixm")
-var RefreshTokenKey = []byte("moyn8y9abnd7q4zkq2m73yw8tu9j5ixA")
+var errSessionRevoked = errors.New("用户已经退出登录")
 
 type RedisHandler struct {
-	cmd          redis.Cmdable
+	sessions     cache.SessionCache
+	keys         *Keys
 	acExpiration time.Duration
 	rtExpiration time.Duration
+
+	degrade DegradeConfig
+	revoked *revokedCache
+	logger  logger.Logger
+	mu      sync.Mutex
+	// degradedSince Redis 开始不可用的时间，零值代表 Redis 正常
+	degradedSince time.Time
+	syncing       atomic.Bool
 }
 
-func NewRedisHandler(cmd redis.Cmdable) Handler {
+func NewRedisHandler(sessions cache.SessionCache, keys *Keys, degrade DegradeConfig, l logger.Logger) Handler {
+	acExpiration := time.Hour * 24
 	return &RedisHandler{
-		cmd:          cmd,
-		acExpiration: time.Hour * 24,
+		sessions:     sessions,
+		keys:         keys,
+		acExpiration: acExpiration,
 		rtExpiration: time.Hour * 24 * 7,
+		degrade:      degrade,
+		revoked:      newRevokedCache(acExpiration),
+		logger:       l,
 	}
 }
 
-func (h *RedisHandler) SetJWTToken(ctx *gin.Context, ssid string, uid int64) error {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, UserClaims{
+func (h *RedisHandler) SetJWTToken(ctx *gin.Context, ssid string, uid int64, role domain.Role) error {
+	tokenStr, err := h.keys.Access.Sign(UserClaims{
 		Id:        uid, // 用户 ID
 		Ssid:      ssid,
+		Role:      role,
 		UserAgent: ctx.GetHeader("User-Agent"), // 从请求头中获取 User-Agent
 		RegisteredClaims: jwt.RegisteredClaims{
+			// 降级时根据签发时间判断 token 是不是在退出所有登录之前签发的
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.acExpiration)), // 设置过期时间
 		},
 	})
-
-	tokenStr, err := token.SignedString(AccessTokenKey)
 	if err != nil {
 		// 如果签名过程中出错，返回系统异常信息
 		return err
@@ -56,33 +74,111 @@ func (h *RedisHandler) ClearToken(ctx *gin.Context) error {
 	ctx.Header("x-refresh-token", "")
 	// 这里不可能拿不到
 	uc := ctx.MustGet("user").(UserClaims)
-	return h.cmd.Set(ctx, h.key(uc.Ssid), "", h.rtExpiration).Err()
+	if uc.IssuedAt == nil {
+		// 没有签发时间的旧 token 只认退出登录的标记，见 CheckSession
+		if err := h.sessions.RevokeLegacy(ctx, uc.Ssid); err != nil {
+			return err
+		}
+	}
+	err := h.ClearSession(ctx, uc.Id, uc.Ssid)
+	if errors.Is(err, cache.ErrSessionNotFound) {
+		// 会话已经失效了
+		return nil
+	}
+	return err
 }
 
-func (h *RedisHandler) key(ssid string) string {
-	return fmt.Sprintf("users:Ssid:%s", ssid)
+func (h *RedisHandler) ListSessions(ctx context.Context, uid int64) ([]domain.Session, error) {
+	return h.sessions.List(ctx, uid)
 }
 
-// SetLoginToken 设置登录后的 token
-func (h *RedisHandler) SetLoginToken(ctx *gin.Context, uid int64) error {
+func (h *RedisHandler) ClearSession(ctx context.Context, uid int64, ssid string) error {
+	err := h.sessions.Revoke(ctx, uid, ssid)
+	if errors.Is(err, cache.ErrSessionNotFound) {
+		return err
+	}
+	// 无论 Redis 是否写成功都在本地记一笔，Redis 之后不可用时也能拦住这个会话
+	h.revoked.addSsid(uid, ssid, err != nil)
+	if err != nil && h.canDegrade(err) {
+		return nil
+	}
+	return err
+}
+
+func (h *RedisHandler) ClearAllSessions(ctx context.Context, uid int64) error {
+	return h.ClearOtherSessions(ctx, uid, "")
+}
+
+func (h *RedisHandler) ClearOtherSessions(ctx context.Context, uid int64, ssid string) error {
+	err := h.sessions.RevokeOthers(ctx, uid, ssid)
+	h.revoked.addUser(uid, ssid, err != nil)
+	if err != nil && h.canDegrade(err) {
+		return nil
+	}
+	return err
+}
+
+// SetLoginToken 设置登录后的 token，返回新会话的 ssid
+func (h *RedisHandler) SetLoginToken(ctx *gin.Context, uid int64, role domain.Role) (string, error) {
 	ssid := uuid.New().String()
-	err := h.SetJWTToken(ctx, ssid, uid)
+	refreshJTI := uuid.New().String()
+	now := time.Now()
+	userAgent := ctx.GetHeader("User-Agent")
+	// 登记会话，之后的每次请求都会在 CheckSession 中检查它
+	err := h.sessions.Create(ctx, domain.Session{
+		Ssid:      ssid,
+		UserID:    uid,
+		Device:    deviceName(ctx.GetHeader("X-Device-Name"), userAgent),
+		IP:        ctx.ClientIP(),
+		UserAgent: userAgent,
+		LoginTime: now,
+	}, refreshJTI)
+	if err != nil {
+		return "", err
+	}
+	err = h.SetJWTToken(ctx, ssid, uid, role)
+	if err != nil {
+		return "", err
+	}
+	err = h.setRefreshToken(ctx, ssid, uid, refreshJTI)
+	return ssid, err
+}
+
+// RotateRefreshToken 用 refresh token 换一对新的 access token 和 refresh token
+// 旧的 refresh token 只在很短的宽限期内还能使用，之后再次使用会被认为是 token 被盗，整个会话都会被撤销
+func (h *RedisHandler) RotateRefreshToken(ctx *gin.Context, loadRole RoleLoader) error {
+	tokenStr := h.ExtractTokenString(ctx)
+	var rc RefreshClaims
+	token, err := h.keys.Refresh.Parse(tokenStr, &rc)
+	if err != nil || token == nil || !token.Valid || rc.Ssid == "" || rc.ID == "" {
+		return ErrInvalidToken
+	}
+	role, err := loadRole(ctx, rc.Id)
 	if err != nil {
 		return err
 	}
-	err = h.setRefreshToken(ctx, ssid, uid)
-	return err
+
+	// 宽限期内的并发刷新拿到的是当前的 ID，和另一个请求签发的 refresh token 一样有效
+	jti, err := h.sessions.RotateRefresh(ctx, rc.Id, rc.Ssid, rc.ID, uuid.New().String())
+	if err != nil {
+		return err
+	}
+	err = h.SetJWTToken(ctx, rc.Ssid, rc.Id, role)
+	if err != nil {
+		return err
+	}
+	return h.setRefreshToken(ctx, rc.Ssid, rc.Id, jti)
 }
 
-func (h *RedisHandler) setRefreshToken(ctx *gin.Context, ssid string, uid int64) error {
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, RefreshClaims{
-		Id: uid,
+func (h *RedisHandler) setRefreshToken(ctx *gin.Context, ssid string, uid int64, jti string) error {
+	refreshTokenStr, err := h.keys.Refresh.Sign(RefreshClaims{
+		Id:   uid,
+		Ssid: ssid,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.rtExpiration)),
 		},
 	})
-
-	refreshTokenStr, err := refreshToken.SignedString(RefreshTokenKey)
 	if err != nil {
 		return err
 	}
@@ -90,19 +186,114 @@ func (h *RedisHandler) setRefreshToken(ctx *gin.Context, ssid string, uid int64)
 	return nil
 }
 
-func (h *RedisHandler) CheckSession(ctx *gin.Context, ssid string) error {
-	logout, err := h.cmd.Exists(ctx, fmt.Sprintf("users:Ssid:%s", ssid)).Result()
+func (h *RedisHandler) ParseAccessToken(tokenStr string) (UserClaims, error) {
+	var uc UserClaims
+	token, err := h.keys.Access.Parse(tokenStr, &uc)
+	if err != nil || token == nil || !token.Valid {
+		return UserClaims{}, ErrInvalidToken
+	}
+	return uc, nil
+}
+
+func (h *RedisHandler) CheckSession(ctx *gin.Context, uc UserClaims) error {
+	ok, err := h.sessions.Touch(ctx, uc.Ssid)
 	if err != nil {
-		// 系统错误或者用户已经主动退出登录了
-		// TODO 可以考虑降级措施，如果在 Redis 已经崩溃的时候就不要去校验是不是已经主动退出登录了
-		return err
+		if !h.canDegrade(err) {
+			return err
+		}
+		// 降级：只信任 JWT 的签名和过期时间，再用本地记录拦住最近退出登录的会话
+		if h.revoked.revoked(uc) {
+			return errSessionRevoked
+		}
+		return nil
 	}
-	if logout > 0 {
-		return errors.New("用户已经退出登录")
+	h.recover()
+	if !ok && uc.IssuedAt == nil {
+		// 没有签发时间的是上线会话注册表之前签发的 token，注册表里没有它们，按照原来的方式校验，
+		// 否则上线之后所有用户都会被踢下线。这些 token 不能刷新，最多 24 小时之后就全部过期了
+		ok, err = h.sessions.TouchLegacy(ctx, uc.Ssid)
+		if err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return errSessionRevoked
 	}
 	return nil
 }
 
+// canDegrade Redis 出错时判断能不能降级，并记录进入降级的时间
+func (h *RedisHandler) canDegrade(err error) bool {
+	if !h.degrade.Enabled {
+		return false
+	}
+	now := time.Now()
+	h.mu.Lock()
+	since := h.degradedSince
+	if since.IsZero() {
+		h.degradedSince = now
+	}
+	h.mu.Unlock()
+	if since.IsZero() {
+		h.logger.Warn("Redis 不可用，会话校验进入降级模式",
+			logger.Field{Key: "err", Value: err.Error()},
+			logger.Field{Key: "max_duration", Value: h.degrade.MaxDuration.String()})
+		return true
+	}
+	// 超过了降级的时长上限，不再只依赖 JWT
+	return now.Sub(since) <= h.degrade.MaxDuration
+}
+
+// recover Redis 可用时退出降级模式，并把降级期间的撤销记录补写到 Redis
+func (h *RedisHandler) recover() {
+	h.mu.Lock()
+	since := h.degradedSince
+	h.degradedSince = time.Time{}
+	h.mu.Unlock()
+	if !since.IsZero() {
+		h.logger.Info("Redis 已恢复，会话校验退出降级模式",
+			logger.Field{Key: "duration", Value: time.Since(since).String()})
+	}
+	if h.revoked.hasPending() && h.syncing.CompareAndSwap(false, true) {
+		go func() {
+			defer h.syncing.Store(false)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			h.syncRevoked(ctx)
+		}()
+	}
+}
+
+func (h *RedisHandler) syncRevoked(ctx context.Context) {
+	ssids, users := h.revoked.pending()
+	for ssid, r := range ssids {
+		err := h.sessions.Revoke(ctx, r.uid, ssid)
+		if err != nil && !errors.Is(err, cache.ErrSessionNotFound) {
+			// 留到下一次同步
+			h.logger.Error("同步撤销的会话失败",
+				logger.Field{Key: "ssid", Value: ssid},
+				logger.Field{Key: "err", Value: err.Error()})
+			continue
+		}
+		h.revoked.markSsidSynced(ssid)
+	}
+	for uid, r := range users {
+		err := h.sessions.RevokeOthers(ctx, uid, r.keep)
+		if err != nil {
+			h.logger.Error("同步撤销用户会话失败",
+				logger.Field{Key: "uid", Value: uid},
+				logger.Field{Key: "err", Value: err.Error()})
+			continue
+		}
+		h.revoked.markUserSynced(uid, r.before)
+	}
+	if len(ssids) > 0 || len(users) > 0 {
+		h.logger.Info("已同步降级期间撤销的会话",
+			logger.Field{Key: "ssids", Value: len(ssids)},
+			logger.Field{Key: "users", Value: len(users)})
+	}
+}
+
 func (h *RedisHandler) ExtractTokenString(ctx *gin.Context) string {
 	// 从请求头中获取Authorization字段，格式应该是 "Bearer token"
 	authCode := ctx.GetHeader("Authorization")
@@ -121,3 +312,27 @@ func (h *RedisHandler) ExtractTokenString(ctx *gin.Context) string {
 	tokenStr := authSegments[1]
 	return tokenStr
 }
+
+// deviceName 客户端上报了设备名称时直接使用，否则根据 User-Agent 粗略推断
+func deviceName(reported, userAgent string) string {
+	if reported != "" {
+		return reported
+	}
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"):
+		return "iPhone"
+	case strings.Contains(ua, "ipad"):
+		return "iPad"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "mac os"):
+		return "Mac"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	default:
+		return "未知设备"
+	}
+}
@@ -1,18 +1,48 @@
 package jwt
 
 import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository/cache"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Handler interface {
 	ClearToken(ctx *gin.Context) error
-	SetLoginToken(ctx *gin.Context, uid int64) error
-	SetJWTToken(ctx *gin.Context, ssid string, uid int64) error
-	CheckSession(ctx *gin.Context, ssid string) error
+	SetLoginToken(ctx *gin.Context, uid int64, role domain.Role) (string, error)
+	SetJWTToken(ctx *gin.Context, ssid string, uid int64, role domain.Role) error
+	// RotateRefreshToken 校验请求中的 refresh token 并轮换出新的 access token 和 refresh token
+	// 新 token 中的角色由 loadRole 重新查询，loadRole 返回 error 时不会轮换
+	RotateRefreshToken(ctx *gin.Context, loadRole RoleLoader) error
+	// ParseAccessToken 校验 access token 的签名并解析出其中的用户信息
+	ParseAccessToken(tokenStr string) (UserClaims, error)
+	// CheckSession 检查 token 对应的会话是否还有效
+	// Redis 不可用并且允许降级时，只拦截本进程内记录的最近撤销的会话
+	CheckSession(ctx *gin.Context, uc UserClaims) error
 	ExtractTokenString(ctx *gin.Context) string
+	// ClearAllSessions 让某个用户所有还没过期的会话失效
+	ClearAllSessions(ctx context.Context, uid int64) error
+	// ClearOtherSessions 让某个用户除了 ssid 之外的会话都失效
+	ClearOtherSessions(ctx context.Context, uid int64, ssid string) error
+	// ListSessions 返回某个用户所有还没失效的会话
+	ListSessions(ctx context.Context, uid int64) ([]domain.Session, error)
+	// ClearSession 让用户的某个会话失效，会话不属于该用户时返回 ErrSessionNotFound
+	ClearSession(ctx context.Context, uid int64, ssid string) error
 }
 
+var (
+	ErrSessionNotFound    = cache.ErrSessionNotFound
+	ErrRefreshTokenReused = cache.ErrRefreshTokenReused
+	ErrInvalidToken       = errors.New("token 不合法")
+)
+
+// RoleLoader 查询用户当前的角色，用户已经不能登录时返回 error
+type RoleLoader func(ctx context.Context, uid int64) (domain.Role, error)
+
+// RefreshClaims refresh token 中的信息，RegisteredClaims.ID 是这个 token 的唯一 ID，轮换时用来识别重复使用
+// 不保存角色，刷新时重新查询，修改角色之后下一次刷新就会生效
 type RefreshClaims struct {
 	Id   int64
 	Ssid string
@@ -23,5 +53,6 @@ type UserClaims struct {
 	Id        int64
 	UserAgent string
 	Ssid      string
+	Role      domain.Role
 	jwt.RegisteredClaims
 }
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	"badminton-backend/pkg/logger"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+)
+
+// PreAuthVO 需要二次验证时返回给前端，前端带着凭证调用 /api/v1/user/2fa/verify
+type PreAuthVO struct {
+	PreAuthToken string
+	Methods      []string
+}
+
+// beginTwoFactor 第一步登录通过之后检查是否需要二次验证
+// 返回 true 说明已经写回了响应（需要二次验证或者出错了），调用方不能再签发 token
+func beginTwoFactor(ctx *gin.Context, svc service.TwoFactorService, u domain.User, method string) bool {
+	token, methods, err := svc.Begin(ctx, u, method)
+	var locked *service.LoginLockedError
+	if errors.As(err, &locked) {
+		ctx.JSON(http.StatusOK, loginLockedResult(locked))
+		return true
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return true
+	}
+	if token == "" {
+		return false
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "需要二次验证",
+		Data: PreAuthVO{
+			PreAuthToken: token,
+			Methods:      methods,
+		},
+	})
+	return true
+}
+
+// loginLockedResult 密码或者第二步验证失败太多次，账号被临时锁定
+func loginLockedResult(locked *service.LoginLockedError) Result {
+	return Result{
+		Code: 14003,
+		Msg:  fmt.Sprintf("登录失败次数太多，请 %d 分钟后再试", int(math.Ceil(locked.RetryAfter.Minutes()))),
+	}
+}
+
+// recordLogin 记录登录历史，失败了不影响这次登录
+func recordLogin(ctx *gin.Context, svc service.LoginHistoryService, l logger.Logger, uid int64, ssid, method string) {
+	err := svc.Record(ctx, domain.LoginRecord{
+		UserID:    uid,
+		Ssid:      ssid,
+		Method:    method,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.GetHeader("User-Agent"),
+	})
+	if err != nil {
+		l.Error("记录登录历史失败",
+			logger.Field{Key: "uid", Value: uid},
+			logger.Field{Key: "err", Value: err.Error()})
+	}
+}
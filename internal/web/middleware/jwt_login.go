@@ -1,10 +1,12 @@
 package middleware
 
 import (
+	"badminton-backend/internal/service"
 	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"errors"
 	"github.com/ecodeclub/ekit/set"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"time"
 )
@@ -13,9 +15,11 @@ import (
 type JWTLoginMiddlewareBuilder struct {
 	publicPaths set.Set[string]
 	ijwt.Handler
+	userSvc service.UserService
+	logger  logger.Logger
 }
 
-func NewJWTLoginMiddlewareBuilder(hdl ijwt.Handler) *JWTLoginMiddlewareBuilder {
+func NewJWTLoginMiddlewareBuilder(hdl ijwt.Handler, userSvc service.UserService, l logger.Logger) *JWTLoginMiddlewareBuilder {
 	s := set.NewMapSet[string](5)
 	// 如果请求的路径是用户注册（/users/signup）或登录（/users/login）
 	// 这些接口不需要JWT验证，直接放行
@@ -24,12 +28,34 @@ func NewJWTLoginMiddlewareBuilder(hdl ijwt.Handler) *JWTLoginMiddlewareBuilder {
 	s.Add("/api/v1/user/login_sms")
 	s.Add("/api/v1/user/login")
 	s.Add("/api/v1/user/refresh_token")
+	s.Add("/api/v1/user/reset_password/code/send")
+	s.Add("/api/v1/user/reset_password")
+	// 个人数据下载凭借下载凭证鉴权
+	s.Add("/api/v1/user/data-archive/download")
+	// 二次验证凭借预登录凭证鉴权
+	s.Add("/api/v1/user/2fa/sms/send")
+	s.Add("/api/v1/user/2fa/verify")
+	// 第三方登录，回调由第三方页面跳转过来，没有 token
+	s.Add("/api/v1/oauth2/authurl")
+	s.Add("/api/v1/oauth2/callback")
+	// 公钥是公开的
+	s.Add("/.well-known/jwks.json")
 	return &JWTLoginMiddlewareBuilder{
 		publicPaths: s,
 		Handler:     hdl,
+		userSvc:     userSvc,
+		logger:      l,
 	}
 }
 
+// IgnorePaths 额外放行的路径，只在对应的路由注册了的时候调用
+func (j *JWTLoginMiddlewareBuilder) IgnorePaths(paths ...string) *JWTLoginMiddlewareBuilder {
+	for _, p := range paths {
+		j.publicPaths.Add(p)
+	}
+	return j
+}
+
 // Build 方法创建并返回一个Gin的中间件，负责JWT的验证。
 func (j *JWTLoginMiddlewareBuilder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -40,17 +66,9 @@ func (j *JWTLoginMiddlewareBuilder) Build() gin.HandlerFunc {
 
 		tokenStr := j.ExtractTokenString(ctx)
 
-		// 创建UserClaims结构体用于解析token中的claim信息
-		uc := ijwt.UserClaims{}
-
-		// 使用jwt.ParseWithClaims解析token并验证其合法性
-		// tokenStr是待验证的JWT字符串，uc是用于存放解析后的claims信息
-		// web.JWTKey是密钥，用于验证token的签名
-		token, err := jwt.ParseWithClaims(tokenStr, &uc, func(token *jwt.Token) (interface{}, error) {
-			return ijwt.AccessTokenKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		// 解析token并验证其合法性，根据 token 头部的 kid 选择校验的密钥
+		uc, err := j.ParseAccessToken(tokenStr)
+		if err != nil {
 			// 如果token解析失败或无效，返回401 Unauthorized
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
@@ -76,12 +94,32 @@ func (j *JWTLoginMiddlewareBuilder) Build() gin.HandlerFunc {
 			return
 		}
 
-		err = j.CheckSession(ctx, uc.Ssid)
+		err = j.CheckSession(ctx, uc)
 		if err != nil {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
+		// 禁用、注销账号的时候已经撤销了所有会话，这里再检查一次，防止撤销失败或者降级期间漏掉
+		u, err := j.userSvc.Profile(ctx, uc.Id)
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			// 账号已经被彻底删除
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		case err != nil:
+			// 缓存或者数据库出错时放行，不能让所有用户都无法访问
+			j.logger.Warn("检查账号状态失败",
+				logger.Field{Key: "uid", Value: uc.Id},
+				logger.Field{Key: "err", Value: err.Error()})
+		case u.Disabled || !u.Dtime.IsZero():
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		default:
+			// token 中的角色可能已经过时，鉴权以数据库中的角色为准
+			uc.Role = u.Role
+		}
+
 		ctx.Set("user", uc)
 	}
 }
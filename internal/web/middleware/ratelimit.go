@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/ginx/middleware/ratelimit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// RateLimitKeyByUser 按登录用户限流，必须放在 JWT 登录校验之后。不需要登录的接口按 IP 限流
+func RateLimitKeyByUser(ctx *gin.Context) string {
+	val, ok := ctx.Get("user")
+	if !ok {
+		return ratelimit.KeyByIP(ctx)
+	}
+	uc, ok := val.(ijwt.UserClaims)
+	if !ok {
+		return ratelimit.KeyByIP(ctx)
+	}
+	return "user:" + strconv.FormatInt(uc.Id, 10)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"badminton-backend/internal/domain"
+	ijwt "badminton-backend/internal/web/jwt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RequireRoles 只允许指定角色访问，必须放在 JWT 登录校验之后
+func RequireRoles(roles ...domain.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uc, ok := claims(ctx)
+		if !ok {
+			return
+		}
+		for _, role := range roles {
+			if uc.Role == role {
+				return
+			}
+		}
+		ctx.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+// RequirePermissions 要求拥有所有指定的权限，必须放在 JWT 登录校验之后
+func RequirePermissions(perms ...domain.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uc, ok := claims(ctx)
+		if !ok {
+			return
+		}
+		for _, perm := range perms {
+			if !uc.Role.HasPermission(perm) {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+	}
+}
+
+// claims 取出登录校验中间件放进去的用户信息，没有登录时直接返回 401
+func claims(ctx *gin.Context) (ijwt.UserClaims, bool) {
+	val, ok := ctx.Get("user")
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return ijwt.UserClaims{}, false
+	}
+	uc, ok := val.(ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return ijwt.UserClaims{}, false
+	}
+	return uc, true
+}
@@ -0,0 +1,262 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+var _ handler = &OAuth2Handler{}
+
+const (
+	// oauth2NonceCookie 把 state 绑定到发起授权的浏览器上，只在回调地址上发送
+	oauth2NonceCookie     = "oauth2_nonce"
+	oauth2NonceCookiePath = "/api/v1/oauth2/callback"
+	// oauth2NonceMaxAge 与 state 在 Redis 中的过期时间一致
+	oauth2NonceMaxAge = 600
+)
+
+// OAuth2Handler 第三方登录以及第三方身份的绑定、解绑
+type OAuth2Handler struct {
+	svc          service.OAuth2Service
+	historySvc   service.LoginHistoryService
+	twoFactorSvc service.TwoFactorService
+	ijwt.Handler
+	l logger.Logger
+}
+
+func NewOAuth2Handler(svc service.OAuth2Service, historySvc service.LoginHistoryService,
+	twoFactorSvc service.TwoFactorService, jwthdl ijwt.Handler, l logger.Logger) *OAuth2Handler {
+	return &OAuth2Handler{
+		svc:          svc,
+		historySvc:   historySvc,
+		twoFactorSvc: twoFactorSvc,
+		Handler:      jwthdl,
+		l:            l,
+	}
+}
+
+func (h *OAuth2Handler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	og := v1.Group("/oauth2")
+	og.GET("/authurl", h.AuthURL)
+	// 登录和绑定共用一个回调地址，用途记录在 state 中
+	og.GET("/callback", h.Callback)
+
+	ug := v1.Group("/user/oauth2")
+	ug.POST("/link", h.Link)
+	ug.POST("/unlink", h.Unlink)
+	ug.GET("/identities", h.Identities)
+}
+
+type UserIdentityVO struct {
+	Provider string
+	Nickname string
+	Ctime    string
+}
+
+// AuthURL 第三方登录的授权地址
+func (h *OAuth2Handler) AuthURL(ctx *gin.Context) {
+	h.authURL(ctx, ctx.Query("provider"), domain.OAuth2PurposeLogin, 0)
+}
+
+// Link 给当前账号绑定第三方身份，返回授权地址
+func (h *OAuth2Handler) Link(ctx *gin.Context) {
+	type Req struct {
+		Provider string `json:"provider"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	h.authURL(ctx, req.Provider, domain.OAuth2PurposeLink, uc.Id)
+}
+
+func (h *OAuth2Handler) authURL(ctx *gin.Context, provider, purpose string, uid int64) {
+	url, nonce, err := h.svc.AuthURL(ctx, provider, purpose, uid)
+	switch {
+	case err == nil:
+		h.setNonceCookie(ctx, nonce, oauth2NonceMaxAge)
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: url,
+		})
+	case errors.Is(err, service.ErrUnknownProvider):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "不支持的第三方平台",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *OAuth2Handler) Callback(ctx *gin.Context) {
+	if ctx.Query("error") != "" {
+		// 用户在第三方页面拒绝了授权
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "授权失败",
+		})
+		return
+	}
+	state, code := ctx.Query("state"), ctx.Query("code")
+	if state == "" || code == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "参数错误",
+		})
+		return
+	}
+	// 没有 cookie 时 nonce 是空字符串，会被当成 state 无效处理
+	nonce, _ := ctx.Cookie(oauth2NonceCookie)
+	h.setNonceCookie(ctx, "", -1)
+	res, err := h.svc.Callback(ctx, state, code, nonce)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidOAuth2State):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "授权已失效，请重试",
+		})
+		return
+	case errors.Is(err, service.ErrIdentityUsed):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "第三方账号已经绑定了其他账号",
+		})
+		return
+	case errors.Is(err, service.ErrProviderLinked):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "已经绑定过该平台的账号，请先解绑",
+		})
+		return
+	case errors.Is(err, service.ErrUserDeleted):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "账号已注销",
+		})
+		return
+	case errors.Is(err, service.ErrUserDisabled):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "账号已被禁用",
+		})
+		return
+	default:
+		h.l.Error("第三方授权回调失败", logger.Field{Key: "err", Value: err.Error()})
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+
+	if res.Purpose == domain.OAuth2PurposeLink {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "绑定成功",
+		})
+		return
+	}
+	if beginTwoFactor(ctx, h.twoFactorSvc, res.User, domain.LoginMethodOAuth2) {
+		return
+	}
+	ssid, err := h.SetLoginToken(ctx, res.User.Id, res.User.Role)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	recordLogin(ctx, h.historySvc, h.l, res.User.Id, ssid, domain.LoginMethodOAuth2)
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "登录成功",
+	})
+}
+
+// setNonceCookie 回调是从第三方页面跳转回来的顶层 GET 请求，SameSite=Lax 时 cookie 仍然会带上
+func (h *OAuth2Handler) setNonceCookie(ctx *gin.Context, nonce string, maxAge int) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oauth2NonceCookie, nonce, maxAge, oauth2NonceCookiePath, "", true, true)
+}
+
+func (h *OAuth2Handler) Unlink(ctx *gin.Context) {
+	type Req struct {
+		Provider string `json:"provider"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.Unlink(ctx, uc.Id, req.Provider)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "解绑成功",
+		})
+	case errors.Is(err, service.ErrIdentityNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "没有绑定该平台的账号",
+		})
+	case errors.Is(err, service.ErrAccountStateConflict):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "这是唯一的登录方式，请先绑定手机号或者其他平台",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *OAuth2Handler) Identities(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	identities, err := h.svc.Identities(ctx, uc.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	vos := make([]UserIdentityVO, 0, len(identities))
+	for _, i := range identities {
+		vos = append(vos, UserIdentityVO{
+			Provider: i.Provider,
+			Nickname: i.Nickname,
+			Ctime:    i.Ctime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: vos,
+	})
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var _ handler = &ReportHandler{}
+
+// ReportHandler 周报/月报相关的接口
+type ReportHandler struct {
+	svc service.TrainingReportService
+}
+
+func NewReportHandler(svc service.TrainingReportService) *ReportHandler {
+	return &ReportHandler{
+		svc: svc,
+	}
+}
+
+func (h *ReportHandler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	g := v1.Group("/report")
+
+	g.POST("/latest", h.Latest)
+	g.POST("/list", h.List)
+}
+
+func (h *ReportHandler) Latest(ctx *gin.Context) {
+	type Req struct {
+		Period string `json:"period"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if req.Period == "" {
+		req.Period = domain.ReportPeriodWeek
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	report, err := h.svc.Latest(ctx, uc.Id, req.Period)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: report,
+		})
+	case errors.Is(err, service.ErrUnknownReportPeriod):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "报告周期不正确",
+		})
+	case errors.Is(err, service.ErrReportNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "还没有生成报告",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *ReportHandler) List(ctx *gin.Context) {
+	type Req struct {
+		Period string `json:"period"`
+		Offset int    `json:"offset"`
+		Limit  int    `json:"limit"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if req.Period == "" {
+		req.Period = domain.ReportPeriodWeek
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 10
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	reports, err := h.svc.List(ctx, uc.Id, req.Period, req.Offset, req.Limit)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: reports,
+		})
+	case errors.Is(err, service.ErrUnknownReportPeriod):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "报告周期不正确",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	ijwt "badminton-backend/internal/web/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var _ handler = &SessionHandler{}
+
+// SessionHandler 多设备登录管理
+type SessionHandler struct {
+	ijwt.Handler
+}
+
+func NewSessionHandler(jwthdl ijwt.Handler) *SessionHandler {
+	return &SessionHandler{
+		Handler: jwthdl,
+	}
+}
+
+func (h *SessionHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/api/v1/user/sessions")
+	g.GET("", h.List)
+	g.POST("/revoke", h.Revoke)
+	g.POST("/revoke_others", h.RevokeOthers)
+}
+
+func (h *SessionHandler) List(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	sessions, err := h.ListSessions(ctx, uc.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	// 最近使用过的排在前面
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	vos := make([]SessionVO, 0, len(sessions))
+	for _, s := range sessions {
+		vos = append(vos, sessionVO(s, uc.Ssid))
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: vos,
+	})
+}
+
+func (h *SessionHandler) Revoke(ctx *gin.Context) {
+	type Req struct {
+		Ssid string `json:"ssid"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	if req.Ssid == uc.Ssid {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "不能下线当前设备，请使用退出登录",
+		})
+		return
+	}
+	err := h.ClearSession(ctx, uc.Id, req.Ssid)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, ijwt.ErrSessionNotFound):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "会话不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+// RevokeOthers 退出其他所有设备，当前设备保持登录
+func (h *SessionHandler) RevokeOthers(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.ClearOtherSessions(ctx, uc.Id, uc.Ssid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+	})
+}
+
+// SessionVO 返回给前端的登录会话
+type SessionVO struct {
+	Ssid      string
+	Device    string
+	IP        string
+	UserAgent string
+	LoginTime string
+	LastSeen  string
+	Current   bool // 是不是当前正在使用的会话
+}
+
+func sessionVO(s domain.Session, current string) SessionVO {
+	return SessionVO{
+		Ssid:      s.Ssid,
+		Device:    s.Device,
+		IP:        s.IP,
+		UserAgent: s.UserAgent,
+		LoginTime: s.LoginTime.Format(time.DateTime),
+		LastSeen:  s.LastSeen.Format(time.DateTime),
+		Current:   s.Ssid == current,
+	}
+}
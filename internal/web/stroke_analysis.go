@@ -0,0 +1,88 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+var _ handler = &StrokeAnalysisHandler{}
+
+// StrokeAnalysisHandler 击球结构分析相关的接口
+type StrokeAnalysisHandler struct {
+	svc service.StrokeAnalysisService
+}
+
+func NewStrokeAnalysisHandler(svc service.StrokeAnalysisService) *StrokeAnalysisHandler {
+	return &StrokeAnalysisHandler{
+		svc: svc,
+	}
+}
+
+func (h *StrokeAnalysisHandler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	g := v1.Group("/analysis")
+
+	g.POST("/stroke-mix", h.StrokeMix)
+}
+
+func (h *StrokeAnalysisHandler) StrokeMix(ctx *gin.Context) {
+	type Req struct {
+		StartDateStr string `json:"start_date"`
+		EndDateStr   string `json:"end_date"`
+		// ideal：教练设定的理想结构；average：所有用户的平均结构
+		Reference string `json:"reference"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	startDate, err := time.Parse(time.DateOnly, req.StartDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+	endDate, err := time.Parse(time.DateOnly, req.EndDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+	if req.Reference == "" {
+		req.Reference = domain.StrokeReferenceIdeal
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	res, err := h.svc.Analyze(ctx, uc.Id, req.Reference, startDate, endDate)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: res,
+		})
+	case errors.Is(err, service.ErrUnknownStrokeReference):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "参考画像不正确",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
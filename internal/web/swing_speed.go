@@ -0,0 +1,223 @@
+package web
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+var _ handler = &SwingSpeedHandler{}
+
+// SwingSpeedHandler 挥拍速度分布相关的接口
+type SwingSpeedHandler struct {
+	svc service.SwingSpeedService
+}
+
+func NewSwingSpeedHandler(svc service.SwingSpeedService) *SwingSpeedHandler {
+	return &SwingSpeedHandler{
+		svc: svc,
+	}
+}
+
+func (h *SwingSpeedHandler) RegisterRoutes(server *gin.Engine) {
+	v1 := server.Group("/api/v1")
+	g := v1.Group("/swing-speed")
+
+	g.POST("/upload", h.Upload)
+	g.POST("/date", h.GetByDate)
+	g.POST("/trend", h.Trend)
+	g.POST("/percentile", h.Percentile)
+}
+
+func (h *SwingSpeedHandler) Upload(ctx *gin.Context) {
+	type Req struct {
+		Date string `json:"date"`
+		// 击球类型 -> 当天该类型每一次挥拍的速度（km/h）
+		Samples map[string][]int `json:"samples"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	date, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err = h.svc.Upload(ctx, uc.Id, date, req.Samples)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrUnknownStrokeType):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "击球类型不正确",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *SwingSpeedHandler) GetByDate(ctx *gin.Context) {
+	type Req struct {
+		Date string `json:"date"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	date, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	dists, err := h.svc.GetByDate(ctx, uc.Id, date)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: dists,
+	})
+}
+
+func (h *SwingSpeedHandler) Trend(ctx *gin.Context) {
+	req, ok := h.bindRangeReq(ctx)
+	if !ok {
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	dists, err := h.svc.Trend(ctx, uc.Id, req.strokeType, req.startDate, req.endDate)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: dists,
+		})
+	case errors.Is(err, service.ErrUnknownStrokeType):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "击球类型不正确",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *SwingSpeedHandler) Percentile(ctx *gin.Context) {
+	req, ok := h.bindRangeReq(ctx)
+	if !ok {
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	res, err := h.svc.Percentile(ctx, uc.Id, req.strokeType, req.startDate, req.endDate)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: res,
+		})
+	case errors.Is(err, service.ErrUnknownStrokeType):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "击球类型不正确",
+		})
+	case errors.Is(err, service.ErrProfileIncomplete):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "请先在个人资料中填写性别和生日",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+type swingSpeedRangeReq struct {
+	strokeType string
+	startDate  time.Time
+	endDate    time.Time
+}
+
+// bindRangeReq 解析区间查询的请求参数，解析失败时已经写回了响应
+func (h *SwingSpeedHandler) bindRangeReq(ctx *gin.Context) (swingSpeedRangeReq, bool) {
+	type Req struct {
+		StrokeType   string `json:"stroke_type"`
+		StartDateStr string `json:"start_date"`
+		EndDateStr   string `json:"end_date"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return swingSpeedRangeReq{}, false
+	}
+	startDate, err := time.Parse(time.DateOnly, req.StartDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return swingSpeedRangeReq{}, false
+	}
+	endDate, err := time.Parse(time.DateOnly, req.EndDateStr)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "日期格式不对",
+		})
+		return swingSpeedRangeReq{}, false
+	}
+	if req.StrokeType == "" {
+		req.StrokeType = domain.StrokeTypeAll
+	}
+	return swingSpeedRangeReq{
+		strokeType: req.StrokeType,
+		startDate:  startDate,
+		endDate:    endDate,
+	}, true
+}
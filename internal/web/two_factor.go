@@ -0,0 +1,308 @@
+package web
+
+import (
+	"badminton-backend/internal/service"
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var _ handler = &TwoFactorHandler{}
+
+// TwoFactorHandler TOTP 的开启、关闭，以及登录时的第二步验证
+type TwoFactorHandler struct {
+	svc        service.TwoFactorService
+	historySvc service.LoginHistoryService
+	ijwt.Handler
+	l logger.Logger
+}
+
+func NewTwoFactorHandler(svc service.TwoFactorService, historySvc service.LoginHistoryService,
+	jwthdl ijwt.Handler, l logger.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		svc:        svc,
+		historySvc: historySvc,
+		Handler:    jwthdl,
+		l:          l,
+	}
+}
+
+func (h *TwoFactorHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/api/v1/user/2fa")
+	g.GET("/status", h.Status)
+	g.POST("/totp/setup", h.SetupTOTP)
+	g.POST("/totp/confirm", h.ConfirmTOTP)
+	g.POST("/totp/disable", h.DisableTOTP)
+	g.POST("/recovery_codes", h.RegenerateRecoveryCodes)
+
+	// 登录的第二步，这时候还没有 token
+	g.POST("/sms/send", h.SendSMSCode)
+	g.POST("/verify", h.Verify)
+}
+
+type TwoFactorStatusVO struct {
+	TOTPEnabled       bool
+	RecoveryCodesLeft int64
+}
+
+type TOTPSetupVO struct {
+	Secret string
+	URI    string
+}
+
+func (h *TwoFactorHandler) Status(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	status, err := h.svc.Status(ctx, uc.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "OK",
+		Data: TwoFactorStatusVO{
+			TOTPEnabled:       status.TOTPEnabled,
+			RecoveryCodesLeft: status.RecoveryCodesLeft,
+		},
+	})
+}
+
+func (h *TwoFactorHandler) SetupTOTP(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	secret, uri, err := h.svc.SetupTOTP(ctx, uc.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: TOTPSetupVO{
+				Secret: secret,
+				URI:    uri,
+			},
+		})
+	case errors.Is(err, service.ErrTOTPEnabled):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "已经开启了二次验证",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *TwoFactorHandler) ConfirmTOTP(ctx *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	codes, err := h.svc.ConfirmTOTP(ctx, uc.Id, req.Code)
+	h.writeRecoveryCodes(ctx, codes, err)
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(ctx *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	codes, err := h.svc.RegenerateRecoveryCodes(ctx, uc.Id, req.Code)
+	h.writeRecoveryCodes(ctx, codes, err)
+}
+
+// writeRecoveryCodes 恢复码只在这里返回一次，前端需要提示用户保存好
+func (h *TwoFactorHandler) writeRecoveryCodes(ctx *gin.Context, codes []string, err error) {
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+			Data: codes,
+		})
+	case errors.Is(err, service.ErrInvalidCode):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+	case errors.Is(err, service.ErrTOTPEnabled):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "已经开启了二次验证",
+		})
+	case errors.Is(err, service.ErrTOTPNotEnabled):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "请先设置二次验证",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *TwoFactorHandler) DisableTOTP(ctx *gin.Context) {
+	type Req struct {
+		// Code 验证码或者恢复码
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.DisableTOTP(ctx, uc.Id, req.Code)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "已关闭二次验证",
+		})
+	case errors.Is(err, service.ErrInvalidCode):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+	case errors.Is(err, service.ErrTOTPNotEnabled):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "没有开启二次验证",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *TwoFactorHandler) SendSMSCode(ctx *gin.Context) {
+	type Req struct {
+		PreAuthToken string `json:"pre_auth_token"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	err := h.svc.SendSMSCode(ctx, req.PreAuthToken)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrPreAuthInvalid):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "登录已失效，请重新登录",
+		})
+	case errors.Is(err, service.ErrTwoFactorMethodUnavailable):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "不能使用短信验证",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14003,
+			Msg:  "短信发送太频繁，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (h *TwoFactorHandler) Verify(ctx *gin.Context) {
+	type Req struct {
+		PreAuthToken string `json:"pre_auth_token"`
+		Method       string `json:"method"`
+		Code         string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	pa, err := h.svc.Verify(ctx, req.PreAuthToken, req.Method, req.Code)
+	var locked *service.LoginLockedError
+	switch {
+	case err == nil:
+	case errors.As(err, &locked):
+		ctx.JSON(http.StatusOK, loginLockedResult(locked))
+		return
+	case errors.Is(err, service.ErrPreAuthInvalid):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "登录已失效，请重新登录",
+		})
+		return
+	case errors.Is(err, service.ErrInvalidCode):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+		return
+	case errors.Is(err, service.ErrTwoFactorMethodUnavailable):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "不支持这种验证方式",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+
+	ssid, err := h.SetLoginToken(ctx, pa.UserID, pa.Role)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	recordLogin(ctx, h.historySvc, h.l, pa.UserID, ssid, pa.LoginMethod)
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "登录成功",
+	})
+}
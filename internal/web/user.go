@@ -4,11 +4,11 @@ import (
 	"badminton-backend/internal/domain"
 	"badminton-backend/internal/service"
 	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"context"
 	"errors"
 	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"net/http"
 	"time"
 )
@@ -27,19 +27,27 @@ var _ handler = &UserHandler{}
 type UserHandler struct {
 	svc              service.UserService
 	codeSvc          service.CodeService
+	historySvc       service.LoginHistoryService
+	twoFactorSvc     service.TwoFactorService
 	phoneRegexExp    *regexp.Regexp
 	passwordRegexExp *regexp.Regexp
 
 	ijwt.Handler
+	l logger.Logger
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService, jwthdl ijwt.Handler) *UserHandler {
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService,
+	historySvc service.LoginHistoryService, twoFactorSvc service.TwoFactorService,
+	jwthdl ijwt.Handler, l logger.Logger) *UserHandler {
 	return &UserHandler{
 		svc:              svc,
 		codeSvc:          codeSvc,
+		historySvc:       historySvc,
+		twoFactorSvc:     twoFactorSvc,
 		phoneRegexExp:    regexp.MustCompile(phoneRegexPattern, regexp.None),
 		passwordRegexExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
 		Handler:          jwthdl,
+		l:                l,
 	}
 }
 
@@ -57,30 +65,29 @@ func (c *UserHandler) RegisterRoutes(server *gin.Engine) {
 	ug.POST("/login_sms/code/send", c.SendSMSLoginCode)
 	ug.POST("/login_sms", c.LoginSMS)
 	ug.POST("/refresh_token", c.RefreshToken)
+
+	ug.POST("/change_password", c.ChangePassword)
+	ug.POST("/reset_password/code/send", c.SendResetPasswordCode)
+	ug.POST("/reset_password", c.ResetPassword)
 }
 
 func (c *UserHandler) RefreshToken(ctx *gin.Context) {
-	tokenStr := c.ExtractTokenString(ctx)
-	var rc ijwt.RefreshClaims
-	token, err := jwt.ParseWithClaims(tokenStr, &rc, func(token *jwt.Token) (interface{}, error) {
-		return ijwt.RefreshTokenKey, nil
+	// 角色以数据库为准，被修改过角色的用户刷新之后拿到的是新的角色
+	err := c.RotateRefreshToken(ctx, func(ctx context.Context, uid int64) (domain.Role, error) {
+		u, err := c.svc.Profile(ctx, uid)
+		if err != nil {
+			return 0, err
+		}
+		if u.Disabled || !u.Dtime.IsZero() {
+			return 0, ijwt.ErrInvalidToken
+		}
+		return u.Role, nil
 	})
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, Result{
-			Code: 14001,
-			Msg:  "请登录",
-		})
-		return
-	}
-	if token == nil || !token.Valid {
-		ctx.JSON(http.StatusUnauthorized, Result{
-			Code: 14001,
-			Msg:  "请登录",
-		})
-		return
+	if errors.Is(err, ijwt.ErrRefreshTokenReused) {
+		// 已经用过的 refresh token 又被拿来用了，会话已经被撤销
+		c.l.Warn("refresh token 被重复使用，已撤销会话",
+			logger.Field{Key: "ip", Value: ctx.ClientIP()})
 	}
-
-	err = c.CheckSession(ctx, rc.Ssid)
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, Result{
 			Code: 14001,
@@ -88,16 +95,7 @@ func (c *UserHandler) RefreshToken(ctx *gin.Context) {
 		})
 		return
 	}
-
-	err = c.SetJWTToken(ctx, rc.Ssid, rc.Id)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, Result{
-			Code: 14001,
-			Msg:  "请登录",
-		})
-		return
-	}
-	ctx.JSON(http.StatusUnauthorized, Result{
+	ctx.JSON(http.StatusOK, Result{
 		Code: 10000,
 		Msg:  "OK",
 	})
@@ -135,6 +133,20 @@ func (c *UserHandler) LoginSMS(ctx *gin.Context) {
 	// 验证码是对的
 	// 登录或者注册用户
 	u, err := c.svc.FindOrCreate(ctx, req.Phone)
+	if errors.Is(err, service.ErrUserDeleted) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "账号已注销",
+		})
+		return
+	}
+	if errors.Is(err, service.ErrUserDisabled) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "账号已被禁用",
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusOK, Result{
 			Code: 25001,
@@ -143,8 +155,10 @@ func (c *UserHandler) LoginSMS(ctx *gin.Context) {
 		return
 	}
 
-	ssid := uuid.New().String()
-	err = c.SetJWTToken(ctx, ssid, u.Id)
+	if beginTwoFactor(ctx, c.twoFactorSvc, u, domain.LoginMethodSMS) {
+		return
+	}
+	ssid, err := c.SetLoginToken(ctx, u.Id, u.Role)
 	if err != nil {
 		ctx.JSON(http.StatusOK, Result{
 			Code: 25001,
@@ -152,6 +166,7 @@ func (c *UserHandler) LoginSMS(ctx *gin.Context) {
 		})
 		return
 	}
+	recordLogin(ctx, c.historySvc, c.l, u.Id, ssid, domain.LoginMethodSMS)
 	ctx.JSON(http.StatusOK, Result{
 		Code: 10000,
 		Msg:  "OK",
@@ -281,6 +296,8 @@ func (c *UserHandler) Login(ctx *gin.Context) {
 	type LoginReq struct {
 		Account  string `json:"account"`
 		Password string `json:"password"`
+		// CaptchaToken 返回 14006 之后前端完成人机验证再带上
+		CaptchaToken string `json:"captcha_token"`
 	}
 	var req LoginReq
 	if err := ctx.Bind(&req); err != nil {
@@ -291,7 +308,29 @@ func (c *UserHandler) Login(ctx *gin.Context) {
 		return
 	}
 
-	u, err := c.svc.Login(ctx.Request.Context(), req.Account, req.Password)
+	u, err := c.svc.Login(ctx.Request.Context(), req.Account, req.Password, domain.LoginClient{
+		IP:           ctx.ClientIP(),
+		CaptchaToken: req.CaptchaToken,
+	})
+	var locked *service.LoginLockedError
+	if errors.As(err, &locked) {
+		ctx.JSON(http.StatusOK, loginLockedResult(locked))
+		return
+	}
+	if errors.Is(err, service.ErrLoginTooFrequent) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14003,
+			Msg:  "登录太频繁，请稍后再试",
+		})
+		return
+	}
+	if errors.Is(err, service.ErrCaptchaRequired) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14006,
+			Msg:  "请先完成人机验证",
+		})
+		return
+	}
 	if errors.Is(err, service.ErrInvalidUserOrPassword) {
 		ctx.JSON(http.StatusOK, Result{
 			Code: 14001,
@@ -299,8 +338,32 @@ func (c *UserHandler) Login(ctx *gin.Context) {
 		})
 		return
 	}
+	if errors.Is(err, service.ErrUserDeleted) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "账号已注销",
+		})
+		return
+	}
+	if errors.Is(err, service.ErrUserDisabled) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14001,
+			Msg:  "账号已被禁用",
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
 
-	err = c.SetLoginToken(ctx, u.Id)
+	if beginTwoFactor(ctx, c.twoFactorSvc, u, domain.LoginMethodPassword) {
+		return
+	}
+	ssid, err := c.SetLoginToken(ctx, u.Id, u.Role)
 	if err != nil {
 		ctx.JSON(http.StatusOK, Result{
 			Code: 25001,
@@ -308,6 +371,7 @@ func (c *UserHandler) Login(ctx *gin.Context) {
 		})
 		return
 	}
+	recordLogin(ctx, c.historySvc, c.l, u.Id, ssid, domain.LoginMethodPassword)
 	ctx.JSON(http.StatusOK, Result{
 		Code: 10000,
 		Msg:  "登录成功",
@@ -332,6 +396,7 @@ func (c *UserHandler) Logout(ctx *gin.Context) {
 func (c *UserHandler) Edit(ctx *gin.Context) {
 	type Req struct {
 		Username string `json:"username"`
+		Gender   int    `json:"gender"`
 		WeightKg int    `json:"weightKg"`
 		HeightCm int    `json:"heightCm"`
 		Nickname string `json:"nickname"`
@@ -354,6 +419,12 @@ func (c *UserHandler) Edit(ctx *gin.Context) {
 			Msg:  "自我介绍过长"})
 		return
 	}
+	if req.Gender < domain.GenderUnknown || req.Gender > domain.GenderFemale {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "性别不正确"})
+		return
+	}
 	var birthday time.Time
 	if req.Birthday != "" {
 		var err error
@@ -372,6 +443,7 @@ func (c *UserHandler) Edit(ctx *gin.Context) {
 		Id:       uc.Id,
 		Username: req.Username,
 		Nickname: req.Nickname,
+		Gender:   req.Gender,
 		WeightKG: req.WeightKg,
 		HeightCM: req.HeightCm,
 		AboutMe:  req.AboutMe,
@@ -397,6 +469,7 @@ func (c *UserHandler) Profile(ctx *gin.Context) {
 		Username string
 		Account  string
 		Phone    string
+		Gender   int
 		WeightKg int
 		HeightCm int
 		Nickname string
@@ -421,6 +494,7 @@ func (c *UserHandler) Profile(ctx *gin.Context) {
 			Username: u.Username,
 			Account:  u.Account,
 			Phone:    u.Phone,
+			Gender:   u.Gender,
 			WeightKg: u.WeightKG,
 			HeightCm: u.HeightCM,
 			Nickname: u.Nickname,
@@ -429,3 +503,194 @@ func (c *UserHandler) Profile(ctx *gin.Context) {
 		},
 	})
 }
+
+func (c *UserHandler) ChangePassword(ctx *gin.Context) {
+	type Req struct {
+		OldPassword     string `json:"oldPassword"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !c.checkNewPassword(ctx, req.Password, req.ConfirmPassword) {
+		return
+	}
+
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := c.svc.ChangePassword(ctx, uc.Id, req.OldPassword, req.Password)
+	if errors.Is(err, service.ErrInvalidUserOrPassword) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "旧密码不正确",
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	// 密码改了，其他设备上的登录都要失效，当前设备保持登录
+	err = c.ClearOtherSessions(ctx, uc.Id, uc.Ssid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "密码修改成功",
+	})
+}
+
+func (c *UserHandler) SendResetPasswordCode(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	isPhone, err := c.phoneRegexExp.MatchString(req.Phone)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !isPhone {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "手机号格式不正确",
+		})
+		return
+	}
+
+	// 不管手机号有没有注册都发送，避免被用来探测手机号是否注册
+	err = c.codeSvc.Send(ctx, service.BizResetPassword, req.Phone)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 10000,
+			Msg:  "OK",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14003,
+			Msg:  "短信发送太频繁，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+	}
+}
+
+func (c *UserHandler) ResetPassword(ctx *gin.Context) {
+	type Req struct {
+		Phone           string `json:"phone"`
+		Code            string `json:"code"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !c.checkNewPassword(ctx, req.Password, req.ConfirmPassword) {
+		return
+	}
+
+	ok, err := c.codeSvc.Verify(ctx, service.BizResetPassword, req.Phone, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "验证码错误",
+		})
+		return
+	}
+
+	u, err := c.svc.ResetPassword(ctx, req.Phone, req.Password)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrUserDeleted):
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14004,
+			Msg:  "手机号没有注册",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	// 忘记密码的情况下所有已经登录的设备都可能不安全
+	err = c.ClearAllSessions(ctx, u.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code: 10000,
+		Msg:  "密码重置成功，请重新登录",
+	})
+}
+
+// checkNewPassword 校验新密码，不合法时直接写回错误响应
+func (c *UserHandler) checkNewPassword(ctx *gin.Context, password, confirmPassword string) bool {
+	if password != confirmPassword {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "两次输入的密码不相同",
+		})
+		return false
+	}
+	isPassword, err := c.passwordRegexExp.MatchString(password)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 25001,
+			Msg:  "服务异常",
+		})
+		return false
+	}
+	if !isPassword {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 14002,
+			Msg:  "密码必须包含字母、数字、特殊字符，并且长度不能小于 8 位",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"time"
+)
+
+func InitAccountService(userRepo repository.UserRepository, summaryRepo repository.DailySummaryRepository,
+	codeSvc service.CodeService, l logger.Logger) service.AccountService {
+	cfg := service.AccountConfig{
+		GracePeriod: time.Hour * 24 * 7,
+		BatchSize:   100,
+	}
+	err := viper.UnmarshalKey("account", &cfg)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", cfg, err))
+	}
+	// 导出、导入、数据打包过程中产生的文件同样属于用户的个人数据
+	exportDir, importDir, archiveDir := defaultExportDir, defaultImportDir, defaultArchiveDir
+	if dir := viper.GetString("export.dir"); dir != "" {
+		exportDir = dir
+	}
+	if dir := viper.GetString("import.dir"); dir != "" {
+		importDir = dir
+	}
+	if dir := viper.GetString("archive.dir"); dir != "" {
+		archiveDir = dir
+	}
+	cfg.FilePatterns = append(cfg.FilePatterns,
+		filepath.Join(exportDir, "export-%d-*"),
+		filepath.Join(importDir, "import-%d-*"),
+		filepath.Join(archiveDir, "archive-%d-*"))
+	return service.NewAccountService(userRepo, summaryRepo, codeSvc, cfg, l)
+}
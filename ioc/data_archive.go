@@ -0,0 +1,35 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/internal/service/notify"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+const defaultArchiveDir = "./data/archive"
+
+func InitDataArchiveService(repo repository.DataArchiveRepository,
+	userRepo repository.UserRepository,
+	summaryRepo repository.DailySummaryRepository,
+	swingRepo repository.SwingSpeedRepository,
+	reportRepo repository.TrainingReportRepository,
+	sessionRepo repository.SessionRepository,
+	historyRepo repository.LoginHistoryRepository,
+	notifier notify.Notifier, l logger.Logger) service.DataArchiveService {
+	cfg := service.DataArchiveConfig{
+		Dir:         defaultArchiveDir,
+		TokenTTL:    time.Hour * 72,
+		JobTimeout:  time.Minute * 30,
+		DownloadURL: "http://localhost:8080/api/v1/user/data-archive/download?token=%s",
+	}
+	err := viper.UnmarshalKey("archive", &cfg)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", cfg, err))
+	}
+	return service.NewDataArchiveService(repo, userRepo, summaryRepo, swingRepo, reportRepo,
+		sessionRepo, historyRepo, notifier, cfg, l)
+}
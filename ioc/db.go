@@ -1,6 +1,7 @@
 package ioc
 
 import (
+	"badminton-backend/internal/repository/dao"
 	"badminton-backend/pkg/logger"
 	"fmt"
 	"github.com/spf13/viper"
@@ -12,6 +13,8 @@ import (
 func InitDB(l logger.Logger) *gorm.DB {
 	type Config struct {
 		DSN string `yaml:"dsn"`
+		// AutoMigrate 启动时自动建表，生产环境关闭，使用 script/mysql/migration 中的脚本
+		AutoMigrate bool `yaml:"autoMigrate"`
 	}
 	c := Config{
 		DSN: "root:root@tcp(localhost:3306)/mysql", // 默认的数据库连接字符串
@@ -35,11 +38,13 @@ func InitDB(l logger.Logger) *gorm.DB {
 		panic(err) // 打开数据库失败时，抛出 panic
 	}
 
-	//// 初始化数据库表结构
-	//err = dao.InitTables(db)
-	//if err != nil {
-	//	panic(err) // 初始化表失败时，抛出 panic
-	//}
+	// 初始化数据库表结构
+	if c.AutoMigrate {
+		err = dao.InitTables(db)
+		if err != nil {
+			panic(err) // 初始化表失败时，抛出 panic
+		}
+	}
 
 	return db // 返回数据库连接对象
 }
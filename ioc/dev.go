@@ -0,0 +1,52 @@
+package ioc
+
+import (
+	"badminton-backend/internal/service/sms/memory"
+	"badminton-backend/internal/web"
+	"fmt"
+	"github.com/spf13/viper"
+	"net"
+)
+
+// isDev 只有明确配置为 dev 才算开发环境
+// 开发环境会开放不需要登录的调试接口，监听的不是本机地址时拒绝启动，
+// 除非是用 -tags dev 编译出来的专门用于测试环境的包
+func isDev() bool {
+	if viper.GetString("env") != "dev" {
+		return false
+	}
+	if addr := ServerAddr(); !devBuild && !isLoopback(addr) {
+		panic(fmt.Errorf("env 为 dev 时只能监听本机地址，当前 server.addr 为 %q；测试环境需要用 -tags dev 编译", addr))
+	}
+	return true
+}
+
+// ServerAddr HTTP 服务监听的地址，默认只监听本机
+func ServerAddr() string {
+	addr := viper.GetString("server.addr")
+	if addr == "" {
+		return "localhost:8080"
+	}
+	return addr
+}
+
+// isLoopback 没有写主机名（比如 :8080）表示监听所有网卡，不算本机地址
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// InitDevSMSHandler 不是开发环境时返回 nil，不注册路由
+func InitDevSMSHandler(store *memory.Service) *web.DevSMSHandler {
+	if !isDev() {
+		return nil
+	}
+	return web.NewDevSMSHandler(store)
+}
@@ -0,0 +1,6 @@
+//go:build dev
+
+package ioc
+
+// devBuild 用 -tags dev 编译时允许开发环境监听非本机地址
+const devBuild = true
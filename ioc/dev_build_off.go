@@ -0,0 +1,5 @@
+//go:build !dev
+
+package ioc
+
+const devBuild = false
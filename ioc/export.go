@@ -0,0 +1,26 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+const defaultExportDir = "./data/export"
+
+func InitExportService(summaryRepo repository.DailySummaryRepository,
+	jobRepo repository.ExportJobRepository, l logger.Logger) service.ExportService {
+	cfg := service.ExportConfig{
+		Dir:         defaultExportDir,
+		SyncMaxRows: 1000,
+		JobTimeout:  time.Minute * 30,
+	}
+	err := viper.UnmarshalKey("export", &cfg)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", cfg, err))
+	}
+	return service.NewExportService(summaryRepo, jobRepo, cfg, l)
+}
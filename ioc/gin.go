@@ -1,23 +1,36 @@
 package ioc
 
 import (
+	"badminton-backend/internal/service"
 	"badminton-backend/internal/web"
 	ijwt "badminton-backend/internal/web/jwt"
 	"badminton-backend/internal/web/middleware"
 	"badminton-backend/pkg/ginx/middleware/accesslog"
-	"badminton-backend/pkg/ginx/middleware/ratelimit"
 	"badminton-backend/pkg/logger"
 	"context"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 	"strings"
 	"time"
 )
 
-func InitWebServer(funcs []gin.HandlerFunc, userHdl *web.UserHandler, summaryHdl *web.DailySummaryHandler) *gin.Engine {
+func InitWebServer(funcs []gin.HandlerFunc, userHdl *web.UserHandler, summaryHdl *web.DailySummaryHandler,
+	swingSpeedHdl *web.SwingSpeedHandler, strokeAnalysisHdl *web.StrokeAnalysisHandler,
+	reportHdl *web.ReportHandler, exportHdl *web.ExportHandler, importHdl *web.ImportHandler,
+	accountHdl *web.AccountHandler, archiveHdl *web.DataArchiveHandler,
+	sessionHdl *web.SessionHandler, jwksHdl *web.JWKSHandler, adminHdl *web.AdminHandler,
+	oauth2Hdl *web.OAuth2Handler, twoFactorHdl *web.TwoFactorHandler, devSMSHdl *web.DevSMSHandler) *gin.Engine {
 	server := gin.Default() // 初始化一个默认的 Gin 引擎实例
 	gin.ForceConsoleColor() // 强制开启控制台的彩色输出
+	// 默认信任所有代理，任何人都能伪造 X-Forwarded-For 绕过按 IP 的限流和登录保护
+	// 只信任配置的反向代理，没有配置时 ClientIP 就是连接的对端地址
+	err := server.SetTrustedProxies(viper.GetStringSlice("server.trustedProxies"))
+	if err != nil {
+		panic(fmt.Errorf("server.trustedProxies 配置不正确 %w", err))
+	}
 
 	// 使用传入的中间件
 	server.Use(funcs...)
@@ -25,18 +38,44 @@ func InitWebServer(funcs []gin.HandlerFunc, userHdl *web.UserHandler, summaryHdl
 	// 注册路由
 	userHdl.RegisterRoutes(server)
 	summaryHdl.RegisterRoutes(server)
+	swingSpeedHdl.RegisterRoutes(server)
+	strokeAnalysisHdl.RegisterRoutes(server)
+	reportHdl.RegisterRoutes(server)
+	exportHdl.RegisterRoutes(server)
+	importHdl.RegisterRoutes(server)
+	accountHdl.RegisterRoutes(server)
+	archiveHdl.RegisterRoutes(server)
+	sessionHdl.RegisterRoutes(server)
+	jwksHdl.RegisterRoutes(server)
+	adminHdl.RegisterRoutes(server)
+	oauth2Hdl.RegisterRoutes(server)
+	twoFactorHdl.RegisterRoutes(server)
+	// 调试用的接口只在开发环境注册
+	if devSMSHdl != nil {
+		devSMSHdl.RegisterRoutes(server)
+	}
 
 	return server // 返回配置好的 Gin 引擎实例
 }
 
-func GinMiddlewares(cmd redis.Cmdable, hdl ijwt.Handler, l logger.Logger) []gin.HandlerFunc {
-	return []gin.HandlerFunc{
-		ratelimit.NewBuilder(cmd, time.Minute, 100).Build(), // 限制每分钟最多 100 次请求
+func GinMiddlewares(cmd redis.Cmdable, hdl ijwt.Handler, userSvc service.UserService,
+	devSMSHdl *web.DevSMSHandler, l logger.Logger) []gin.HandlerFunc {
+	beforeAuth, afterAuth := rateLimitHandlers(cmd, l)
+	funcs := []gin.HandlerFunc{
 		corsHandler(), // 配置 CORS 中间件
-
-		// 使用 JWT 中间件
-		middleware.NewJWTLoginMiddlewareBuilder(hdl).Build(),
-
+	}
+	// 按 IP、路由限流放在 JWT 中间件之前，被限流的请求不需要再校验 token
+	funcs = append(funcs, beforeAuth...)
+	// 使用 JWT 中间件
+	jwtBuilder := middleware.NewJWTLoginMiddlewareBuilder(hdl, userSvc, l)
+	// 调试用的接口只在开发环境注册，也只在开发环境放行
+	if devSMSHdl != nil {
+		jwtBuilder.IgnorePaths(devSMSHdl.PublicPaths()...)
+	}
+	funcs = append(funcs, jwtBuilder.Build())
+	// 按用户限流放在 JWT 中间件之后，才能拿到用户信息
+	funcs = append(funcs, afterAuth...)
+	return append(funcs,
 		// 访问日志中间件
 		accesslog.NewMiddlewareBuilder(func(ctx context.Context, al accesslog.AccessLog) {
 			// 设置为 DEBUG 级别
@@ -45,14 +84,16 @@ func GinMiddlewares(cmd redis.Cmdable, hdl ijwt.Handler, l logger.Logger) []gin.
 				Value: al,
 			})
 		}).AllowReqBody().AllowRespBody().Build(),
-	}
+	)
 }
 
 func corsHandler() gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowCredentials: true,                                       // 允许客户端发送认证信息
-		AllowHeaders:     []string{"Content-Type", "Authorization"},  // 允许的请求头
-		ExposeHeaders:    []string{"X-Jwt-Token", "X-Refresh-Token"}, // 暴露的响应头
+		AllowCredentials: true,                                      // 允许客户端发送认证信息
+		AllowHeaders:     []string{"Content-Type", "Authorization"}, // 允许的请求头
+		// 暴露的响应头
+		ExposeHeaders: []string{"X-Jwt-Token", "X-Refresh-Token",
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowOriginFunc: func(origin string) bool {
 			// 允许来自 localhost 和指定公司域名的请求
 			if strings.HasPrefix(origin, "http://localhost") {
@@ -0,0 +1,43 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+const defaultImportDir = "./data/import"
+
+func InitImportService(summaryRepo repository.DailySummaryRepository,
+	jobRepo repository.ImportJobRepository, l logger.Logger) service.ImportService {
+	cfg := service.ImportConfig{
+		Dir:          defaultImportDir,
+		BatchSize:    500,
+		MaxRowErrors: 1000,
+		JobTimeout:   time.Minute * 30,
+		Sources:      map[string]service.ImportSource{},
+	}
+	err := viper.UnmarshalKey("import", &cfg)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", cfg, err))
+	}
+	// 内置的数据来源：本系统自己导出的 CSV / NDJSON 文件，列名与数据库一致
+	columns := make(map[string]string)
+	for _, col := range summaryRepo.ExportColumns() {
+		columns[col] = col
+	}
+	// 导出文件中的 id、user_id 等列不需要导入
+	for _, col := range []string{"id", "user_id", "ctime", "utime"} {
+		delete(columns, col)
+	}
+	if _, ok := cfg.Sources["badminton_csv"]; !ok {
+		cfg.Sources["badminton_csv"] = service.ImportSource{Format: service.ImportFormatCSV, Columns: columns}
+	}
+	if _, ok := cfg.Sources["badminton_json"]; !ok {
+		cfg.Sources["badminton_json"] = service.ImportSource{Format: service.ImportFormatJSON, Columns: columns}
+	}
+	return service.NewImportService(summaryRepo, jobRepo, cfg, l)
+}
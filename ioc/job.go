@@ -0,0 +1,48 @@
+package ioc
+
+import (
+	"badminton-backend/internal/job"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+func InitScheduler(l logger.Logger, reportJob *job.ReportJob, purgeJob *job.AccountPurgeJob,
+	archiveCleanJob *job.DataArchiveCleanJob, smsRetryJob *job.SMSRetryJob,
+	staleJobSweepJob *job.StaleJobSweepJob) *job.Scheduler {
+	type Config struct {
+		ReportInterval       time.Duration
+		ReportTimeout        time.Duration
+		AccountPurgeInterval time.Duration
+		AccountPurgeTimeout  time.Duration
+		ArchiveCleanInterval time.Duration
+		ArchiveCleanTimeout  time.Duration
+		SMSRetryInterval     time.Duration
+		SMSRetryTimeout      time.Duration
+		StaleJobInterval     time.Duration
+		StaleJobTimeout      time.Duration
+	}
+	c := Config{
+		ReportInterval:       time.Hour,
+		ReportTimeout:        time.Minute * 10,
+		AccountPurgeInterval: time.Hour,
+		AccountPurgeTimeout:  time.Minute * 10,
+		ArchiveCleanInterval: time.Hour,
+		ArchiveCleanTimeout:  time.Minute * 10,
+		SMSRetryInterval:     time.Second * 10,
+		SMSRetryTimeout:      time.Minute,
+		StaleJobInterval:     time.Minute * 5,
+		StaleJobTimeout:      time.Minute,
+	}
+	err := viper.UnmarshalKey("job", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+	return job.NewScheduler(l).
+		AddJob(reportJob, c.ReportInterval, c.ReportTimeout).
+		AddJob(purgeJob, c.AccountPurgeInterval, c.AccountPurgeTimeout).
+		AddJob(archiveCleanJob, c.ArchiveCleanInterval, c.ArchiveCleanTimeout).
+		AddJob(smsRetryJob, c.SMSRetryInterval, c.SMSRetryTimeout).
+		AddJob(staleJobSweepJob, c.StaleJobInterval, c.StaleJobTimeout)
+}
@@ -0,0 +1,53 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository/cache"
+	ijwt "badminton-backend/internal/web/jwt"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+func InitJWTHandler(sessions cache.SessionCache, keys *ijwt.Keys, l logger.Logger) ijwt.Handler {
+	cfg := ijwt.DegradeConfig{
+		Enabled:     true,
+		MaxDuration: time.Minute * 5,
+	}
+	err := viper.UnmarshalKey("jwt.degrade", &cfg)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", cfg, err))
+	}
+	return ijwt.NewRedisHandler(sessions, keys, cfg, l)
+}
+
+func InitJWTKeys() *ijwt.Keys {
+	type Config struct {
+		Access  ijwt.KeySetConfig
+		Refresh ijwt.KeySetConfig
+	}
+	var c Config
+	err := viper.UnmarshalKey("jwt", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+	// 没有配置密钥时不能启动，不能退回到写死在代码里的密钥
+	if len(c.Access.Keys) == 0 {
+		panic(fmt.Errorf("没有配置 jwt.access 的签名密钥"))
+	}
+	if len(c.Refresh.Keys) == 0 {
+		panic(fmt.Errorf("没有配置 jwt.refresh 的签名密钥"))
+	}
+	access, err := ijwt.NewKeySet(c.Access)
+	if err != nil {
+		panic(fmt.Errorf("初始化 access token 密钥失败 %w", err))
+	}
+	refresh, err := ijwt.NewKeySet(c.Refresh)
+	if err != nil {
+		panic(fmt.Errorf("初始化 refresh token 密钥失败 %w", err))
+	}
+	return &ijwt.Keys{
+		Access:  access,
+		Refresh: refresh,
+	}
+}
@@ -0,0 +1,84 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/internal/service/captcha"
+	"badminton-backend/pkg/logger"
+	"badminton-backend/pkg/ratelimit"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"net/http"
+	"time"
+)
+
+func InitLoginGuard(cmd redis.Cmdable, repo repository.LoginAttemptRepository, l logger.Logger) service.LoginGuard {
+	type Lockout struct {
+		Window    time.Duration
+		Threshold int
+		BaseLock  time.Duration
+		MaxLock   time.Duration
+	}
+	type Captcha struct {
+		// Type 为 none 时不要求人机验证，siteverify 兼容 reCAPTCHA、hCaptcha、Turnstile
+		Type      string
+		VerifyURL string
+		Secret    string
+		Timeout   time.Duration
+		// AccountAfter、IPAfter 失败多少次之后要求人机验证
+		AccountAfter int
+		IPAfter      int
+	}
+	type Config struct {
+		// IPAttemptsPerMinute 同一个 IP 每分钟最多尝试登录多少次，不区分成功失败
+		IPAttemptsPerMinute int
+		Account             Lockout
+		IP                  Lockout
+		Captcha             Captcha
+	}
+	c := Config{
+		IPAttemptsPerMinute: 30,
+		Account: Lockout{
+			Window:    15 * time.Minute,
+			Threshold: 5,
+			BaseLock:  time.Minute,
+			MaxLock:   time.Hour,
+		},
+		IP: Lockout{
+			Window:    15 * time.Minute,
+			Threshold: 50,
+			BaseLock:  time.Minute,
+			MaxLock:   time.Hour,
+		},
+		Captcha: Captcha{
+			Type:         "none",
+			Timeout:      5 * time.Second,
+			AccountAfter: 3,
+			IPAfter:      10,
+		},
+	}
+	err := viper.UnmarshalKey("loginGuard", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+
+	cfg := service.LoginGuardConfig{
+		Account: repository.LockoutPolicy(c.Account),
+		IP:      repository.LockoutPolicy(c.IP),
+	}
+	var captchaSvc captcha.Service
+	switch c.Captcha.Type {
+	case "none":
+		l.Warn("没有配置人机验证，登录失败之后只会锁定")
+	case "siteverify":
+		captchaSvc = captcha.NewSiteVerifyService(c.Captcha.VerifyURL, c.Captcha.Secret,
+			&http.Client{Timeout: c.Captcha.Timeout})
+		cfg.AccountCaptchaAfter = c.Captcha.AccountAfter
+		cfg.IPCaptchaAfter = c.Captcha.IPAfter
+	default:
+		panic(fmt.Errorf("不支持的人机验证类型 %s", c.Captcha.Type))
+	}
+	ipLimiter := ratelimit.NewRedisSlidingWindowLimiter(cmd, time.Minute, c.IPAttemptsPerMinute)
+	return service.NewLoginGuard(repo, ipLimiter, captchaSvc, cfg, l)
+}
@@ -0,0 +1,40 @@
+package ioc
+
+import (
+	"badminton-backend/internal/service/notify"
+	"badminton-backend/internal/service/sms"
+	"badminton-backend/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+func InitNotifier(smsSvc sms.Service, templates *sms.TemplateRegistry, l logger.Logger) notify.Notifier {
+	type Config struct {
+		// Type 通知渠道：sms 或者 log。短信模板在 sms.templates 中按业务类型配置
+		Type string
+	}
+	c := Config{
+		Type: "log",
+	}
+	err := viper.UnmarshalKey("notify", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+	// 之前的版本在 notify.templates 中配置模板，继续使用旧配置会在发送的时候才发现找不到模板
+	if viper.IsSet("notify.templates") {
+		panic(fmt.Errorf("notify.templates 已经不再使用，请把通知的短信模板移到 sms.templates 中"))
+	}
+	switch c.Type {
+	case "sms":
+		for _, biz := range []string{notify.BizTrainingReport, notify.BizDataArchive} {
+			if !templates.Has(biz) {
+				panic(fmt.Errorf("notify.type 为 sms 时必须在 sms.templates 中配置 %s 模板", biz))
+			}
+		}
+		return notify.NewSMSNotifier(smsSvc)
+	case "log":
+		return notify.NewLogNotifier(l)
+	default:
+		panic(fmt.Errorf("未知的通知渠道 %s", c.Type))
+	}
+}
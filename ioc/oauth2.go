@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/internal/service/oauth2"
+	"fmt"
+	"github.com/spf13/viper"
+	"net/http"
+	"time"
+)
+
+func InitOAuth2Service(stateRepo repository.OAuth2StateRepository, identityRepo repository.UserIdentityRepository,
+	userRepo repository.UserRepository) service.OAuth2Service {
+	type Config struct {
+		Timeout   time.Duration
+		Providers map[string]oauth2.Config
+	}
+	c := Config{
+		Timeout: time.Second * 10,
+	}
+	err := viper.UnmarshalKey("oauth2", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+	client := &http.Client{Timeout: c.Timeout}
+	providers := make(map[string]oauth2.Provider, len(c.Providers))
+	for name, cfg := range c.Providers {
+		providers[name] = oauth2.NewGenericProvider(cfg, client)
+	}
+	return service.NewOAuth2Service(providers, stateRepo, identityRepo, userRepo)
+}
@@ -0,0 +1,146 @@
+package ioc
+
+import (
+	"badminton-backend/internal/web/middleware"
+	ginxratelimit "badminton-backend/pkg/ginx/middleware/ratelimit"
+	"badminton-backend/pkg/logger"
+	"badminton-backend/pkg/ratelimit"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// rateLimitHandlers 按配置的策略构建限流中间件，一个请求会经过所有匹配的策略
+// 按用户限流的策略依赖 JWT 中间件放进去的用户信息，放在 afterAuth 中，要注册在 JWT 中间件后面
+// 其他策略放在 beforeAuth 中，在校验 token、查询 Redis 会话之前就拦下请求
+func rateLimitHandlers(cmd redis.Cmdable, l logger.Logger) (beforeAuth []gin.HandlerFunc, afterAuth []gin.HandlerFunc) {
+	type Policy struct {
+		// Name 用作限流 key 的前缀，不同策略不能重复
+		Name string
+		// Paths 为空时对所有请求生效，以 / 结尾的表示前缀
+		Paths []string
+		// Key 限流对象：ip、user、route、header
+		Key string
+		// Header Key 为 header 时使用的请求头，例如 X-Api-Key
+		Header string
+		// HeaderValues 认可的请求头取值，例如已经发放的 API key，其余的值按 IP 限流
+		HeaderValues []string
+		// Algorithm 限流算法：slidingWindow、tokenBucket、gcra、local
+		Algorithm string
+		Interval  time.Duration
+		Rate      int
+		// Burst 允许的突发请求数，滑动窗口不需要，默认等于 rate
+		Burst int
+		// OnError Redis 出错时的处理，为空时使用全局的配置
+		OnError string
+	}
+	type Breaker struct {
+		// Threshold 连续失败多少次之后熔断
+		Threshold int
+		Cooldown  time.Duration
+	}
+	type Config struct {
+		// OnError Redis 出错时的处理：open 放行、closed 拒绝、local 改用进程内限流
+		OnError  string
+		Breaker  Breaker
+		Policies []Policy
+	}
+	c := Config{
+		OnError: "local",
+		Breaker: Breaker{
+			Threshold: 5,
+			Cooldown:  10 * time.Second,
+		},
+		Policies: []Policy{
+			// 限制每个 IP 每分钟最多 100 次请求
+			{Name: "ip-limiter", Key: "ip", Algorithm: "slidingWindow", Interval: time.Minute, Rate: 100},
+		},
+	}
+	err := viper.UnmarshalKey("rateLimit", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+
+	// 所有策略访问的是同一个 Redis，共用一个熔断器
+	breaker := ratelimit.NewCircuitBreaker(c.Breaker.Threshold, c.Breaker.Cooldown, l)
+	names := make(map[string]struct{}, len(c.Policies))
+	for _, p := range c.Policies {
+		if _, ok := names[p.Name]; ok || p.Name == "" {
+			panic(fmt.Errorf("限流策略的名字不能为空也不能重复 %s", p.Name))
+		}
+		names[p.Name] = struct{}{}
+		if p.Burst == 0 {
+			p.Burst = p.Rate
+		}
+		limiter := newLimiter(cmd, p.Algorithm, p.Interval, p.Rate, p.Burst)
+		if p.Algorithm != "local" {
+			limiter = ratelimit.NewBreakerLimiter(limiter, breaker)
+		}
+		builder := ginxratelimit.NewBuilder(limiter, rateLimitKey(p.Key, p.Header, p.HeaderValues)).
+			Prefix(p.Name).Paths(p.Paths...)
+		if p.OnError == "" {
+			p.OnError = c.OnError
+		}
+		switch p.OnError {
+		case "open":
+			builder.FailOpen()
+		case "closed":
+		case "local":
+			builder.Fallback(ratelimit.NewLocalLimiter(p.Interval, p.Rate, p.Burst)).FailOpen()
+		default:
+			panic(fmt.Errorf("不支持的限流出错处理 %s", p.OnError))
+		}
+		if p.Key == "user" {
+			afterAuth = append(afterAuth, builder.Build())
+		} else {
+			beforeAuth = append(beforeAuth, builder.Build())
+		}
+	}
+	return beforeAuth, afterAuth
+}
+
+func newLimiter(cmd redis.Cmdable, algorithm string, interval time.Duration, rate, burst int) ratelimit.Limiter {
+	if interval <= 0 || rate <= 0 || burst <= 0 {
+		panic(fmt.Errorf("限流的 interval、rate、burst 必须大于 0"))
+	}
+	switch algorithm {
+	// 没有配置时保持原来的滑动窗口
+	case "slidingWindow", "":
+		return ratelimit.NewRedisSlidingWindowLimiter(cmd, interval, rate)
+	case "tokenBucket":
+		return ratelimit.NewRedisTokenBucketLimiter(cmd, interval, rate, burst)
+	case "gcra":
+		return ratelimit.NewRedisGCRALimiter(cmd, interval, rate, burst)
+	case "local":
+		return ratelimit.NewLocalLimiter(interval, rate, burst)
+	default:
+		panic(fmt.Errorf("不支持的限流算法 %s", algorithm))
+	}
+}
+
+func rateLimitKey(key, header string, values []string) ginxratelimit.KeyFunc {
+	switch key {
+	case "ip":
+		return ginxratelimit.KeyByIP
+	case "user":
+		return middleware.RateLimitKeyByUser
+	case "route":
+		return ginxratelimit.KeyByRoute
+	case "header":
+		if header == "" || len(values) == 0 {
+			panic(fmt.Errorf("按请求头限流必须配置 header 和 headerValues"))
+		}
+		known := make(map[string]struct{}, len(values))
+		for _, v := range values {
+			known[v] = struct{}{}
+		}
+		return ginxratelimit.KeyByHeader(header, func(val string) bool {
+			_, ok := known[val]
+			return ok
+		})
+	default:
+		panic(fmt.Errorf("不支持的限流对象 %s", key))
+	}
+}
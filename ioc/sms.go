@@ -1,20 +1,162 @@
 package ioc
 
 import (
+	"badminton-backend/internal/repository"
 	"badminton-backend/internal/service/sms"
+	"badminton-backend/internal/service/sms/aliyun"
+	"badminton-backend/internal/service/sms/async"
+	"badminton-backend/internal/service/sms/failover"
+	"badminton-backend/internal/service/sms/logsms"
+	"badminton-backend/internal/service/sms/memory"
 	"badminton-backend/internal/service/sms/tencent"
+	"badminton-backend/pkg/logger"
+	"badminton-backend/pkg/ratelimit"
+	"fmt"
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 	tencentSMS "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
+	"net/http"
 	"os"
+	"time"
 )
 
-func InitSmsService(cmd redis.Cmdable) sms.Service {
-	return initSmsTencentService()
+type smsTencentConfig struct {
+	AppId    string
+	SignName string
+	Region   string
 }
 
-func initSmsTencentService() sms.Service {
+type smsAliyunConfig struct {
+	SignName string
+	Timeout  time.Duration
+}
+
+type smsConfig struct {
+	// Provider 只使用一个服务商时的简便写法，配置了的话忽略 Providers
+	Provider string
+	// Providers 使用的服务商，按优先级排列：tencent、aliyun、memory、log
+	Providers []string
+	// Strategy 有多个服务商时的切换策略：roundRobin 轮流使用，失败时换下一个；timeout 连续超时之后切换
+	Strategy string
+	// Timeout timeout 策略下单次发送的超时时间
+	Timeout time.Duration
+	Health  repository.SMSHealthPolicy
+	Tencent smsTencentConfig
+	Aliyun  smsAliyunConfig
+	// Rate 每秒最多调用服务商多少次，超过的放进重试队列，0 表示不限制
+	Rate int
+	// Async 同步发送失败之后的重试
+	Async async.Config
+}
+
+// InitSmsService 按配置组合服务商，最外层是异步重试
+// memory 服务商使用传进来的 store，开发环境的接口从里面读取最后一条短信
+func InitSmsService(cmd redis.Cmdable, healthRepo repository.SMSHealthRepository,
+	taskRepo repository.SMSTaskRepository, templates *sms.TemplateRegistry,
+	store *memory.Service, l logger.Logger) *async.Service {
+	c := smsConfig{
+		Providers: []string{"tencent"},
+		Strategy:  "timeout",
+		Timeout:   5 * time.Second,
+		Health: repository.SMSHealthPolicy{
+			Window:    time.Minute,
+			Threshold: 3,
+			Cooldown:  time.Minute,
+		},
+		Tencent: smsTencentConfig{
+			Region: "ap-beijing",
+		},
+		Aliyun: smsAliyunConfig{
+			Timeout: 10 * time.Second,
+		},
+		Async: async.Config{
+			MaxAttempts: 5,
+			BaseBackoff: 10 * time.Second,
+			MaxBackoff:  2 * time.Minute,
+			MaxAge:      10 * time.Minute,
+			BatchSize:   100,
+			Workers:     10,
+			Lease:       30 * time.Second,
+		},
+	}
+	err := viper.UnmarshalKey("sms", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+	if c.Provider != "" {
+		c.Providers = []string{c.Provider}
+	}
+
+	var limiter ratelimit.Limiter
+	if c.Rate > 0 {
+		limiter = ratelimit.NewRedisGCRALimiter(cmd, time.Second, c.Rate, c.Rate)
+	}
+	return async.NewService(initSmsProviders(c, healthRepo, templates, store, l),
+		taskRepo, templates, limiter, c.Async, l)
+}
+
+// InitSmsTemplates 逻辑模板名 -> 各个服务商的模板 ID 和参数
+func InitSmsTemplates() *sms.TemplateRegistry {
+	templates := map[string]sms.Template{
+		// 验证码短信，某个业务需要不同的文案时配置 code_<biz>，例如 code_reset_password
+		// 各个服务商的模板 ID 必须在配置文件中指定
+		"code": {
+			Params: []string{"code"},
+		},
+	}
+	err := viper.UnmarshalKey("sms.templates", &templates)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", templates, err))
+	}
+	return sms.NewTemplateRegistry(templates)
+}
+
+func initSmsProviders(c smsConfig, healthRepo repository.SMSHealthRepository, templates *sms.TemplateRegistry,
+	store *memory.Service, l logger.Logger) sms.Service {
+	providers := make([]failover.Provider, 0, len(c.Providers))
+	for _, name := range c.Providers {
+		var svc sms.Service
+		switch name {
+		case "tencent":
+			svc = initSmsTencentService(c.Tencent, templates)
+		case "aliyun":
+			svc = initSmsAliyunService(c.Aliyun, templates)
+		case "memory":
+			svc = store
+		case "log":
+			svc = logsms.NewService(l)
+		default:
+			panic(fmt.Errorf("不支持的短信服务商 %s", name))
+		}
+		if name == "tencent" || name == "aliyun" {
+			if err := templates.CheckProvider(name); err != nil {
+				panic(err)
+			}
+		}
+		providers = append(providers, failover.Provider{Name: name, Svc: svc})
+	}
+	switch {
+	case len(providers) == 0:
+		panic(fmt.Errorf("至少要配置一个短信服务商"))
+	case len(providers) == 1:
+		return providers[0].Svc
+	}
+	switch c.Strategy {
+	case "roundRobin":
+		return failover.NewFailoverService(providers, healthRepo, c.Health, l)
+	case "timeout":
+		return failover.NewTimeoutFailoverService(providers, c.Timeout, healthRepo, c.Health, l)
+	default:
+		panic(fmt.Errorf("不支持的短信切换策略 %s", c.Strategy))
+	}
+}
+
+func initSmsTencentService(c smsTencentConfig, templates *sms.TemplateRegistry) sms.Service {
+	if c.AppId == "" || c.SignName == "" {
+		panic("没有配置 sms.tencent.appId 或者 sms.tencent.signName ")
+	}
 	secretId, ok := os.LookupEnv("Tencent_SMS_Secret_Id")
 	if !ok {
 		panic("没有找到环境变量 Tencent_SMS_Secret_Id ")
@@ -24,11 +166,23 @@ func initSmsTencentService() sms.Service {
 		panic("没有找到环境变量 Tencent_SMS_Secret_Key ")
 	}
 
-	c, err := tencentSMS.NewClient(common.NewCredential(secretId, secretKey),
-		"ap-beijing",
+	client, err := tencentSMS.NewClient(common.NewCredential(secretId, secretKey),
+		c.Region,
 		profile.NewClientProfile())
 	if err != nil {
 		panic("tencentSMS 初始化失败 ")
 	}
-	return tencent.NewService(c, "1400952398", "南絮0124公众号")
+	return tencent.NewService(client, c.AppId, c.SignName, templates)
+}
+
+func initSmsAliyunService(c smsAliyunConfig, templates *sms.TemplateRegistry) sms.Service {
+	accessKeyId, ok := os.LookupEnv("Aliyun_SMS_Access_Key_Id")
+	if !ok {
+		panic("没有找到环境变量 Aliyun_SMS_Access_Key_Id ")
+	}
+	accessKeySecret, ok := os.LookupEnv("Aliyun_SMS_Access_Key_Secret")
+	if !ok {
+		panic("没有找到环境变量 Aliyun_SMS_Access_Key_Secret ")
+	}
+	return aliyun.NewService(&http.Client{Timeout: c.Timeout}, accessKeyId, accessKeySecret, c.SignName, templates)
 }
@@ -0,0 +1,37 @@
+package ioc
+
+import (
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+func InitStrokeAnalysisService(repo repository.DailySummaryRepository) service.StrokeAnalysisService {
+	cfg := service.StrokeAnalysisConfig{
+		MinBackhandRatio:   0.1,
+		MinCategoryStrokes: 20,
+		DeviationRatio:     0.5,
+		MinExpectedShare:   0.02,
+		// 默认的理想击球结构，各项之和为 1
+		Ideal: map[string]float64{
+			"forehand_clear": 0.12,
+			"backhand_clear": 0.04,
+			"forehand_lift":  0.08,
+			"backhand_lift":  0.06,
+			"forehand_net":   0.08,
+			"backhand_net":   0.07,
+			"forehand_smash": 0.12,
+			"backhand_smash": 0.01,
+			"forehand_drop":  0.10,
+			"backhand_drop":  0.04,
+			"forehand_drive": 0.14,
+			"backhand_drive": 0.14,
+		},
+	}
+	err := viper.UnmarshalKey("strokeAnalysis", &cfg)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", cfg, err))
+	}
+	return service.NewStrokeAnalysisService(repo, cfg)
+}
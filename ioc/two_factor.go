@@ -0,0 +1,55 @@
+package ioc
+
+import (
+	"badminton-backend/internal/domain"
+	"badminton-backend/internal/repository"
+	"badminton-backend/internal/service"
+	"badminton-backend/pkg/logger"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+func InitTwoFactorService(repo repository.TwoFactorRepository, preAuthRepo repository.PreAuthRepository,
+	userRepo repository.UserRepository, codeSvc service.CodeService, guard service.LoginGuard,
+	l logger.Logger) service.TwoFactorService {
+	type Config struct {
+		Issuer            string
+		RequiredRole      int
+		MaxAttempts       int64
+		RecoveryCodeCount int
+		// EncryptionKey base64 编码的 32 字节 AES 密钥，用来加密 TOTP 密钥
+		EncryptionKey string
+	}
+	c := Config{
+		Issuer:            "Badminton",
+		RequiredRole:      int(domain.RoleCoach),
+		MaxAttempts:       5,
+		RecoveryCodeCount: 10,
+	}
+	err := viper.UnmarshalKey("twoFactor", &c)
+	if err != nil {
+		panic(fmt.Errorf("初始化配置失败 %v, 原因 %w", c, err))
+	}
+	key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		panic(fmt.Errorf("twoFactor.encryptionKey 必须是 base64 编码的 32 字节密钥"))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewTwoFactorService(repo, preAuthRepo, userRepo, codeSvc, guard, service.TwoFactorConfig{
+		Issuer:            c.Issuer,
+		RequiredRole:      domain.Role(c.RequiredRole),
+		MaxAttempts:       c.MaxAttempts,
+		RecoveryCodeCount: c.RecoveryCodeCount,
+		SecretCipher:      aead,
+	}, l)
+}
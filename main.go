@@ -1,21 +1,54 @@
 package main
 
 import (
+	"badminton-backend/ioc"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	_ "github.com/spf13/viper/remote"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	initViper()
-	server := InitWebServer()
+	app := InitApp()
+	// 启动后台任务
+	app.scheduler.Start()
+
+	server := app.server
 	// 注册路由
 	server.GET("/hello", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "hello, world")
 	})
-	server.Run("localhost:8080")
+
+	srv := &http.Server{
+		Addr:    ioc.ServerAddr(),
+		Handler: server,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP 服务异常退出 %v", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	// 先停止接收新请求并等待处理中的请求结束，再停止后台任务
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("关闭 HTTP 服务失败 %v", err)
+	}
+	app.scheduler.Stop()
 }
 
 func initViper() {
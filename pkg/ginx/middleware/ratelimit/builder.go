@@ -1,37 +1,70 @@
 package ratelimit
 
 import (
-	_ "embed"
+	"badminton-backend/pkg/ratelimit"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// resultKey 同一个请求可能经过多个限流中间件，响应头只反映最紧的那个
+const resultKey = "ratelimit_result"
+
+// KeyFunc 从请求中提取限流对象，例如客户端 IP、用户 id
+type KeyFunc func(ctx *gin.Context) string
+
+// KeyByIP 按客户端 IP 限流
+func KeyByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// KeyByRoute 按路由限流，所有人共享同一个配额，用来保护比较重的接口
+func KeyByRoute(ctx *gin.Context) string {
+	route := ctx.FullPath()
+	if route == "" {
+		// 没有匹配上路由的请求统一算在一起，避免随便构造路径绕过限流
+		route = "unknown"
+	}
+	return "route:" + route
+}
+
+// KeyByHeader 按请求头限流，例如 API key
+// 请求头的值由客户端决定，只有 valid 认可的值才单独计数，
+// 没带或者不认识的值按 IP 限流，否则每次换一个值就能拿到一份新的配额
+func KeyByHeader(name string, valid func(val string) bool) KeyFunc {
+	return func(ctx *gin.Context) string {
+		val := ctx.GetHeader(name)
+		if val == "" || !valid(val) {
+			return KeyByIP(ctx)
+		}
+		return "header:" + val
+	}
+}
+
 // Builder 用于构建限流中间件
-// 它包括配置前缀、Redis 客户端、限流间隔时间和阈值
+// 限流算法由 limiter 决定，限流对象由 key 决定
 type Builder struct {
-	prefix   string        // 限流 key 的前缀，用于构建唯一的 Redis key
-	cmd      redis.Cmdable // Redis 命令接口，提供执行 Redis 命令的方法
-	interval time.Duration // 限流的时间间隔
-	rate     int           // 限流的阈值，表示在指定的时间间隔内允许的最大请求次数
+	prefix  string // 限流 key 的前缀，不同的策略要用不同的前缀
+	limiter ratelimit.Limiter
+	key     KeyFunc
+	// paths 只对这些路径生效，为空时对所有请求生效
+	paths []string
+	// failOpen 限流器出错时放行
+	failOpen bool
+	// fallback 限流器出错时改用它限流，通常是进程内的限流器
+	fallback ratelimit.Limiter
 }
 
-// luaScript 是通过 embed 嵌入的 Lua 脚本，
-// 用于在 Redis 上执行限流逻辑（滑动窗口算法）
-//
-//go:embed slide_window.lua
-var luaScript string
-
 // NewBuilder 创建一个新的限流中间件构建器
-// cmd 是 Redis 客户端，interval 是限流时间间隔，rate 是每个时间间隔允许的最大请求次数
-func NewBuilder(cmd redis.Cmdable, interval time.Duration, rate int) *Builder {
+func NewBuilder(limiter ratelimit.Limiter, key KeyFunc) *Builder {
 	return &Builder{
-		cmd:      cmd,
-		prefix:   "ip-limiter", // 默认的 Redis 键前缀
-		interval: interval,
-		rate:     rate,
+		prefix:  "limiter", // 默认的 Redis 键前缀
+		limiter: limiter,
+		key:     key,
 	}
 }
 
@@ -41,19 +74,49 @@ func (b *Builder) Prefix(prefix string) *Builder {
 	return b
 }
 
+// Paths 限定生效的路径。以 / 结尾的表示前缀，用来匹配一整个路由分组，其余的要完全一致
+func (b *Builder) Paths(paths ...string) *Builder {
+	b.paths = append(b.paths, paths...)
+	return b
+}
+
+// FailOpen 限流器出错时放行。默认拒绝请求
+func (b *Builder) FailOpen() *Builder {
+	b.failOpen = true
+	return b
+}
+
+// Fallback 限流器出错时改用 limiter 限流，它也出错的话再按 FailOpen 处理
+func (b *Builder) Fallback(limiter ratelimit.Limiter) *Builder {
+	b.fallback = limiter
+	return b
+}
+
 // Build 创建并返回一个 Gin 中间件处理函数
 // 这个函数会在每个请求中进行限流操作
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		// 调用 limit 函数来检查请求是否被限流
-		limited, err := b.limit(ctx)
+		if !b.match(ctx.Request.URL.Path) {
+			return
+		}
+		key := fmt.Sprintf("%s:%s", b.prefix, b.key(ctx))
+		res, err := b.limiter.Check(ctx, key)
+		if err != nil && b.fallback != nil {
+			res, err = b.fallback.Check(ctx, key)
+		}
 		if err != nil {
+			if b.failOpen {
+				ctx.Next()
+				return
+			}
 			// 如果执行限流检查时发生错误，返回 500 系统错误
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
-		if limited {
+		setHeaders(ctx, res)
+		if res.Limited {
 			// 如果被限流，返回 429 Too Many Requests 错误
+			ctx.Header("Retry-After", strconv.Itoa(seconds(res.Reset)))
 			ctx.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
@@ -62,12 +125,33 @@ func (b *Builder) Build() gin.HandlerFunc {
 	}
 }
 
-// limit 是核心限流逻辑的实现
-// 它会根据客户端的 IP 地址，在 Redis 中执行 Lua 脚本，判断该 IP 是否超过了限流阈值
-func (b *Builder) limit(ctx *gin.Context) (bool, error) {
-	// 使用客户端 IP 和指定的前缀来构建 Redis key
-	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	// 执行 Lua 脚本，传递限流相关的参数
-	return b.cmd.Eval(ctx, luaScript, []string{key},
-		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+func (b *Builder) match(path string) bool {
+	if len(b.paths) == 0 {
+		return true
+	}
+	for _, p := range b.paths {
+		if p == path || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// setHeaders 设置 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 响应头
+// 前面的限流中间件已经设置过并且剩余的配额更少时保留前面的
+func setHeaders(ctx *gin.Context, res ratelimit.Result) {
+	if val, ok := ctx.Get(resultKey); ok {
+		if prev := val.(ratelimit.Result); prev.Remaining < res.Remaining && !res.Limited {
+			return
+		}
+	}
+	ctx.Set(resultKey, res)
+	ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Header("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+}
+
+// seconds 响应头里的时间单位是秒，向上取整，避免客户端提前重试
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
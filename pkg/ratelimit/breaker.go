@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"badminton-backend/pkg/logger"
+	"errors"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断期间不再访问 Redis，直接返回这个错误
+var ErrCircuitOpen = errors.New("限流熔断中")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	// breakerHalfOpen 冷却时间过了之后放一个请求去探测
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// CircuitBreaker 连续失败 threshold 次之后熔断 cooldown，避免 Redis 挂掉的时候每个请求都要等到超时
+// 多个限流器访问的是同一个 Redis 时应该共用一个 CircuitBreaker
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	l         logger.Logger
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// probing 半开状态下已经有请求在探测了
+	probing bool
+	// now 测试时替换成可控的时钟
+	now func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration, l logger.Logger) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		l:         l,
+		now:       time.Now,
+	}
+}
+
+// allow 返回 false 表示熔断中，不应该访问 Redis
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transit(breakerHalfOpen)
+		b.probing = true
+		return true
+	default:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+}
+
+func (b *CircuitBreaker) onSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	if b.state != breakerClosed {
+		b.transit(breakerClosed)
+	}
+}
+
+func (b *CircuitBreaker) onFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.transit(breakerOpen, logger.Field{Key: "err", Value: err})
+	}
+}
+
+// release 探测请求没有得出结论，让下一个请求继续探测
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) transit(to breakerState, fields ...logger.Field) {
+	from := b.state
+	b.state = to
+	fields = append(fields,
+		logger.Field{Key: "from", Value: from.String()},
+		logger.Field{Key: "to", Value: to.String()},
+		logger.Field{Key: "failures", Value: b.failures})
+	switch to {
+	case breakerOpen:
+		b.l.Warn("限流熔断", append(fields, logger.Field{Key: "cooldown", Value: b.cooldown.String()})...)
+	case breakerHalfOpen:
+		b.l.Info("限流熔断冷却结束，开始探测", fields...)
+	default:
+		b.l.Info("限流熔断恢复", fields...)
+	}
+}
+
+// BreakerLimiter 给限流器加上熔断
+type BreakerLimiter struct {
+	limiter Limiter
+	breaker *CircuitBreaker
+}
+
+func NewBreakerLimiter(limiter Limiter, breaker *CircuitBreaker) *BreakerLimiter {
+	return &BreakerLimiter{
+		limiter: limiter,
+		breaker: breaker,
+	}
+}
+
+func (b *BreakerLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := b.Check(ctx, key)
+	return res.Limited, err
+}
+
+func (b *BreakerLimiter) Check(ctx context.Context, key string) (Result, error) {
+	if !b.breaker.allow() {
+		return Result{}, ErrCircuitOpen
+	}
+	res, err := b.limiter.Check(ctx, key)
+	switch {
+	case err == nil:
+		b.breaker.onSuccess()
+	case ctx.Err() != nil:
+		// 客户端断开导致的错误和 Redis 无关，但是探测的名额要还回去
+		b.breaker.release()
+	default:
+		b.breaker.onFailure(err)
+	}
+	return res, err
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"badminton-backend/pkg/logger"
+	"errors"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+var errRedisDown = errors.New("redis down")
+
+// stubLimiter 每次返回 err，并记录被调用的次数
+type stubLimiter struct {
+	err   error
+	calls int
+}
+
+func (s *stubLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := s.Check(ctx, key)
+	return res.Limited, err
+}
+
+func (s *stubLimiter) Check(ctx context.Context, key string) (Result, error) {
+	s.calls++
+	return Result{}, s.err
+}
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	b := NewCircuitBreaker(threshold, cooldown, logger.NewZapLogger(zap.NewNop()))
+	now := time.Now()
+	b.now = func() time.Time {
+		return now
+	}
+	return b, &now
+}
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	type step struct {
+		// advance 这次请求之前时钟前进的时间
+		advance time.Duration
+		// err 下游限流器返回的错误
+		err error
+		// canceled 请求的 ctx 已经取消
+		canceled bool
+		wantErr  error
+		// wantCalled 是否访问了下游限流器
+		wantCalled bool
+		wantState  breakerState
+	}
+	testCases := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "连续失败达到阈值之后熔断",
+			steps: []step{
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerOpen},
+				{wantErr: ErrCircuitOpen, wantState: breakerOpen},
+			},
+		},
+		{
+			name: "成功会清零失败次数",
+			steps: []step{
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "冷却结束之后探测成功恢复",
+			steps: []step{
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerOpen},
+				{advance: 9 * time.Second, wantErr: ErrCircuitOpen, wantState: breakerOpen},
+				{advance: time.Second, wantCalled: true, wantState: breakerClosed},
+				{wantCalled: true, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "探测失败重新熔断一个冷却时间",
+			steps: []step{
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerOpen},
+				{advance: 10 * time.Second, err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerOpen},
+				{advance: 9 * time.Second, wantErr: ErrCircuitOpen, wantState: breakerOpen},
+				{advance: time.Second, wantCalled: true, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "客户端断开不计入失败，探测名额还回去",
+			steps: []step{
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerClosed},
+				{err: errRedisDown, wantErr: errRedisDown, wantCalled: true, wantState: breakerOpen},
+				{advance: 10 * time.Second, err: context.Canceled, canceled: true,
+					wantErr: context.Canceled, wantCalled: true, wantState: breakerHalfOpen},
+				{wantCalled: true, wantState: breakerClosed},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, now := newTestBreaker(3, 10*time.Second)
+			stub := &stubLimiter{}
+			limiter := NewBreakerLimiter(stub, b)
+			for i, s := range tc.steps {
+				*now = now.Add(s.advance)
+				stub.err = s.err
+				stub.calls = 0
+				ctx, cancel := context.WithCancel(context.Background())
+				if s.canceled {
+					cancel()
+				}
+				_, err := limiter.Check(ctx, "key")
+				cancel()
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("第 %d 个请求返回错误 %v，期望 %v", i+1, err, s.wantErr)
+				}
+				if called := stub.calls > 0; called != s.wantCalled {
+					t.Fatalf("第 %d 个请求访问下游 %v，期望 %v", i+1, called, s.wantCalled)
+				}
+				if b.state != s.wantState {
+					t.Fatalf("第 %d 个请求之后状态是 %s，期望 %s", i+1, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_SingleProbe(t *testing.T) {
+	b, now := newTestBreaker(1, 10*time.Second)
+	b.onFailure(errRedisDown)
+	*now = now.Add(10 * time.Second)
+	if !b.allow() {
+		t.Fatal("冷却结束之后应该放一个请求去探测")
+	}
+	// 探测还没有结果的时候，其他请求不能访问 Redis
+	if b.allow() {
+		t.Fatal("半开状态下只能有一个请求在探测")
+	}
+	b.release()
+	if !b.allow() {
+		t.Fatal("探测名额还回去之后下一个请求应该可以探测")
+	}
+	b.onSuccess()
+	if b.state != breakerClosed || !b.allow() || !b.allow() {
+		t.Fatal("探测成功之后应该恢复")
+	}
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+// LocalLimiter 进程内的 GCRA 限流，适合单实例部署，也可以在 Redis 不可用时兜底
+// 多实例部署时每个实例各算各的，整体的阈值会放大到实例数倍
+type LocalLimiter struct {
+	emission  time.Duration
+	tolerance time.Duration
+	burst     int
+
+	mu sync.Mutex
+	// tats 每个 key 下一个请求理论上到达的时间
+	tats map[string]time.Time
+	// lastSweep 过期的 key 定期清理，避免内存无限增长
+	lastSweep time.Time
+	// now 测试时替换成可控的时钟
+	now func() time.Time
+}
+
+func NewLocalLimiter(interval time.Duration, rate, burst int) *LocalLimiter {
+	emission := interval / time.Duration(rate)
+	return &LocalLimiter{
+		emission:  emission,
+		tolerance: emission * time.Duration(burst),
+		burst:     burst,
+		tats:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *LocalLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.Check(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalLimiter) Check(ctx context.Context, key string) (Result, error) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	tat, ok := l.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(l.emission)
+	allowAt := newTat.Add(-l.tolerance)
+	if now.Before(allowAt) {
+		return Result{
+			Limited: true,
+			Limit:   l.burst,
+			Reset:   allowAt.Sub(now),
+		}, nil
+	}
+	l.tats[key] = newTat
+	return Result{
+		Limit:     l.burst,
+		Remaining: int(now.Sub(allowAt) / l.emission),
+		Reset:     newTat.Sub(now),
+	}, nil
+}
+
+// sweep tat 早于当前时间的 key 和不存在是一样的
+func (l *LocalLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.tolerance {
+		return
+	}
+	l.lastSweep = now
+	for key, tat := range l.tats {
+		if tat.Before(now) {
+			delete(l.tats, key)
+		}
+	}
+}
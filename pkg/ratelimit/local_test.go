@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+)
+
+func TestLocalLimiter_Check(t *testing.T) {
+	// 每秒 10 个，也就是每 100ms 恢复一个配额
+	type step struct {
+		// advance 这次请求之前时钟前进的时间
+		advance       time.Duration
+		key           string
+		wantLimited   bool
+		wantRemaining int
+		wantReset     time.Duration
+	}
+	testCases := []struct {
+		name  string
+		burst int
+		steps []step
+	}{
+		{
+			name:  "突发用完之后限流",
+			burst: 3,
+			steps: []step{
+				{key: "a", wantRemaining: 2, wantReset: 100 * time.Millisecond},
+				{key: "a", wantRemaining: 1, wantReset: 200 * time.Millisecond},
+				{key: "a", wantRemaining: 0, wantReset: 300 * time.Millisecond},
+				{key: "a", wantLimited: true, wantReset: 100 * time.Millisecond},
+				// 被限流的请求不消耗配额
+				{key: "a", wantLimited: true, wantReset: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "按速率恢复配额",
+			burst: 3,
+			steps: []step{
+				{key: "a", wantRemaining: 2, wantReset: 100 * time.Millisecond},
+				{key: "a", wantRemaining: 1, wantReset: 200 * time.Millisecond},
+				{key: "a", wantRemaining: 0, wantReset: 300 * time.Millisecond},
+				{advance: 100 * time.Millisecond, key: "a", wantRemaining: 0, wantReset: 300 * time.Millisecond},
+				{advance: 50 * time.Millisecond, key: "a", wantLimited: true, wantReset: 50 * time.Millisecond},
+				{advance: 50 * time.Millisecond, key: "a", wantRemaining: 0, wantReset: 300 * time.Millisecond},
+			},
+		},
+		{
+			name:  "空闲足够久之后恢复全部配额",
+			burst: 3,
+			steps: []step{
+				{key: "a", wantRemaining: 2, wantReset: 100 * time.Millisecond},
+				{key: "a", wantRemaining: 1, wantReset: 200 * time.Millisecond},
+				{key: "a", wantRemaining: 0, wantReset: 300 * time.Millisecond},
+				{advance: time.Second, key: "a", wantRemaining: 2, wantReset: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "突发为 1 时请求间隔不能小于速率",
+			burst: 1,
+			steps: []step{
+				{key: "a", wantRemaining: 0, wantReset: 100 * time.Millisecond},
+				{advance: 99 * time.Millisecond, key: "a", wantLimited: true, wantReset: time.Millisecond},
+				{advance: time.Millisecond, key: "a", wantRemaining: 0, wantReset: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "不同的 key 互不影响",
+			burst: 1,
+			steps: []step{
+				{key: "a", wantRemaining: 0, wantReset: 100 * time.Millisecond},
+				{key: "a", wantLimited: true, wantReset: 100 * time.Millisecond},
+				{key: "b", wantRemaining: 0, wantReset: 100 * time.Millisecond},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLocalLimiter(time.Second, 10, tc.burst)
+			now := time.Now()
+			l.now = func() time.Time {
+				return now
+			}
+			for i, s := range tc.steps {
+				now = now.Add(s.advance)
+				res, err := l.Check(context.Background(), s.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := Result{
+					Limited:   s.wantLimited,
+					Limit:     tc.burst,
+					Remaining: s.wantRemaining,
+					Reset:     s.wantReset,
+				}
+				if res != want {
+					t.Fatalf("第 %d 个请求返回 %+v，期望 %+v", i+1, res, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalLimiter_Sweep(t *testing.T) {
+	l := NewLocalLimiter(time.Second, 10, 3)
+	now := time.Now()
+	l.now = func() time.Time {
+		return now
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := l.Check(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 超过容忍时间之后，再来一个请求会清理掉已经过期的 key
+	now = now.Add(time.Second)
+	if _, err := l.Check(context.Background(), "d"); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.tats) != 1 {
+		t.Fatalf("清理之后还剩 %d 个 key，期望只剩 1 个", len(l.tats))
+	}
+}
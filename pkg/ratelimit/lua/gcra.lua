@@ -0,0 +1,30 @@
+-- GCRA（通用信元速率算法）：只保存一个时间戳 tat，表示按照固定速率下一个请求理论上到达的时间
+-- 效果和令牌桶一样，但是每个 key 只有一个字符串
+
+local key = KEYS[1]
+-- 两个请求之间的间隔，毫秒，可以是小数
+local emission = tonumber(ARGV[1])
+-- 允许的突发请求数
+local burst = tonumber(ARGV[2])
+-- 当前时间戳，毫秒
+local now = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call('GET', key))
+if not tat or tat < now then
+    tat = now
+end
+
+-- 允许 tat 比当前时间超前 burst 个间隔
+local tolerance = emission * burst
+local newTat = tat + emission
+local allowAt = newTat - tolerance
+
+if now < allowAt then
+    -- 返回 是否限流、剩余的请求数、多少毫秒之后可以再次请求
+    return {1, 0, math.ceil(allowAt - now)}
+end
+
+redis.call('SET', key, newTat, 'PX', math.ceil(newTat - now))
+local remaining = math.floor((now - allowAt) / emission)
+-- 返回 是否限流、剩余的请求数、多少毫秒之后配额恢复
+return {0, remaining, math.ceil(newTat - now)}
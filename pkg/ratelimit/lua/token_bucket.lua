@@ -0,0 +1,42 @@
+-- 令牌桶：桶里最多 capacity 个令牌，每毫秒补充 refill 个，每次请求消耗一个
+-- 用一个 hash 保存剩余的令牌数和上一次补充的时间，每个 key 占用的内存是固定的
+
+local key = KEYS[1]
+-- 桶的容量，也就是允许的突发请求数
+local capacity = tonumber(ARGV[1])
+-- 每毫秒补充的令牌数
+local refill = tonumber(ARGV[2])
+-- 当前时间戳，毫秒
+local now = tonumber(ARGV[3])
+
+local tokens = capacity
+local last = now
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+if state[1] then
+    tokens = tonumber(state[1])
+    last = tonumber(state[2])
+end
+
+-- 补充上一次请求到现在的令牌。各个实例的时钟可能有偏差，时间倒退时不补充
+local elapsed = math.max(0, now - last)
+tokens = math.min(capacity, tokens + elapsed * refill)
+
+local limited = 1
+if tokens >= 1 then
+    tokens = tokens - 1
+    limited = 0
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', math.max(now, last))
+-- 桶补满之后这个 key 和不存在是一样的，可以过期
+redis.call('PEXPIRE', key, math.ceil(capacity / refill))
+
+-- 被限流时返回多久之后有一个令牌，否则返回多久之后桶会补满
+local reset
+if limited == 1 then
+    reset = math.ceil((1 - tokens) / refill)
+else
+    reset = math.ceil((capacity - tokens) / refill)
+end
+-- 返回 是否限流、剩余的令牌数、多少毫秒之后配额恢复
+return {limited, math.floor(tokens), reset}
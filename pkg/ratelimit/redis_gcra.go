@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"time"
+)
+
+//go:embed lua/gcra.lua
+var luaGCRA string
+
+// RedisGCRALimiter GCRA 限流，效果和令牌桶一样，每个 key 只占用一个字符串
+type RedisGCRALimiter struct {
+	cmd redis.Cmdable
+	// emission 两个请求之间的间隔，也就是 interval / rate
+	emission time.Duration
+	burst    int
+}
+
+func NewRedisGCRALimiter(cmd redis.Cmdable, interval time.Duration, rate, burst int) *RedisGCRALimiter {
+	return &RedisGCRALimiter{
+		cmd:      cmd,
+		emission: interval / time.Duration(rate),
+		burst:    burst,
+	}
+}
+
+func (r *RedisGCRALimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := r.Check(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisGCRALimiter) Check(ctx context.Context, key string) (Result, error) {
+	vals, err := r.cmd.Eval(ctx, luaGCRA, []string{key},
+		float64(r.emission)/float64(time.Millisecond), r.burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 3 {
+		return Result{}, errors.New("限流脚本返回值不对")
+	}
+	return Result{
+		Limited:   vals[0] == 1,
+		Limit:     r.burst,
+		Remaining: int(vals[1]),
+		Reset:     time.Duration(vals[2]) * time.Millisecond,
+	}, nil
+}
@@ -2,8 +2,11 @@ package ratelimit
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/net/context"
+	"math/rand/v2"
 	"time"
 )
 
@@ -26,7 +29,25 @@ func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rat
 }
 
 func (r *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return r.cmd.Eval(ctx, luaScript, []string{key},
+	res, err := r.Check(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisSlidingWindowLimiter) Check(ctx context.Context, key string) (Result, error) {
+	now := time.Now().UnixMilli()
+	vals, err := r.cmd.Eval(ctx, luaScript, []string{key},
 		r.interval.Milliseconds(),
-		r.rate, time.Now().UnixMilli()).Bool()
+		r.rate, now, fmt.Sprintf("%d-%d", now, rand.Int64())).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 3 {
+		return Result{}, errors.New("限流脚本返回值不对")
+	}
+	return Result{
+		Limited:   vals[0] == 1,
+		Limit:     r.rate,
+		Remaining: max(r.rate-int(vals[1]), 0),
+		Reset:     time.Duration(vals[2]) * time.Millisecond,
+	}, nil
 }
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/context"
+	"time"
+)
+
+//go:embed lua/token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter 令牌桶限流，每个 key 只占用一个 hash
+type RedisTokenBucketLimiter struct {
+	cmd redis.Cmdable
+	// interval 内补充 rate 个令牌
+	interval time.Duration
+	rate     int
+	// burst 桶的容量
+	burst int
+}
+
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate, burst int) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		burst:    burst,
+	}
+}
+
+func (r *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := r.Check(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisTokenBucketLimiter) Check(ctx context.Context, key string) (Result, error) {
+	refill := float64(r.rate) / float64(r.interval.Milliseconds())
+	vals, err := r.cmd.Eval(ctx, luaTokenBucket, []string{key},
+		r.burst, refill, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 3 {
+		return Result{}, errors.New("限流脚本返回值不对")
+	}
+	return Result{
+		Limited:   vals[0] == 1,
+		Limit:     r.burst,
+		Remaining: int(vals[1]),
+		Reset:     time.Duration(vals[2]) * time.Millisecond,
+	}, nil
+}
@@ -1,7 +1,24 @@
 package ratelimit
 
-import "golang.org/x/net/context"
+import (
+	"golang.org/x/net/context"
+	"time"
+)
 
 type Limiter interface {
+	// Limit 返回 true 表示触发了限流
 	Limit(ctx context.Context, key string) (bool, error)
+	// Check 和 Limit 一样会消耗一次配额，同时返回配额的使用情况，用于设置 RateLimit-* 响应头
+	Check(ctx context.Context, key string) (Result, error)
+}
+
+// Result 一次限流检查的结果
+type Result struct {
+	Limited bool
+	// Limit 窗口内允许的请求数
+	Limit int
+	// Remaining 窗口内还剩下的请求数
+	Remaining int
+	// Reset 多久之后可以再次请求。没有被限流时表示多久之后配额恢复
+	Reset time.Duration
 }
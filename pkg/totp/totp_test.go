@@ -0,0 +1,88 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret RFC 6238 附录 B 中 SHA1 使用的密钥 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 附录 B 的测试向量，RFC 中是 8 位，这里取最后 6 位
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tc := range testCases {
+		got, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("T=%d 计算验证码失败 %v", tc.unix, err)
+		}
+		if got != tc.want {
+			t.Fatalf("T=%d 验证码是 %s，期望 %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestCodeLowerCaseSecret(t *testing.T) {
+	got, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "287082" {
+		t.Fatalf("小写密钥的验证码是 %s，期望 287082", got)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Fatal("密钥不是 base32 时应该返回错误")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// 1111111111 所在的时间步
+	now := time.Unix(1111111111, 0)
+	cur := Step(now)
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	testCases := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "当前时间步", code: "050471", skew: 0, wantStep: cur, wantOK: true},
+		{name: "不允许误差时拒绝上一个时间步", code: code(cur - 1), skew: 0},
+		{name: "允许误差时接受上一个时间步", code: code(cur - 1), skew: 1, wantStep: cur - 1, wantOK: true},
+		{name: "允许误差时接受下一个时间步", code: code(cur + 1), skew: 1, wantStep: cur + 1, wantOK: true},
+		{name: "超出误差范围", code: code(cur - 2), skew: 1},
+		{name: "超出误差范围的下一个时间步", code: code(cur + 2), skew: 1},
+		{name: "更大的误差范围", code: code(cur + 2), skew: 2, wantStep: cur + 2, wantOK: true},
+		{name: "错误的验证码", code: "000000", skew: 1},
+		{name: "位数不对", code: "50471", skew: 1},
+		{name: "RFC 中的 8 位验证码", code: "14050471", skew: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tc.code, now, tc.skew)
+			if ok != tc.wantOK || step != tc.wantStep {
+				t.Fatalf("返回 (%d, %v)，期望 (%d, %v)", step, ok, tc.wantStep, tc.wantOK)
+			}
+		})
+	}
+}
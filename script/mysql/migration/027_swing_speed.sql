@@ -0,0 +1,20 @@
+-- 挥拍速度直方图，以及按性别计算百分位需要的性别
+
+ALTER TABLE users
+    ADD COLUMN gender BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS swing_speed_stat
+(
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id      BIGINT      NOT NULL DEFAULT 0,
+    summary_date DATE        NULL,
+    stroke_type  VARCHAR(32) NOT NULL DEFAULT '',
+    buckets      TEXT        NULL COMMENT '直方图各个桶的计数，JSON 数组',
+    swing_count  BIGINT      NOT NULL DEFAULT 0,
+    avg_speed    DOUBLE      NOT NULL DEFAULT 0,
+    p50_speed    BIGINT      NOT NULL DEFAULT 0,
+    p90_speed    BIGINT      NOT NULL DEFAULT 0,
+    ctime        BIGINT      NOT NULL DEFAULT 0,
+    utime        BIGINT      NOT NULL DEFAULT 0,
+    UNIQUE INDEX uk_user_date_stroke (user_id, summary_date, stroke_type)
+);
@@ -0,0 +1,13 @@
+-- 周报、月报
+
+CREATE TABLE IF NOT EXISTS training_report
+(
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id    BIGINT      NOT NULL DEFAULT 0,
+    period     VARCHAR(16) NOT NULL DEFAULT '' COMMENT 'week / month',
+    start_date DATE        NULL,
+    end_date   DATE        NULL,
+    content    TEXT        NULL COMMENT '报告正文，JSON 格式',
+    ctime      BIGINT      NOT NULL DEFAULT 0,
+    UNIQUE INDEX uk_user_period (user_id, period, start_date)
+);
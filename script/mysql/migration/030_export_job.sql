@@ -0,0 +1,17 @@
+-- 异步导出任务
+
+CREATE TABLE IF NOT EXISTS export_job
+(
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id    BIGINT        NOT NULL DEFAULT 0,
+    format     VARCHAR(16)   NOT NULL DEFAULT '',
+    start_date DATE          NULL,
+    end_date   DATE          NULL,
+    status     VARCHAR(16)   NOT NULL DEFAULT '',
+    row_count  BIGINT        NOT NULL DEFAULT 0,
+    file_path  VARCHAR(512)  NOT NULL DEFAULT '',
+    err_msg    VARCHAR(1024) NOT NULL DEFAULT '',
+    ctime      BIGINT        NOT NULL DEFAULT 0,
+    utime      BIGINT        NOT NULL DEFAULT 0,
+    INDEX idx_export_job_user_id (user_id)
+);
@@ -0,0 +1,29 @@
+-- 批量导入：daily_summary 每个用户每天只有一行，导入依赖这个唯一索引做 upsert
+
+-- 同一天有多行时只保留最后写入的一行
+DELETE d FROM daily_summary d
+    JOIN daily_summary n ON n.user_id = d.user_id AND n.summary_date = d.summary_date AND n.id > d.id;
+ALTER TABLE daily_summary
+    ADD UNIQUE INDEX uk_user_date (user_id, summary_date);
+-- 之前的 ctime、utime 是秒，统一改成毫秒
+UPDATE daily_summary SET ctime = ctime * 1000 WHERE ctime > 0 AND ctime < 100000000000;
+UPDATE daily_summary SET utime = utime * 1000 WHERE utime > 0 AND utime < 100000000000;
+
+CREATE TABLE IF NOT EXISTS import_job
+(
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id      BIGINT        NOT NULL DEFAULT 0,
+    source       VARCHAR(64)   NOT NULL DEFAULT '',
+    format       VARCHAR(16)   NOT NULL DEFAULT '',
+    file_hash    CHAR(64)      NOT NULL DEFAULT '',
+    file_path    VARCHAR(512)  NOT NULL DEFAULT '',
+    status       VARCHAR(16)   NOT NULL DEFAULT '',
+    total_rows   BIGINT        NOT NULL DEFAULT 0,
+    success_rows BIGINT        NOT NULL DEFAULT 0,
+    failed_rows  BIGINT        NOT NULL DEFAULT 0,
+    errors       MEDIUMTEXT    NULL COMMENT '行错误列表，JSON 格式',
+    err_msg      VARCHAR(1024) NOT NULL DEFAULT '',
+    ctime        BIGINT        NOT NULL DEFAULT 0,
+    utime        BIGINT        NOT NULL DEFAULT 0,
+    INDEX idx_user_hash (user_id, file_hash)
+);
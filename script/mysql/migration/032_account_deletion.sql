@@ -0,0 +1,5 @@
+-- 注销账号
+
+ALTER TABLE users
+    ADD COLUMN dtime BIGINT NOT NULL DEFAULT 0 COMMENT '注销时间，0 表示账号正常',
+    ADD INDEX idx_users_dtime (dtime);
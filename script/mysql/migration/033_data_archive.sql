@@ -0,0 +1,29 @@
+-- 登录历史和个人数据下载
+
+CREATE TABLE IF NOT EXISTS login_history
+(
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id    BIGINT       NOT NULL DEFAULT 0,
+    ssid       VARCHAR(64)  NOT NULL DEFAULT '',
+    method     VARCHAR(16)  NOT NULL DEFAULT '',
+    ip         VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ctime      BIGINT       NOT NULL DEFAULT 0,
+    INDEX idx_login_history_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS data_archive
+(
+    id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id   BIGINT        NOT NULL DEFAULT 0,
+    status    VARCHAR(16)   NOT NULL DEFAULT '',
+    file_path VARCHAR(512)  NOT NULL DEFAULT '',
+    token     VARCHAR(64)   NULL,
+    expire_at BIGINT        NOT NULL DEFAULT 0,
+    err_msg   VARCHAR(1024) NOT NULL DEFAULT '',
+    ctime     BIGINT        NOT NULL DEFAULT 0,
+    utime     BIGINT        NOT NULL DEFAULT 0,
+    UNIQUE INDEX uni_data_archive_token (token),
+    INDEX idx_data_archive_user_id (user_id),
+    INDEX idx_data_archive_expire_at (expire_at)
+);
@@ -0,0 +1,12 @@
+-- 绑定、合并手机号：账号和手机号唯一
+
+-- 加唯一索引之前先确认没有重复的账号和手机号，有重复时需要先人工处理
+-- SELECT account, COUNT(*) FROM users WHERE account IS NOT NULL GROUP BY account HAVING COUNT(*) > 1;
+-- SELECT phone, COUNT(*) FROM users WHERE phone IS NOT NULL GROUP BY phone HAVING COUNT(*) > 1;
+UPDATE users SET account = NULL WHERE account = '';
+UPDATE users SET phone = NULL WHERE phone = '';
+ALTER TABLE users
+    MODIFY COLUMN account VARCHAR(191) NULL,
+    MODIFY COLUMN phone   VARCHAR(191) NULL,
+    ADD UNIQUE INDEX uni_users_account (account),
+    ADD UNIQUE INDEX uni_users_phone (phone);
@@ -0,0 +1,5 @@
+-- 用户角色和禁用
+
+ALTER TABLE users
+    ADD COLUMN role     BIGINT     NOT NULL DEFAULT 0,
+    ADD COLUMN disabled TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- 第三方登录绑定的身份
+
+CREATE TABLE IF NOT EXISTS user_identity
+(
+    id       BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id  BIGINT       NOT NULL DEFAULT 0,
+    provider VARCHAR(64)  NOT NULL DEFAULT '',
+    subject  VARCHAR(255) NOT NULL DEFAULT '',
+    nickname VARCHAR(128) NOT NULL DEFAULT '',
+    ctime    BIGINT       NOT NULL DEFAULT 0,
+    UNIQUE INDEX uniq_provider_subject (provider, subject),
+    INDEX idx_user_identity_user_id (user_id)
+);
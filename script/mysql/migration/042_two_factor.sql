@@ -0,0 +1,23 @@
+-- TOTP 二次验证和恢复码
+
+CREATE TABLE IF NOT EXISTS user_totp
+(
+    id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id   BIGINT       NOT NULL DEFAULT 0,
+    secret    VARCHAR(255) NOT NULL DEFAULT '' COMMENT '加密之后的密钥',
+    enabled   TINYINT(1)   NOT NULL DEFAULT 0,
+    last_step BIGINT       NOT NULL DEFAULT 0,
+    ctime     BIGINT       NOT NULL DEFAULT 0,
+    utime     BIGINT       NOT NULL DEFAULT 0,
+    UNIQUE INDEX uni_user_totp_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_code
+(
+    id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id   BIGINT      NOT NULL DEFAULT 0,
+    code_hash VARCHAR(64) NOT NULL DEFAULT '',
+    used_at   BIGINT      NOT NULL DEFAULT 0 COMMENT '0 表示还没有使用',
+    ctime     BIGINT      NOT NULL DEFAULT 0,
+    INDEX idx_user_recovery_code_user_id (user_id)
+);
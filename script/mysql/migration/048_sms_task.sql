@@ -0,0 +1,17 @@
+-- 发送失败的短信排队重试
+
+CREATE TABLE IF NOT EXISTS sms_task
+(
+    id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tpl_id          VARCHAR(64)   NOT NULL DEFAULT '',
+    args            VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'JSON 数组',
+    numbers         VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'JSON 数组',
+    status          VARCHAR(16)   NOT NULL DEFAULT '',
+    attempts        BIGINT        NOT NULL DEFAULT 0,
+    next_retry_time BIGINT        NOT NULL DEFAULT 0,
+    deadline        BIGINT        NOT NULL DEFAULT 0,
+    last_err        VARCHAR(1024) NOT NULL DEFAULT '',
+    ctime           BIGINT        NOT NULL DEFAULT 0,
+    utime           BIGINT        NOT NULL DEFAULT 0,
+    INDEX idx_status_next_retry (status, next_retry_time)
+);
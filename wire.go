@@ -3,45 +3,115 @@
 package main
 
 import (
+	"badminton-backend/internal/job"
 	"badminton-backend/internal/repository"
 	"badminton-backend/internal/repository/cache"
 	"badminton-backend/internal/repository/dao"
 	"badminton-backend/internal/service"
+	"badminton-backend/internal/service/sms"
+	"badminton-backend/internal/service/sms/async"
+	"badminton-backend/internal/service/sms/memory"
 	"badminton-backend/internal/web"
-	ijwt "badminton-backend/internal/web/jwt"
 	"badminton-backend/ioc"
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
 
-func InitWebServer() *gin.Engine {
+func InitApp() *App {
 	wire.Build(
 		ioc.InitDB, ioc.InitRedis,
 
 		dao.NewGormUserDAO,
 		dao.NewGormDailySummaryDAO,
+		dao.NewGormSwingSpeedDAO,
+		dao.NewGormTrainingReportDAO,
+		dao.NewGormExportJobDAO,
+		dao.NewGormImportJobDAO,
+		dao.NewGormLoginHistoryDAO,
+		dao.NewGormDataArchiveDAO,
+		dao.NewGormUserIdentityDAO,
+		dao.NewGormTwoFactorDAO,
+		dao.NewGormSMSTaskDAO,
 
 		cache.NewRedisUserCache,
 		cache.NewRedisCodeCache,
 		cache.NewRedisDailySummaryCache,
+		cache.NewRedisSessionCache,
+		cache.NewRedisOAuth2StateCache,
+		cache.NewRedisPreAuthCache,
+		cache.NewRedisLoginAttemptCache,
+		cache.NewRedisSMSHealthCache,
 
 		repository.NewCachedUserRepository,
 		repository.NewCachedCodeRepository,
 		repository.NewDailySummaryRepository,
+		repository.NewSwingSpeedRepository,
+		repository.NewTrainingReportRepository,
+		repository.NewExportJobRepository,
+		repository.NewImportJobRepository,
+		repository.NewLoginHistoryRepository,
+		repository.NewSessionRepository,
+		repository.NewDataArchiveRepository,
+		repository.NewUserIdentityRepository,
+		repository.NewCachedOAuth2StateRepository,
+		repository.NewTwoFactorRepository,
+		repository.NewCachedPreAuthRepository,
+		repository.NewCachedLoginAttemptRepository,
+		repository.NewCachedSMSHealthRepository,
+		repository.NewSMSTaskRepository,
 
 		service.NewUserService,
 		service.NewSMSCodeService,
 		service.NewDailySummaryService,
+		service.NewTrainingMetricsService,
+		service.NewSwingSpeedService,
+		service.NewTrainingReportService,
+		service.NewLoginHistoryService,
+		service.NewAdminService,
 
 		ioc.GinMiddlewares,
 		ioc.InitWebServer,
 		ioc.InitLogger,
 		ioc.InitSmsService,
-		ijwt.NewRedisHandler,
+		ioc.InitSmsTemplates,
+		wire.Bind(new(sms.Service), new(*async.Service)),
+		memory.NewService,
+		ioc.InitDevSMSHandler,
+		ioc.InitStrokeAnalysisService,
+		ioc.InitNotifier,
+		ioc.InitScheduler,
+		ioc.InitExportService,
+		ioc.InitImportService,
+		ioc.InitAccountService,
+		ioc.InitDataArchiveService,
+		ioc.InitOAuth2Service,
+		ioc.InitTwoFactorService,
+		ioc.InitLoginGuard,
+		ioc.InitJWTHandler,
+		ioc.InitJWTKeys,
 
 		web.NewUserHandler,
 		web.NewDailySummaryHandler,
+		web.NewSwingSpeedHandler,
+		web.NewStrokeAnalysisHandler,
+		web.NewReportHandler,
+		web.NewExportHandler,
+		web.NewImportHandler,
+		web.NewAccountHandler,
+		web.NewDataArchiveHandler,
+		web.NewSessionHandler,
+		web.NewJWKSHandler,
+		web.NewAdminHandler,
+		web.NewOAuth2Handler,
+		web.NewTwoFactorHandler,
+
+		job.NewReportJob,
+		job.NewAccountPurgeJob,
+		job.NewDataArchiveCleanJob,
+		job.NewSMSRetryJob,
+		job.NewStaleJobSweepJob,
+
+		wire.Struct(new(App), "*"),
 	)
 
-	return new(gin.Engine)
+	return new(App)
 }
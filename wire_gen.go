@@ -7,14 +7,14 @@
 package main
 
 import (
+	"badminton-backend/internal/job"
 	"badminton-backend/internal/repository"
 	"badminton-backend/internal/repository/cache"
 	"badminton-backend/internal/repository/dao"
 	"badminton-backend/internal/service"
+	"badminton-backend/internal/service/sms/memory"
 	"badminton-backend/internal/web"
-	"badminton-backend/internal/web/jwt"
 	"badminton-backend/ioc"
-	"github.com/gin-gonic/gin"
 )
 
 import (
@@ -23,26 +23,94 @@ import (
 
 // Injectors from wire.go:
 
-func InitWebServer() *gin.Engine {
+func InitApp() *App {
 	cmdable := ioc.InitRedis()
-	handler := jwt.NewRedisHandler(cmdable)
+	sessionCache := cache.NewRedisSessionCache(cmdable)
+	keys := ioc.InitJWTKeys()
 	logger := ioc.InitLogger()
-	v := ioc.GinMiddlewares(cmdable, handler, logger)
+	handler := ioc.InitJWTHandler(sessionCache, keys, logger)
 	db := ioc.InitDB(logger)
 	userDAO := dao.NewGormUserDAO(db)
 	userCache := cache.NewRedisUserCache(cmdable)
 	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewUserService(userRepository, logger)
-	smsService := ioc.InitSmsService(cmdable)
+	loginAttemptCache := cache.NewRedisLoginAttemptCache(cmdable)
+	loginAttemptRepository := repository.NewCachedLoginAttemptRepository(loginAttemptCache)
+	loginGuard := ioc.InitLoginGuard(cmdable, loginAttemptRepository, logger)
+	userService := service.NewUserService(userRepository, loginGuard, logger)
+	smsHealthCache := cache.NewRedisSMSHealthCache(cmdable)
+	smsHealthRepository := repository.NewCachedSMSHealthRepository(smsHealthCache)
+	smsTaskDAO := dao.NewGormSMSTaskDAO(db)
+	smsTaskRepository := repository.NewSMSTaskRepository(smsTaskDAO)
+	memoryService := memory.NewService()
+	devSMSHandler := ioc.InitDevSMSHandler(memoryService)
+	v := ioc.GinMiddlewares(cmdable, handler, userService, devSMSHandler, logger)
+	templateRegistry := ioc.InitSmsTemplates()
+	asyncService := ioc.InitSmsService(cmdable, smsHealthRepository, smsTaskRepository, templateRegistry, memoryService, logger)
 	codeCache := cache.NewRedisCodeCache(cmdable)
 	codeRepository := repository.NewCachedCodeRepository(codeCache)
-	codeService := service.NewSMSCodeService(smsService, codeRepository, logger)
-	userHandler := web.NewUserHandler(userService, codeService, handler)
+	codeService := service.NewSMSCodeService(asyncService, codeRepository, templateRegistry, logger)
+	loginHistoryDAO := dao.NewGormLoginHistoryDAO(db)
+	loginHistoryRepository := repository.NewLoginHistoryRepository(loginHistoryDAO)
+	loginHistoryService := service.NewLoginHistoryService(loginHistoryRepository)
+	twoFactorDAO := dao.NewGormTwoFactorDAO(db)
+	twoFactorRepository := repository.NewTwoFactorRepository(twoFactorDAO)
+	preAuthCache := cache.NewRedisPreAuthCache(cmdable)
+	preAuthRepository := repository.NewCachedPreAuthRepository(preAuthCache)
+	twoFactorService := ioc.InitTwoFactorService(twoFactorRepository, preAuthRepository, userRepository, codeService, loginGuard, logger)
+	userHandler := web.NewUserHandler(userService, codeService, loginHistoryService, twoFactorService, handler, logger)
 	dailySummaryDAO := dao.NewGormDailySummaryDAO(db)
 	dailySummaryCache := cache.NewRedisDailySummaryCache(cmdable)
 	dailySummaryRepository := repository.NewDailySummaryRepository(dailySummaryDAO, dailySummaryCache)
 	dailySummaryService := service.NewDailySummaryService(dailySummaryRepository)
-	dailySummaryHandler := web.NewDailySummaryHandler(dailySummaryService)
-	engine := ioc.InitWebServer(v, userHandler, dailySummaryHandler)
-	return engine
+	trainingMetricsService := service.NewTrainingMetricsService(dailySummaryRepository, userRepository)
+	dailySummaryHandler := web.NewDailySummaryHandler(dailySummaryService, trainingMetricsService)
+	swingSpeedDAO := dao.NewGormSwingSpeedDAO(db)
+	swingSpeedRepository := repository.NewSwingSpeedRepository(swingSpeedDAO)
+	swingSpeedService := service.NewSwingSpeedService(swingSpeedRepository, userRepository)
+	swingSpeedHandler := web.NewSwingSpeedHandler(swingSpeedService)
+	strokeAnalysisService := ioc.InitStrokeAnalysisService(dailySummaryRepository)
+	strokeAnalysisHandler := web.NewStrokeAnalysisHandler(strokeAnalysisService)
+	trainingReportDAO := dao.NewGormTrainingReportDAO(db)
+	trainingReportRepository := repository.NewTrainingReportRepository(trainingReportDAO)
+	notifier := ioc.InitNotifier(asyncService, templateRegistry, logger)
+	trainingReportService := service.NewTrainingReportService(trainingReportRepository, dailySummaryRepository, userRepository, notifier, logger)
+	reportHandler := web.NewReportHandler(trainingReportService)
+	exportJobDAO := dao.NewGormExportJobDAO(db)
+	exportJobRepository := repository.NewExportJobRepository(exportJobDAO)
+	exportService := ioc.InitExportService(dailySummaryRepository, exportJobRepository, logger)
+	exportHandler := web.NewExportHandler(exportService, logger)
+	importJobDAO := dao.NewGormImportJobDAO(db)
+	importJobRepository := repository.NewImportJobRepository(importJobDAO)
+	importService := ioc.InitImportService(dailySummaryRepository, importJobRepository, logger)
+	importHandler := web.NewImportHandler(importService)
+	accountService := ioc.InitAccountService(userRepository, dailySummaryRepository, codeService, logger)
+	accountHandler := web.NewAccountHandler(accountService, handler, logger)
+	dataArchiveDAO := dao.NewGormDataArchiveDAO(db)
+	dataArchiveRepository := repository.NewDataArchiveRepository(dataArchiveDAO)
+	sessionRepository := repository.NewSessionRepository(sessionCache)
+	dataArchiveService := ioc.InitDataArchiveService(dataArchiveRepository, userRepository, dailySummaryRepository, swingSpeedRepository, trainingReportRepository, sessionRepository, loginHistoryRepository, notifier, logger)
+	dataArchiveHandler := web.NewDataArchiveHandler(dataArchiveService)
+	sessionHandler := web.NewSessionHandler(handler)
+	jwksHandler := web.NewJWKSHandler(keys)
+	adminService := service.NewAdminService(userRepository, dailySummaryRepository, smsTaskRepository, logger)
+	adminHandler := web.NewAdminHandler(adminService, handler)
+	oAuth2StateCache := cache.NewRedisOAuth2StateCache(cmdable)
+	oAuth2StateRepository := repository.NewCachedOAuth2StateRepository(oAuth2StateCache)
+	userIdentityDAO := dao.NewGormUserIdentityDAO(db)
+	userIdentityRepository := repository.NewUserIdentityRepository(userIdentityDAO)
+	oAuth2Service := ioc.InitOAuth2Service(oAuth2StateRepository, userIdentityRepository, userRepository)
+	oAuth2Handler := web.NewOAuth2Handler(oAuth2Service, loginHistoryService, twoFactorService, handler, logger)
+	twoFactorHandler := web.NewTwoFactorHandler(twoFactorService, loginHistoryService, handler, logger)
+	engine := ioc.InitWebServer(v, userHandler, dailySummaryHandler, swingSpeedHandler, strokeAnalysisHandler, reportHandler, exportHandler, importHandler, accountHandler, dataArchiveHandler, sessionHandler, jwksHandler, adminHandler, oAuth2Handler, twoFactorHandler, devSMSHandler)
+	reportJob := job.NewReportJob(trainingReportService)
+	accountPurgeJob := job.NewAccountPurgeJob(accountService)
+	dataArchiveCleanJob := job.NewDataArchiveCleanJob(dataArchiveService)
+	smsRetryJob := job.NewSMSRetryJob(asyncService)
+	staleJobSweepJob := job.NewStaleJobSweepJob(exportService, importService, dataArchiveService, logger)
+	scheduler := ioc.InitScheduler(logger, reportJob, accountPurgeJob, dataArchiveCleanJob, smsRetryJob, staleJobSweepJob)
+	app := &App{
+		server:    engine,
+		scheduler: scheduler,
+	}
+	return app
 }